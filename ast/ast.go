@@ -3,9 +3,14 @@ package ast
 import (
 	"bytes"
 	"goscript/token"
+	"path/filepath"
 	"strings"
 )
 
+// SourceExt is the extension of source files, which may be left out of the
+// path of an import.
+const SourceExt = ".gs"
+
 type Node interface {
 	TokenLiteral() string
 	String() string
@@ -23,6 +28,7 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+	Comments   []*Comment
 }
 
 func (p *Program) TokenLiteral() string {
@@ -43,10 +49,22 @@ func (p *Program) String() string {
 	return out.String()
 }
 
+// A LetStatement binds either Name or, when it destructures its value, the
+// names in Pattern.
 type LetStatement struct {
-	Token token.Token
-	Name  *Identifier
-	Value Expression
+	Token   token.Token
+	Name    *Identifier
+	Pattern Expression
+	Type    TypeExpression
+	Value   Expression
+}
+
+// Names returns the identifiers the let binds.
+func (ls *LetStatement) Names() []*Identifier {
+	if ls.Name != nil {
+		return []*Identifier{ls.Name}
+	}
+	return PatternNames(ls.Pattern)
 }
 
 func (ls *LetStatement) statementNode()       {}
@@ -55,7 +73,14 @@ func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	if ls.Name != nil {
+		out.WriteString(ls.Name.String())
+	} else {
+		out.WriteString(ls.Pattern.String())
+	}
+	if ls.Type != nil {
+		out.WriteString(": " + ls.Type.String())
+	}
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -68,8 +93,19 @@ func (ls *LetStatement) String() string {
 }
 
 type Identifier struct {
-	Token token.Token
-	Value string
+	Token   token.Token
+	Value   string
+	Binding *Binding
+}
+
+// Binding is set by the resolver. Locals live in slot Slot of the frame
+// Depth function calls out from the use; globals are looked up by name in
+// the outermost environment. Identifiers without a binding are looked up by
+// name through the whole environment chain.
+type Binding struct {
+	Depth  int
+	Slot   int
+	Global bool
 }
 
 func (i *Identifier) expressionNode()      {}
@@ -129,6 +165,78 @@ func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
+// An InterpolatedString is a string literal with expressions in ${}. Its
+// Parts are StringLiterals for the text between them and the expressions.
+type InterpolatedString struct {
+	Token token.Token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	for _, part := range is.Parts {
+		if text, ok := part.(*StringLiteral); ok {
+			out.WriteString(text.Value)
+			continue
+		}
+		out.WriteString("${" + part.String() + "}")
+	}
+
+	return out.String()
+}
+
+type YieldStatement struct {
+	Token token.Token
+	Value Expression
+}
+
+func (ys *YieldStatement) statementNode()       {}
+func (ys *YieldStatement) TokenLiteral() string { return ys.Token.Literal }
+func (ys *YieldStatement) String() string       { return "yield " + ys.Value.String() + ";" }
+
+// A ForStatement binds Variable to each value of Iterable in turn. Like a
+// let, the variable belongs to the enclosing function, not to the body.
+type ForStatement struct {
+	Token    token.Token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) String() string {
+	return "for (" + fs.Variable.String() + " in " + fs.Iterable.String() + ") " + fs.Body.String()
+}
+
+// A StructStatement declares a struct and binds Name to its constructor,
+// which takes the value of each field in order. Methods are function
+// literals named after the method, in which self is the value they are
+// called on.
+type StructStatement struct {
+	Token   token.Token
+	Name    *Identifier
+	Fields  []*Identifier
+	Methods []*FunctionLiteral
+	Rbrace  token.Token
+}
+
+func (ss *StructStatement) statementNode()       {}
+func (ss *StructStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *StructStatement) String() string {
+	members := []string{}
+	for _, field := range ss.Fields {
+		members = append(members, field.String())
+	}
+	for _, method := range ss.Methods {
+		members = append(members, method.String())
+	}
+	return "struct " + ss.Name.String() + " {" + strings.Join(members, ", ") + "}"
+}
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -148,6 +256,126 @@ func (pe *PrefixExpression) String() string {
 	return out.String()
 }
 
+// A SpawnExpression runs Call in a new task. Call is either a call
+// expression, whose function and arguments are evaluated before the task
+// starts, or an expression evaluating to a function without parameters.
+type SpawnExpression struct {
+	Token token.Token
+	Call  Expression
+}
+
+func (se *SpawnExpression) expressionNode()      {}
+func (se *SpawnExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpawnExpression) String() string       { return "(spawn " + se.Call.String() + ")" }
+
+// An ArrayPattern destructures an array into its elements, which are
+// patterns themselves, and the array of the elements after them if Rest is
+// set. Patterns are the identifiers and patterns bound by a let or a
+// parameter.
+type ArrayPattern struct {
+	Token    token.Token
+	Elements []Expression
+	Rest     *Identifier
+}
+
+func (ap *ArrayPattern) expressionNode()      {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// A HashPattern destructures a hash by looking up each key, written as a
+// name, and binding the value to the matching pattern. {name} is short for
+// {name: name}.
+type HashPattern struct {
+	Token  token.Token
+	Keys   []*Identifier
+	Values []Expression
+}
+
+func (hp *HashPattern) expressionNode()      {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) String() string {
+	pairs := []string{}
+	for i, key := range hp.Keys {
+		if ident, ok := hp.Values[i].(*Identifier); ok && ident.Value == key.Value {
+			pairs = append(pairs, key.Value)
+		} else {
+			pairs = append(pairs, key.Value+": "+hp.Values[i].String())
+		}
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// A TypePattern matches the values of a type, such as int or string, and
+// binds them to Name if it is set: n: int.
+type TypePattern struct {
+	Token token.Token
+	Name  *Identifier
+	Type  string
+}
+
+func (tp *TypePattern) expressionNode()      {}
+func (tp *TypePattern) TokenLiteral() string { return tp.Token.Literal }
+func (tp *TypePattern) String() string {
+	if tp.Name != nil {
+		return tp.Name.String() + ": " + tp.Type
+	}
+	return tp.Type
+}
+
+// IsWildcard reports whether pattern is _, which matches anything and binds
+// nothing.
+func IsWildcard(pattern Expression) bool {
+	ident, ok := pattern.(*Identifier)
+	return ok && ident.Value == "_"
+}
+
+// PatternNames returns the identifiers bound by a pattern, in order.
+func PatternNames(pattern Expression) []*Identifier {
+	var names []*Identifier
+	switch pattern := pattern.(type) {
+	case *Identifier:
+		if !IsWildcard(pattern) {
+			names = append(names, pattern)
+		}
+	case *ArrayPattern:
+		for _, el := range pattern.Elements {
+			names = append(names, PatternNames(el)...)
+		}
+		if pattern.Rest != nil {
+			names = append(names, PatternNames(pattern.Rest)...)
+		}
+	case *HashPattern:
+		for _, value := range pattern.Values {
+			names = append(names, PatternNames(value)...)
+		}
+	case *TypePattern:
+		if pattern.Name != nil {
+			names = append(names, PatternNames(pattern.Name)...)
+		}
+	}
+	return names
+}
+
+// A SpreadExpression passes the elements of Value as separate arguments of
+// a call or elements of an array literal.
+type SpreadExpression struct {
+	Token token.Token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
 type InfixExpression struct {
 	Token    token.Token
 	Left     Expression
@@ -203,9 +431,53 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// A MatchExpression compares Value with the pattern of each arm in turn and
+// evaluates to the body of the first arm that matches and whose guard, if it
+// has one, is true.
+type MatchExpression struct {
+	Token  token.Token
+	Value  Expression
+	Arms   []*MatchArm
+	Rbrace token.Token
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) String() string {
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+	return "match (" + me.Value.String() + ") {" + strings.Join(arms, ", ") + "}"
+}
+
+// A MatchArm binds the names of its pattern in a frame of its own, whose
+// slot names the resolver records in Locals.
+type MatchArm struct {
+	Token   token.Token
+	Pattern Expression
+	Guard   Expression
+	Body    Expression
+	Locals  []string
+}
+
+func (ma *MatchArm) TokenLiteral() string { return ma.Token.Literal }
+func (ma *MatchArm) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ma.Pattern.String())
+	if ma.Guard != nil {
+		out.WriteString(" if " + ma.Guard.String())
+	}
+	out.WriteString(" => " + ma.Body.String())
+
+	return out.String()
+}
+
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
+	Rbrace     token.Token
 }
 
 func (bs *BlockStatement) statementNode()       {}
@@ -220,10 +492,64 @@ func (bs *BlockStatement) String() string {
 	return out.String()
 }
 
+// The parameter types, defaults and patterns of a FunctionLiteral are nil
+// when no parameter has one; otherwise they line up with the parameters, with
+// nil for the parameters without. A parameter with a pattern is bound under a
+// name that cannot be written, the pattern itself, before being destructured.
 type FunctionLiteral struct {
-	Token      token.Token
-	Parameters []*Identifier
-	Body       *BlockStatement
+	Token          token.Token
+	Parameters     []*Identifier
+	ParameterTypes []TypeExpression
+	Defaults       []Expression
+	Patterns       []Expression
+	ReturnType     TypeExpression
+	Body           *BlockStatement
+	Name           string
+	Locals         []string
+
+	// Rest is set when the last parameter collects the remaining arguments
+	// into an array.
+	Rest bool
+
+	// Generator is set by the parser when the body yields.
+	Generator bool
+}
+
+func (fl *FunctionLiteral) ParameterType(i int) TypeExpression {
+	if i < len(fl.ParameterTypes) {
+		return fl.ParameterTypes[i]
+	}
+	return nil
+}
+
+func (fl *FunctionLiteral) ParameterDefault(i int) Expression {
+	if i < len(fl.Defaults) {
+		return fl.Defaults[i]
+	}
+	return nil
+}
+
+func (fl *FunctionLiteral) ParameterPattern(i int) Expression {
+	if i < len(fl.Patterns) {
+		return fl.Patterns[i]
+	}
+	return nil
+}
+
+// ParameterString returns the i-th parameter as it is written, with its rest
+// marker, type annotation and default value.
+func (fl *FunctionLiteral) ParameterString(i int) string {
+	s := fl.Parameters[i].String()
+	if fl.Rest && i == len(fl.Parameters)-1 {
+		s = "..." + s
+	}
+	if t := fl.ParameterType(i); t != nil {
+		s += ": " + t.String()
+	}
+	if d := fl.ParameterDefault(i); d != nil {
+		s += " = " + d.String()
+	}
+	return s
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -232,14 +558,17 @@ func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+	for i := range fl.Parameters {
+		params = append(params, fl.ParameterString(i))
 	}
 
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(")")
+	if fl.ReturnType != nil {
+		out.WriteString(": " + fl.ReturnType.String())
+	}
 	out.WriteString(fl.Body.String())
 
 	return out.String()
@@ -249,6 +578,7 @@ type CallExpression struct {
 	Token     token.Token
 	Function  Expression
 	Arguments []Expression
+	Rparen    token.Token
 }
 
 func (ce *CallExpression) expressionNode()      {}
@@ -314,6 +644,7 @@ func (ie *IndexExpression) String() string {
 type HashLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	Keys  []Expression
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -322,8 +653,8 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -331,4 +662,198 @@ func (hl *HashLiteral) String() string {
 	out.WriteString("}")
 
 	return out.String()
-}
\ No newline at end of file
+}
+
+type ImportStatement struct {
+	Token token.Token
+	Name  *Identifier
+	Path  *StringLiteral
+}
+
+// ModuleName returns the name a module imported from path is bound to when
+// the import does not name it.
+func ModuleName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), SourceExt)
+}
+
+// BoundName returns the name the import binds.
+func (is *ImportStatement) BoundName() string {
+	if is.Name != nil {
+		return is.Name.Value
+	}
+	return ModuleName(is.Path.Value)
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(is.TokenLiteral() + " ")
+
+	if is.Name != nil {
+		out.WriteString(is.Name.String())
+		out.WriteString(" from ")
+	}
+
+	out.WriteString(`"` + is.Path.Value + `"`)
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// An ExportStatement's Statement is a let statement or a struct statement.
+type ExportStatement struct {
+	Token     token.Token
+	Statement Statement
+}
+
+// Name returns the name the statement exports.
+func (es *ExportStatement) Name() *Identifier {
+	switch stmt := es.Statement.(type) {
+	case *LetStatement:
+		return stmt.Name
+	case *StructStatement:
+		return stmt.Name
+	}
+	return nil
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
+
+// A WithExpression is a copy of the struct value Left with the fields named
+// by Fields set to Values.
+type WithExpression struct {
+	Token  token.Token
+	Left   Expression
+	Fields []*Identifier
+	Values []Expression
+}
+
+func (we *WithExpression) expressionNode()      {}
+func (we *WithExpression) TokenLiteral() string { return we.Token.Literal }
+func (we *WithExpression) String() string {
+	pairs := []string{}
+	for i, field := range we.Fields {
+		pairs = append(pairs, field.String()+": "+we.Values[i].String())
+	}
+	return "(" + we.Left.String() + " with {" + strings.Join(pairs, ", ") + "})"
+}
+
+type MemberExpression struct {
+	Token    token.Token
+	Object   Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(me.Object.String())
+	out.WriteString(".")
+	out.WriteString(me.Property.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+type MacroLiteral struct {
+	Token      token.Token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(ml.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+	out.WriteString(ml.Body.String())
+
+	return out.String()
+}
+
+type TypeExpression interface {
+	Node
+	typeNode()
+}
+
+type NamedType struct {
+	Token token.Token
+	Name  string
+}
+
+func (nt *NamedType) typeNode()            {}
+func (nt *NamedType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedType) String() string       { return nt.Name }
+
+type ArrayType struct {
+	Token   token.Token
+	Element TypeExpression
+}
+
+func (at *ArrayType) typeNode()            {}
+func (at *ArrayType) TokenLiteral() string { return at.Token.Literal }
+func (at *ArrayType) String() string       { return "[" + at.Element.String() + "]" }
+
+type HashType struct {
+	Token token.Token
+	Key   TypeExpression
+	Value TypeExpression
+}
+
+func (ht *HashType) typeNode()            {}
+func (ht *HashType) TokenLiteral() string { return ht.Token.Literal }
+func (ht *HashType) String() string {
+	return "{" + ht.Key.String() + ": " + ht.Value.String() + "}"
+}
+
+type FunctionType struct {
+	Token      token.Token
+	Parameters []TypeExpression
+	Return     TypeExpression
+}
+
+func (ft *FunctionType) typeNode()            {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range ft.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+	if ft.Return != nil {
+		out.WriteString(": " + ft.Return.String())
+	}
+
+	return out.String()
+}
+
+type Comment struct {
+	Token token.Token
+	Text  string
+}
+
+func (c *Comment) TokenLiteral() string { return c.Token.Literal }
+func (c *Comment) String() string       { return c.Text }
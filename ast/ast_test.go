@@ -26,3 +26,29 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestImportBoundName(t *testing.T) {
+	tests := []struct {
+		path     string
+		name     string
+		expected string
+	}{
+		{"lib", "", "lib"},
+		{"./util.gs", "", "util"},
+		{"../pkg/strings", "", "strings"},
+		{"data.json", "", "data.json"},
+		{"data.json", "data", "data"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			stmt := &ImportStatement{Path: &StringLiteral{Value: tt.path}}
+			if tt.name != "" {
+				stmt.Name = &Identifier{Value: tt.name}
+			}
+			if got := stmt.BoundName(); got != tt.expected {
+				t.Errorf("wrong name. expected=%q, got=%q", tt.expected, got)
+			}
+		})
+	}
+}
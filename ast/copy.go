@@ -0,0 +1,173 @@
+package ast
+
+func Copy(node Node) Node {
+	switch node := node.(type) {
+	case *Program:
+		return &Program{Statements: copyStatements(node.Statements), Comments: node.Comments}
+	case *LetStatement:
+		return &LetStatement{Token: node.Token, Name: copyIdentifier(node.Name), Pattern: copyExpression(node.Pattern), Type: node.Type, Value: copyExpression(node.Value)}
+	case *ReturnStatement:
+		return &ReturnStatement{Token: node.Token, ReturnValue: copyExpression(node.ReturnValue)}
+	case *ExpressionStatement:
+		return &ExpressionStatement{Token: node.Token, Expression: copyExpression(node.Expression)}
+	case *ImportStatement:
+		return &ImportStatement{Token: node.Token, Name: copyIdentifier(node.Name), Path: Copy(node.Path).(*StringLiteral)}
+	case *ExportStatement:
+		return &ExportStatement{Token: node.Token, Statement: Copy(node.Statement).(Statement)}
+	case *YieldStatement:
+		return &YieldStatement{Token: node.Token, Value: copyExpression(node.Value)}
+	case *ForStatement:
+		return &ForStatement{Token: node.Token, Variable: copyIdentifier(node.Variable), Iterable: copyExpression(node.Iterable), Body: copyBlock(node.Body)}
+	case *StructStatement:
+		methods := make([]*FunctionLiteral, len(node.Methods))
+		for i, method := range node.Methods {
+			methods[i] = Copy(method).(*FunctionLiteral)
+		}
+		return &StructStatement{Token: node.Token, Name: copyIdentifier(node.Name), Fields: copyIdentifiers(node.Fields), Methods: methods, Rbrace: node.Rbrace}
+	case *BlockStatement:
+		return copyBlock(node)
+	case *Identifier:
+		return copyIdentifier(node)
+	case *IntegerLiteral:
+		lit := *node
+		return &lit
+	case *StringLiteral:
+		lit := *node
+		return &lit
+	case *Boolean:
+		lit := *node
+		return &lit
+	case *PrefixExpression:
+		return &PrefixExpression{Token: node.Token, Operator: node.Operator, Right: copyExpression(node.Right)}
+	case *SpawnExpression:
+		return &SpawnExpression{Token: node.Token, Call: copyExpression(node.Call)}
+	case *SpreadExpression:
+		return &SpreadExpression{Token: node.Token, Value: copyExpression(node.Value)}
+	case *ArrayPattern:
+		return &ArrayPattern{Token: node.Token, Elements: copyExpressions(node.Elements), Rest: copyIdentifier(node.Rest)}
+	case *HashPattern:
+		return &HashPattern{Token: node.Token, Keys: copyIdentifiers(node.Keys), Values: copyExpressions(node.Values)}
+	case *TypePattern:
+		return &TypePattern{Token: node.Token, Name: copyIdentifier(node.Name), Type: node.Type}
+	case *MatchExpression:
+		arms := make([]*MatchArm, len(node.Arms))
+		for i, arm := range node.Arms {
+			arms[i] = Copy(arm).(*MatchArm)
+		}
+		return &MatchExpression{Token: node.Token, Value: copyExpression(node.Value), Arms: arms, Rbrace: node.Rbrace}
+	case *MatchArm:
+		return &MatchArm{
+			Token:   node.Token,
+			Pattern: copyExpression(node.Pattern),
+			Guard:   copyExpression(node.Guard),
+			Body:    copyExpression(node.Body),
+			Locals:  node.Locals,
+		}
+	case *InfixExpression:
+		return &InfixExpression{Token: node.Token, Left: copyExpression(node.Left), Operator: node.Operator, Right: copyExpression(node.Right)}
+	case *IfExpression:
+		return &IfExpression{
+			Token:       node.Token,
+			Condition:   copyExpression(node.Condition),
+			Consequence: copyBlock(node.Consequence),
+			Alternative: copyBlock(node.Alternative),
+		}
+	case *FunctionLiteral:
+		return &FunctionLiteral{
+			Token:          node.Token,
+			Parameters:     copyIdentifiers(node.Parameters),
+			ParameterTypes: append([]TypeExpression(nil), node.ParameterTypes...),
+			Defaults:       copyExpressions(node.Defaults),
+			Patterns:       copyExpressions(node.Patterns),
+			ReturnType:     node.ReturnType,
+			Body:           copyBlock(node.Body),
+			Name:           node.Name,
+			Locals:         node.Locals,
+			Rest:           node.Rest,
+			Generator:      node.Generator,
+		}
+	case *MacroLiteral:
+		return &MacroLiteral{Token: node.Token, Parameters: copyIdentifiers(node.Parameters), Body: copyBlock(node.Body)}
+	case *CallExpression:
+		return &CallExpression{Token: node.Token, Function: copyExpression(node.Function), Arguments: copyExpressions(node.Arguments), Rparen: node.Rparen}
+	case *ArrayLiteral:
+		return &ArrayLiteral{Token: node.Token, Elements: copyExpressions(node.Elements)}
+	case *HashLiteral:
+		hash := &HashLiteral{Token: node.Token, Pairs: make(map[Expression]Expression, len(node.Pairs))}
+		for _, key := range node.Keys {
+			newKey := copyExpression(key)
+			hash.Keys = append(hash.Keys, newKey)
+			hash.Pairs[newKey] = copyExpression(node.Pairs[key])
+		}
+		return hash
+	case *InterpolatedString:
+		return &InterpolatedString{Token: node.Token, Parts: copyExpressions(node.Parts)}
+	case *IndexExpression:
+		return &IndexExpression{Token: node.Token, Left: copyExpression(node.Left), Index: copyExpression(node.Index)}
+	case *WithExpression:
+		return &WithExpression{Token: node.Token, Left: copyExpression(node.Left), Fields: copyIdentifiers(node.Fields), Values: copyExpressions(node.Values)}
+	case *MemberExpression:
+		return &MemberExpression{Token: node.Token, Object: copyExpression(node.Object), Property: copyIdentifier(node.Property)}
+	}
+
+	return node
+}
+
+func copyExpression(exp Expression) Expression {
+	if exp == nil {
+		return nil
+	}
+	copied, _ := Copy(exp).(Expression)
+	return copied
+}
+
+func copyExpressions(exps []Expression) []Expression {
+	if exps == nil {
+		return nil
+	}
+
+	copied := make([]Expression, len(exps))
+	for i, exp := range exps {
+		copied[i] = copyExpression(exp)
+	}
+	return copied
+}
+
+func copyStatements(stmts []Statement) []Statement {
+	if stmts == nil {
+		return nil
+	}
+
+	copied := make([]Statement, len(stmts))
+	for i, stmt := range stmts {
+		copied[i], _ = Copy(stmt).(Statement)
+	}
+	return copied
+}
+
+func copyIdentifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	copied := *ident
+	return &copied
+}
+
+func copyIdentifiers(idents []*Identifier) []*Identifier {
+	if idents == nil {
+		return nil
+	}
+
+	copied := make([]*Identifier, len(idents))
+	for i, ident := range idents {
+		copied[i] = copyIdentifier(ident)
+	}
+	return copied
+}
+
+func copyBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	return &BlockStatement{Token: block.Token, Statements: copyStatements(block.Statements), Rbrace: block.Rbrace}
+}
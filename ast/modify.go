@@ -0,0 +1,170 @@
+package ast
+
+type ModifierFunc func(Node) Node
+
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+	case *Program:
+		for i, s := range node.Statements {
+			node.Statements[i], _ = Modify(s, modifier).(Statement)
+		}
+	case *LetStatement:
+		if node.Name != nil {
+			node.Name = modifyIdentifier(node.Name, modifier)
+		} else if node.Pattern != nil {
+			node.Pattern, _ = Modify(node.Pattern, modifier).(Expression)
+		}
+		if node.Value != nil {
+			node.Value, _ = Modify(node.Value, modifier).(Expression)
+		}
+	case *ReturnStatement:
+		if node.ReturnValue != nil {
+			node.ReturnValue, _ = Modify(node.ReturnValue, modifier).(Expression)
+		}
+	case *ExpressionStatement:
+		if node.Expression != nil {
+			node.Expression, _ = Modify(node.Expression, modifier).(Expression)
+		}
+	case *ImportStatement:
+		if node.Name != nil {
+			node.Name = modifyIdentifier(node.Name, modifier)
+		}
+		if path, ok := Modify(node.Path, modifier).(*StringLiteral); ok {
+			node.Path = path
+		}
+	case *ExportStatement:
+		if stmt, ok := Modify(node.Statement, modifier).(Statement); ok {
+			node.Statement = stmt
+		}
+	case *YieldStatement:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *ForStatement:
+		node.Variable = modifyIdentifier(node.Variable, modifier)
+		node.Iterable, _ = Modify(node.Iterable, modifier).(Expression)
+		node.Body = modifyBlock(node.Body, modifier)
+	case *BlockStatement:
+		for i, s := range node.Statements {
+			node.Statements[i], _ = Modify(s, modifier).(Statement)
+		}
+	case *PrefixExpression:
+		node.Right, _ = Modify(node.Right, modifier).(Expression)
+	case *SpawnExpression:
+		node.Call, _ = Modify(node.Call, modifier).(Expression)
+	case *SpreadExpression:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *ArrayPattern:
+		for i, el := range node.Elements {
+			node.Elements[i], _ = Modify(el, modifier).(Expression)
+		}
+		if node.Rest != nil {
+			node.Rest = modifyIdentifier(node.Rest, modifier)
+		}
+	case *HashPattern:
+		for i, key := range node.Keys {
+			node.Keys[i] = modifyIdentifier(key, modifier)
+			node.Values[i], _ = Modify(node.Values[i], modifier).(Expression)
+		}
+	case *TypePattern:
+		if node.Name != nil {
+			node.Name = modifyIdentifier(node.Name, modifier)
+		}
+	case *InfixExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		node.Right, _ = Modify(node.Right, modifier).(Expression)
+	case *IfExpression:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Consequence = modifyBlock(node.Consequence, modifier)
+		if node.Alternative != nil {
+			node.Alternative = modifyBlock(node.Alternative, modifier)
+		}
+	case *MatchExpression:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+		for i, arm := range node.Arms {
+			if modified, ok := Modify(arm, modifier).(*MatchArm); ok {
+				node.Arms[i] = modified
+			}
+		}
+	case *MatchArm:
+		node.Pattern, _ = Modify(node.Pattern, modifier).(Expression)
+		if node.Guard != nil {
+			node.Guard, _ = Modify(node.Guard, modifier).(Expression)
+		}
+		node.Body, _ = Modify(node.Body, modifier).(Expression)
+	case *FunctionLiteral:
+		for i, p := range node.Parameters {
+			node.Parameters[i] = modifyIdentifier(p, modifier)
+		}
+		for i, pattern := range node.Patterns {
+			if pattern != nil {
+				node.Patterns[i], _ = Modify(pattern, modifier).(Expression)
+			}
+		}
+		for i, d := range node.Defaults {
+			if d != nil {
+				node.Defaults[i], _ = Modify(d, modifier).(Expression)
+			}
+		}
+		node.Body = modifyBlock(node.Body, modifier)
+	case *MacroLiteral:
+		for i, p := range node.Parameters {
+			node.Parameters[i] = modifyIdentifier(p, modifier)
+		}
+		node.Body = modifyBlock(node.Body, modifier)
+	case *CallExpression:
+		node.Function, _ = Modify(node.Function, modifier).(Expression)
+		for i, a := range node.Arguments {
+			node.Arguments[i], _ = Modify(a, modifier).(Expression)
+		}
+	case *ArrayLiteral:
+		for i, el := range node.Elements {
+			node.Elements[i], _ = Modify(el, modifier).(Expression)
+		}
+	case *HashLiteral:
+		pairs := make(map[Expression]Expression, len(node.Pairs))
+		for i, key := range node.Keys {
+			value := node.Pairs[key]
+			newKey, _ := Modify(key, modifier).(Expression)
+			newValue, _ := Modify(value, modifier).(Expression)
+			node.Keys[i] = newKey
+			pairs[newKey] = newValue
+		}
+		node.Pairs = pairs
+	case *InterpolatedString:
+		for i, part := range node.Parts {
+			node.Parts[i], _ = Modify(part, modifier).(Expression)
+		}
+	case *IndexExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		node.Index, _ = Modify(node.Index, modifier).(Expression)
+	case *StructStatement:
+		for i, method := range node.Methods {
+			if fl, ok := Modify(method, modifier).(*FunctionLiteral); ok {
+				node.Methods[i] = fl
+			}
+		}
+	case *WithExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		for i, value := range node.Values {
+			node.Values[i], _ = Modify(value, modifier).(Expression)
+		}
+	case *MemberExpression:
+		node.Object, _ = Modify(node.Object, modifier).(Expression)
+		node.Property = modifyIdentifier(node.Property, modifier)
+	}
+
+	return modifier(node)
+}
+
+func modifyIdentifier(ident *Identifier, modifier ModifierFunc) *Identifier {
+	if modified, ok := Modify(ident, modifier).(*Identifier); ok {
+		return modified
+	}
+	return ident
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if modified, ok := Modify(block, modifier).(*BlockStatement); ok {
+		return modified
+	}
+	return block
+}
@@ -0,0 +1,260 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Value: 2} }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok {
+			return node
+		}
+
+		if integer.Value != 1 {
+			return node
+		}
+
+		integer.Value = 2
+		return integer
+	}
+
+	tests := []struct {
+		testName string
+		input    Node
+		expected Node
+	}{
+		{"integer", one(), two()},
+		{
+			"program",
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+		},
+		{
+			"infix",
+			&InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			"infix swapped",
+			&InfixExpression{Left: two(), Operator: "+", Right: one()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			"prefix",
+			&PrefixExpression{Operator: "-", Right: one()},
+			&PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			"index",
+			&IndexExpression{Left: one(), Index: one()},
+			&IndexExpression{Left: two(), Index: two()},
+		},
+		{
+			"if",
+			&IfExpression{
+				Condition:   one(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+				Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&IfExpression{
+				Condition:   two(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+				Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{
+			"return",
+			&ReturnStatement{ReturnValue: one()},
+			&ReturnStatement{ReturnValue: two()},
+		},
+		{
+			"let",
+			&LetStatement{Name: &Identifier{Value: "x"}, Value: one()},
+			&LetStatement{Name: &Identifier{Value: "x"}, Value: two()},
+		},
+		{
+			"export",
+			&ExportStatement{Statement: &LetStatement{Name: &Identifier{Value: "x"}, Value: one()}},
+			&ExportStatement{Statement: &LetStatement{Name: &Identifier{Value: "x"}, Value: two()}},
+		},
+		{
+			"function",
+			&FunctionLiteral{
+				Parameters: []*Identifier{},
+				Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&FunctionLiteral{
+				Parameters: []*Identifier{},
+				Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{
+			"call",
+			&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{one(), one()}},
+			&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{two(), two()}},
+		},
+		{
+			"array",
+			&ArrayLiteral{Elements: []Expression{one(), one()}},
+			&ArrayLiteral{Elements: []Expression{two(), two()}},
+		},
+		{
+			"member",
+			&MemberExpression{Object: one(), Property: &Identifier{Value: "x"}},
+			&MemberExpression{Object: two(), Property: &Identifier{Value: "x"}},
+		},
+		{
+			"match",
+			&MatchExpression{Value: one(), Arms: []*MatchArm{{Pattern: &ArrayPattern{Elements: []Expression{one()}}, Guard: one(), Body: one()}}},
+			&MatchExpression{Value: two(), Arms: []*MatchArm{{Pattern: &ArrayPattern{Elements: []Expression{two()}}, Guard: two(), Body: two()}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			modified := Modify(tt.input, turnOneIntoTwo)
+
+			if !reflect.DeepEqual(modified, tt.expected) {
+				t.Errorf("not equal. got=%#v, want=%#v", modified, tt.expected)
+			}
+		})
+	}
+}
+
+func TestModifyPatterns(t *testing.T) {
+	ident := func(name string) *Identifier { return &Identifier{Value: name} }
+	pattern := func(a, b, c string) Expression {
+		return &ArrayPattern{
+			Elements: []Expression{ident(a), &HashPattern{Keys: []*Identifier{ident("k")}, Values: []Expression{ident(b)}}},
+			Rest:     ident(c),
+		}
+	}
+
+	renameX := func(node Node) Node {
+		if id, ok := node.(*Identifier); ok && id.Value == "x" {
+			return ident("y")
+		}
+		return node
+	}
+
+	tests := []struct {
+		testName string
+		input    Node
+		expected Node
+	}{
+		{
+			"let",
+			&LetStatement{Pattern: pattern("x", "x", "x"), Value: ident("x")},
+			&LetStatement{Pattern: pattern("y", "y", "y"), Value: ident("y")},
+		},
+		{
+			"type pattern",
+			&MatchArm{Pattern: &TypePattern{Name: ident("x"), Type: "int"}, Body: ident("x")},
+			&MatchArm{Pattern: &TypePattern{Name: ident("y"), Type: "int"}, Body: ident("y")},
+		},
+		{
+			"parameter",
+			&FunctionLiteral{
+				Parameters: []*Identifier{ident("p")},
+				Patterns:   []Expression{pattern("x", "a", "x")},
+				Body:       &BlockStatement{Statements: []Statement{}},
+			},
+			&FunctionLiteral{
+				Parameters: []*Identifier{ident("p")},
+				Patterns:   []Expression{pattern("y", "a", "y")},
+				Body:       &BlockStatement{Statements: []Statement{}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			modified := Modify(tt.input, renameX)
+
+			if !reflect.DeepEqual(modified, tt.expected) {
+				t.Errorf("not equal. got=%s, want=%s", modified, tt.expected)
+			}
+		})
+	}
+}
+
+func TestModifyHashLiteral(t *testing.T) {
+	one := &IntegerLiteral{Value: 1}
+	three := &IntegerLiteral{Value: 3}
+	hash := &HashLiteral{
+		Keys:  []Expression{one, three},
+		Pairs: map[Expression]Expression{one: &IntegerLiteral{Value: 1}, three: &IntegerLiteral{Value: 1}},
+	}
+
+	replaceOne := func(node Node) Node {
+		if integer, ok := node.(*IntegerLiteral); ok && integer.Value == 1 {
+			return &IntegerLiteral{Value: 2}
+		}
+		return node
+	}
+
+	Modify(hash, replaceOne)
+
+	if len(hash.Keys) != 2 || len(hash.Pairs) != 2 {
+		t.Fatalf("wrong number of pairs. keys=%d, pairs=%d", len(hash.Keys), len(hash.Pairs))
+	}
+
+	expectedKeys := []int64{2, 3}
+	for i, key := range hash.Keys {
+		if key.(*IntegerLiteral).Value != expectedKeys[i] {
+			t.Errorf("key %d wrong. want=%d, got=%d", i, expectedKeys[i], key.(*IntegerLiteral).Value)
+		}
+
+		value, ok := hash.Pairs[key]
+		if !ok {
+			t.Fatalf("key %d missing from Pairs", i)
+		}
+		if value.(*IntegerLiteral).Value != 2 {
+			t.Errorf("value %d wrong. want=2, got=%d", i, value.(*IntegerLiteral).Value)
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	key := &StringLiteral{Value: "k"}
+	original := &Program{Statements: []Statement{
+		&LetStatement{Name: &Identifier{Value: "f"}, Value: &FunctionLiteral{
+			Parameters: []*Identifier{{Value: "x"}},
+			Body: &BlockStatement{Statements: []Statement{
+				&ExpressionStatement{Expression: &IfExpression{
+					Condition:   &Identifier{Value: "x"},
+					Consequence: &BlockStatement{Statements: []Statement{&ReturnStatement{ReturnValue: &IntegerLiteral{Value: 1}}}},
+				}},
+			}},
+		}},
+		&ExpressionStatement{Expression: &HashLiteral{
+			Keys:  []Expression{key},
+			Pairs: map[Expression]Expression{key: &IntegerLiteral{Value: 1}},
+		}},
+	}}
+
+	copied := Copy(original)
+
+	if copied == Node(original) || copied.String() != original.String() {
+		t.Fatalf("copy differs from original.\ngot= %q\nwant=%q", copied.String(), original.String())
+	}
+
+	Modify(copied, func(node Node) Node {
+		if integer, ok := node.(*IntegerLiteral); ok {
+			integer.Value = 2
+		}
+		return node
+	})
+
+	Inspect(original, func(node Node) bool {
+		if integer, ok := node.(*IntegerLiteral); ok && integer.Value != 1 {
+			t.Errorf("modifying the copy changed the original")
+		}
+		return true
+	})
+}
@@ -0,0 +1,91 @@
+package ast
+
+import "goscript/token"
+
+func Pos(node Node) token.Token {
+	switch node := node.(type) {
+	case *Program:
+		if len(node.Statements) > 0 {
+			return Pos(node.Statements[0])
+		}
+	case *LetStatement:
+		return node.Token
+	case *ReturnStatement:
+		return node.Token
+	case *ExpressionStatement:
+		if node.Expression != nil {
+			return Pos(node.Expression)
+		}
+		return node.Token
+	case *ImportStatement:
+		return node.Token
+	case *ExportStatement:
+		return node.Token
+	case *YieldStatement:
+		return node.Token
+	case *ForStatement:
+		return node.Token
+	case *StructStatement:
+		return node.Token
+	case *BlockStatement:
+		return node.Token
+	case *Identifier:
+		return node.Token
+	case *IntegerLiteral:
+		return node.Token
+	case *StringLiteral:
+		return node.Token
+	case *InterpolatedString:
+		return node.Token
+	case *Boolean:
+		return node.Token
+	case *PrefixExpression:
+		return node.Token
+	case *SpawnExpression:
+		return node.Token
+	case *SpreadExpression:
+		return node.Token
+	case *ArrayPattern:
+		return node.Token
+	case *HashPattern:
+		return node.Token
+	case *TypePattern:
+		return node.Token
+	case *InfixExpression:
+		return Pos(node.Left)
+	case *IfExpression:
+		return node.Token
+	case *MatchExpression:
+		return node.Token
+	case *MatchArm:
+		return Pos(node.Pattern)
+	case *FunctionLiteral:
+		return node.Token
+	case *MacroLiteral:
+		return node.Token
+	case *CallExpression:
+		return Pos(node.Function)
+	case *ArrayLiteral:
+		return node.Token
+	case *HashLiteral:
+		return node.Token
+	case *IndexExpression:
+		return Pos(node.Left)
+	case *WithExpression:
+		return Pos(node.Left)
+	case *MemberExpression:
+		return Pos(node.Object)
+	case *NamedType:
+		return node.Token
+	case *ArrayType:
+		return node.Token
+	case *HashType:
+		return node.Token
+	case *FunctionType:
+		return node.Token
+	case *Comment:
+		return node.Token
+	}
+
+	return token.Token{}
+}
@@ -0,0 +1,185 @@
+package ast
+
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for _, s := range node.Statements {
+			Walk(v, s)
+		}
+	case *LetStatement:
+		if node.Name != nil {
+			Walk(v, node.Name)
+		} else {
+			Walk(v, node.Pattern)
+		}
+		if node.Type != nil {
+			Walk(v, node.Type)
+		}
+		if node.Value != nil {
+			Walk(v, node.Value)
+		}
+	case *ReturnStatement:
+		if node.ReturnValue != nil {
+			Walk(v, node.ReturnValue)
+		}
+	case *ExpressionStatement:
+		if node.Expression != nil {
+			Walk(v, node.Expression)
+		}
+	case *ImportStatement:
+		if node.Name != nil {
+			Walk(v, node.Name)
+		}
+		Walk(v, node.Path)
+	case *ExportStatement:
+		Walk(v, node.Statement)
+	case *YieldStatement:
+		Walk(v, node.Value)
+	case *ForStatement:
+		Walk(v, node.Variable)
+		Walk(v, node.Iterable)
+		Walk(v, node.Body)
+	case *StructStatement:
+		Walk(v, node.Name)
+		for _, field := range node.Fields {
+			Walk(v, field)
+		}
+		for _, method := range node.Methods {
+			Walk(v, method)
+		}
+	case *BlockStatement:
+		for _, s := range node.Statements {
+			Walk(v, s)
+		}
+	case *PrefixExpression:
+		Walk(v, node.Right)
+	case *SpawnExpression:
+		Walk(v, node.Call)
+	case *SpreadExpression:
+		Walk(v, node.Value)
+	case *ArrayPattern:
+		for _, el := range node.Elements {
+			Walk(v, el)
+		}
+		if node.Rest != nil {
+			Walk(v, node.Rest)
+		}
+	case *HashPattern:
+		for i, key := range node.Keys {
+			Walk(v, key)
+			Walk(v, node.Values[i])
+		}
+	case *InfixExpression:
+		Walk(v, node.Left)
+		Walk(v, node.Right)
+	case *IfExpression:
+		Walk(v, node.Condition)
+		Walk(v, node.Consequence)
+		if node.Alternative != nil {
+			Walk(v, node.Alternative)
+		}
+	case *TypePattern:
+		if node.Name != nil {
+			Walk(v, node.Name)
+		}
+	case *MatchExpression:
+		Walk(v, node.Value)
+		for _, arm := range node.Arms {
+			Walk(v, arm)
+		}
+	case *MatchArm:
+		Walk(v, node.Pattern)
+		if node.Guard != nil {
+			Walk(v, node.Guard)
+		}
+		Walk(v, node.Body)
+	case *FunctionLiteral:
+		for i, p := range node.Parameters {
+			if pattern := node.ParameterPattern(i); pattern != nil {
+				Walk(v, pattern)
+			} else {
+				Walk(v, p)
+			}
+			if t := node.ParameterType(i); t != nil {
+				Walk(v, t)
+			}
+			if d := node.ParameterDefault(i); d != nil {
+				Walk(v, d)
+			}
+		}
+		if node.ReturnType != nil {
+			Walk(v, node.ReturnType)
+		}
+		Walk(v, node.Body)
+	case *MacroLiteral:
+		for _, p := range node.Parameters {
+			Walk(v, p)
+		}
+		Walk(v, node.Body)
+	case *CallExpression:
+		Walk(v, node.Function)
+		for _, a := range node.Arguments {
+			Walk(v, a)
+		}
+	case *ArrayLiteral:
+		for _, el := range node.Elements {
+			Walk(v, el)
+		}
+	case *HashLiteral:
+		for _, key := range node.Keys {
+			Walk(v, key)
+			Walk(v, node.Pairs[key])
+		}
+	case *InterpolatedString:
+		for _, part := range node.Parts {
+			Walk(v, part)
+		}
+	case *IndexExpression:
+		Walk(v, node.Left)
+		Walk(v, node.Index)
+	case *WithExpression:
+		Walk(v, node.Left)
+		for i, field := range node.Fields {
+			Walk(v, field)
+			Walk(v, node.Values[i])
+		}
+	case *MemberExpression:
+		Walk(v, node.Object)
+		Walk(v, node.Property)
+	case *ArrayType:
+		Walk(v, node.Element)
+	case *HashType:
+		Walk(v, node.Key)
+		Walk(v, node.Value)
+	case *FunctionType:
+		for _, p := range node.Parameters {
+			Walk(v, p)
+		}
+		if node.Return != nil {
+			Walk(v, node.Return)
+		}
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
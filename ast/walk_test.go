@@ -0,0 +1,135 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func describe(node Node) string {
+	switch node := node.(type) {
+	case *Identifier:
+		return "Identifier " + node.Value
+	case *IntegerLiteral:
+		return fmt.Sprintf("IntegerLiteral %d", node.Value)
+	case *StringLiteral:
+		return "StringLiteral " + node.Value
+	default:
+		return reflect.TypeOf(node).Elem().Name()
+	}
+}
+
+func TestInspect(t *testing.T) {
+	key := &StringLiteral{Value: "k"}
+	program := &Program{Statements: []Statement{
+		&ImportStatement{Name: &Identifier{Value: "lib"}, Path: &StringLiteral{Value: "lib"}},
+		&ExportStatement{Statement: &LetStatement{
+			Name: &Identifier{Value: "f"},
+			Value: &FunctionLiteral{
+				Parameters: []*Identifier{{Value: "a"}},
+				Body: &BlockStatement{Statements: []Statement{
+					&ReturnStatement{ReturnValue: &InfixExpression{
+						Left:     &PrefixExpression{Operator: "-", Right: &Identifier{Value: "a"}},
+						Operator: "+",
+						Right:    &IntegerLiteral{Value: 1},
+					}},
+				}},
+			},
+		}},
+		&ExpressionStatement{Expression: &IfExpression{
+			Condition:   &Boolean{Value: true},
+			Consequence: &BlockStatement{Statements: []Statement{}},
+			Alternative: &BlockStatement{Statements: []Statement{
+				&ExpressionStatement{Expression: &CallExpression{
+					Function: &MemberExpression{Object: &Identifier{Value: "lib"}, Property: &Identifier{Value: "g"}},
+					Arguments: []Expression{
+						&ArrayLiteral{Elements: []Expression{&IntegerLiteral{Value: 2}}},
+						&IndexExpression{
+							Left:  &HashLiteral{Keys: []Expression{key}, Pairs: map[Expression]Expression{key: &IntegerLiteral{Value: 3}}},
+							Index: &StringLiteral{Value: "k"},
+						},
+					},
+				}},
+			}},
+		}},
+	}}
+
+	expected := []string{
+		"Program",
+		"ImportStatement", "Identifier lib", "StringLiteral lib",
+		"ExportStatement", "LetStatement", "Identifier f",
+		"FunctionLiteral", "Identifier a", "BlockStatement",
+		"ReturnStatement", "InfixExpression", "PrefixExpression", "Identifier a", "IntegerLiteral 1",
+		"ExpressionStatement", "IfExpression", "Boolean", "BlockStatement", "BlockStatement",
+		"ExpressionStatement", "CallExpression", "MemberExpression", "Identifier lib", "Identifier g",
+		"ArrayLiteral", "IntegerLiteral 2",
+		"IndexExpression", "HashLiteral", "StringLiteral k", "IntegerLiteral 3", "StringLiteral k",
+	}
+
+	got := []string{}
+	Inspect(program, func(node Node) bool {
+		if node != nil {
+			got = append(got, describe(node))
+		}
+		return true
+	})
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong traversal order.\nwant=%q\ngot= %q", expected, got)
+	}
+}
+
+func TestInspectPrune(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&LetStatement{Name: &Identifier{Value: "x"}, Value: &FunctionLiteral{
+			Parameters: []*Identifier{{Value: "y"}},
+			Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: &Identifier{Value: "y"}}}},
+		}},
+		&ExpressionStatement{Expression: &Identifier{Value: "x"}},
+	}}
+
+	idents := []string{}
+	Inspect(program, func(node Node) bool {
+		if _, ok := node.(*FunctionLiteral); ok {
+			return false
+		}
+		if ident, ok := node.(*Identifier); ok {
+			idents = append(idents, ident.Value)
+		}
+		return true
+	})
+
+	if !reflect.DeepEqual(idents, []string{"x", "x"}) {
+		t.Errorf("wrong identifiers. got=%q", idents)
+	}
+}
+
+type depthVisitor struct {
+	depth    int
+	maxDepth *int
+}
+
+func (v depthVisitor) Visit(node Node) Visitor {
+	if node == nil {
+		return nil
+	}
+	if v.depth > *v.maxDepth {
+		*v.maxDepth = v.depth
+	}
+	return depthVisitor{depth: v.depth + 1, maxDepth: v.maxDepth}
+}
+
+func TestWalk(t *testing.T) {
+	node := &ExpressionStatement{Expression: &InfixExpression{
+		Left:     &IntegerLiteral{Value: 1},
+		Operator: "*",
+		Right:    &PrefixExpression{Operator: "-", Right: &IntegerLiteral{Value: 2}},
+	}}
+
+	maxDepth := 0
+	Walk(depthVisitor{maxDepth: &maxDepth}, node)
+
+	if maxDepth != 3 {
+		t.Errorf("wrong depth. want=3, got=%d", maxDepth)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"goscript/check"
+	"os"
+)
+
+func checkMain(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: goscript check files...")
+		return 2
+	}
+
+	status := 0
+	for _, file := range flags.Args() {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 2
+			continue
+		}
+
+		for _, d := range check.Source(src) {
+			fmt.Printf("%s:%s\n", file, d)
+			if status == 0 {
+				status = 1
+			}
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,647 @@
+package check
+
+import (
+	"fmt"
+	"goscript/ast"
+	"goscript/lexer"
+	"goscript/lint"
+	"goscript/parser"
+	"sort"
+)
+
+type Diagnostic struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s", d.Line, d.Column, d.Message)
+}
+
+type scope struct {
+	outer *scope
+	vars  map[string]Type
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{outer: outer, vars: make(map[string]Type)}
+}
+
+func (s *scope) lookup(name string) Type {
+	for ; s != nil; s = s.outer {
+		if t, ok := s.vars[name]; ok {
+			return t
+		}
+	}
+	return Any
+}
+
+// The declared type of a generator is the type of the values it yields.
+type function struct {
+	declared  Type
+	generator bool
+	returns   []Type
+	yields    []Type
+}
+
+type checker struct {
+	diags     []Diagnostic
+	functions []*function
+	types     map[ast.TypeExpression]Type
+	structs   map[string]*Instance
+}
+
+func Source(src []byte) []Diagnostic {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+
+	if errs := p.ErrorList(); len(errs) != 0 {
+		diags := make([]Diagnostic, len(errs))
+		for i, err := range errs {
+			diags[i] = Diagnostic{Message: err.Message, Line: err.Line, Column: err.Column}
+		}
+		return diags
+	}
+
+	return Program(program)
+}
+
+// Program infers the types of the program's expressions and reports the
+// operations that would fail at runtime. Unannotated parameters and values
+// of unknown type are any, which is compatible with everything, so only
+// mismatches between known types are reported.
+func Program(program *ast.Program) []Diagnostic {
+	c := &checker{types: make(map[ast.TypeExpression]Type), structs: make(map[string]*Instance)}
+
+	// A struct can be named in annotations before it is declared.
+	ast.Inspect(program, func(node ast.Node) bool {
+		if ss, ok := node.(*ast.StructStatement); ok {
+			c.structs[ss.Name.Value] = &Instance{Name: ss.Name.Value}
+		}
+		return true
+	})
+
+	s := newScope(nil)
+	for _, stmt := range program.Statements {
+		c.statement(stmt, s)
+	}
+
+	sort.SliceStable(c.diags, func(i, j int) bool {
+		if c.diags[i].Line != c.diags[j].Line {
+			return c.diags[i].Line < c.diags[j].Line
+		}
+		return c.diags[i].Column < c.diags[j].Column
+	})
+	return c.diags
+}
+
+func (c *checker) errorf(node ast.Node, format string, a ...interface{}) {
+	pos := ast.Pos(node)
+	c.diags = append(c.diags, Diagnostic{Message: fmt.Sprintf(format, a...), Line: pos.Line, Column: pos.Column})
+}
+
+func (c *checker) statement(stmt ast.Statement, s *scope) Type {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.let(stmt, s)
+		return Null
+	case *ast.ExportStatement:
+		return c.statement(stmt.Statement, s)
+	case *ast.ImportStatement:
+		s.vars[lint.ImportName(stmt).Value] = Any
+		return Null
+	case *ast.ReturnStatement:
+		t := c.expression(stmt.ReturnValue, s)
+		if len(c.functions) > 0 {
+			fn := c.functions[len(c.functions)-1]
+			if fn.declared != nil && !fn.generator && !assignable(t, fn.declared) {
+				c.errorf(stmt.ReturnValue, "cannot return %s from function returning %s", t, fn.declared)
+			}
+			fn.returns = append(fn.returns, t)
+		}
+		return t
+	case *ast.YieldStatement:
+		t := c.expression(stmt.Value, s)
+		if len(c.functions) > 0 {
+			fn := c.functions[len(c.functions)-1]
+			if fn.declared != nil && !assignable(t, fn.declared) {
+				c.errorf(stmt.Value, "cannot yield %s from generator of %s", t, fn.declared)
+			}
+			fn.yields = append(fn.yields, t)
+		}
+		return Null
+	case *ast.ForStatement:
+		c.forStatement(stmt, s)
+		return Null
+	case *ast.StructStatement:
+		c.structStatement(stmt, s)
+		return Null
+	case *ast.ExpressionStatement:
+		return c.expression(stmt.Expression, s)
+	}
+	return Any
+}
+
+func (c *checker) let(stmt *ast.LetStatement, s *scope) {
+	if stmt.Pattern != nil {
+		c.destructure(stmt.Pattern, c.expression(stmt.Value, s), s)
+		return
+	}
+
+	var declared Type
+	if stmt.Type != nil {
+		declared = c.typeOf(stmt.Type)
+	}
+
+	// Binding the signature first lets recursive calls be checked.
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok && declared == nil {
+		s.vars[stmt.Name.Value] = c.signature(fl)
+	} else if declared != nil {
+		s.vars[stmt.Name.Value] = declared
+	}
+
+	t := c.expression(stmt.Value, s)
+
+	if declared == nil {
+		s.vars[stmt.Name.Value] = t
+		return
+	}
+	if !assignable(t, declared) {
+		c.errorf(stmt.Value, "cannot use %s as %s in let %s", t, declared, stmt.Name.Value)
+	}
+}
+
+// structStatement binds the name of a struct to its constructor, which takes
+// a value of any type for each field and returns an instance of the struct,
+// and checks the methods with self bound to such an instance.
+func (c *checker) structStatement(stmt *ast.StructStatement, s *scope) {
+	inst := c.structs[stmt.Name.Value]
+	constructor := &Func{Params: make([]Type, len(stmt.Fields)), Return: inst}
+	for i := range stmt.Fields {
+		constructor.Params[i] = Any
+	}
+	s.vars[stmt.Name.Value] = constructor
+
+	for _, method := range stmt.Methods {
+		self := newScope(s)
+		self.vars["self"] = inst
+		c.function(method, self)
+	}
+}
+
+// destructure binds the names of a pattern to the types of the parts of t
+// they take, reporting a value that cannot have the pattern's shape.
+func (c *checker) destructure(pattern ast.Expression, t Type, s *scope) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		s.vars[pattern.Value] = t
+	case *ast.ArrayPattern:
+		element := Any
+		if a, ok := t.(*Array); ok {
+			element = a.Element
+		} else if t != Any {
+			c.errorf(pattern, "cannot destructure %s into %s", t, pattern)
+		}
+		for _, el := range pattern.Elements {
+			c.destructure(el, element, s)
+		}
+		if pattern.Rest != nil {
+			s.vars[pattern.Rest.Value] = &Array{Element: element}
+		}
+	case *ast.HashPattern:
+		value := Any
+		if h, ok := t.(*Hash); ok {
+			if !assignable(String, h.Key) {
+				c.errorf(pattern, "cannot destructure %s into %s", t, pattern)
+			}
+			value = h.Value
+		} else if t != Any {
+			c.errorf(pattern, "cannot destructure %s into %s", t, pattern)
+		}
+		for _, v := range pattern.Values {
+			c.destructure(v, value, s)
+		}
+	}
+}
+
+// match types each arm in a scope of its own and joins the types of the arm
+// bodies. An arm whose pattern cannot match the value is not reported: the
+// value may still come from code that is not checked.
+func (c *checker) match(me *ast.MatchExpression, s *scope) Type {
+	value := c.expression(me.Value, s)
+
+	var t Type
+	for _, arm := range me.Arms {
+		inner := newScope(s)
+		c.matchPattern(arm.Pattern, value, inner)
+		if arm.Guard != nil {
+			c.expression(arm.Guard, inner)
+		}
+
+		body := c.expression(arm.Body, inner)
+		if t == nil {
+			t = body
+		} else {
+			t = join(t, body)
+		}
+	}
+
+	if t == nil {
+		return Any
+	}
+	return t
+}
+
+// matchPattern binds the names of a match pattern to the types of the parts
+// of t they take, or to any where t does not have the pattern's shape.
+func (c *checker) matchPattern(pattern ast.Expression, t Type, s *scope) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		s.vars[pattern.Value] = t
+	case *ast.TypePattern:
+		if pattern.Name != nil {
+			s.vars[pattern.Name.Value] = c.patternType(pattern.Type)
+		}
+	case *ast.ArrayPattern:
+		element := Any
+		if a, ok := t.(*Array); ok {
+			element = a.Element
+		}
+		for _, el := range pattern.Elements {
+			c.matchPattern(el, element, s)
+		}
+		if pattern.Rest != nil {
+			s.vars[pattern.Rest.Value] = &Array{Element: element}
+		}
+	case *ast.HashPattern:
+		value := Any
+		if h, ok := t.(*Hash); ok {
+			value = h.Value
+		}
+		for _, v := range pattern.Values {
+			c.matchPattern(v, value, s)
+		}
+	}
+}
+
+// The variable of a loop over an array has the array's element type; other
+// iterables are only known at runtime.
+func (c *checker) forStatement(stmt *ast.ForStatement, s *scope) {
+	var elem Type = Any
+	switch t := c.expression(stmt.Iterable, s).(type) {
+	case *Array:
+		elem = t.Element
+	case *Iterator:
+		elem = t.Element
+	case *Func:
+		c.errorf(stmt.Iterable, "cannot iterate over %s", t)
+	default:
+		if t == Int || t == Bool || t == Null {
+			c.errorf(stmt.Iterable, "cannot iterate over %s", t)
+		}
+	}
+
+	s.vars[stmt.Variable.Value] = elem
+	c.block(stmt.Body, s)
+}
+
+func (c *checker) signature(fl *ast.FunctionLiteral) *Func {
+	f := &Func{Params: make([]Type, len(fl.Parameters)), Return: c.typeOf(fl.ReturnType), Rest: fl.Rest}
+	if fl.Generator {
+		f.Return = &Iterator{Element: f.Return}
+	}
+	for i := range fl.Parameters {
+		f.Params[i] = c.parameterType(fl, i)
+		if fl.ParameterDefault(i) != nil {
+			f.Optional++
+		}
+	}
+	return f
+}
+
+// parameterType returns the annotated type of a parameter. A rest parameter
+// is always an array, whatever its annotation says.
+func (c *checker) parameterType(fl *ast.FunctionLiteral, i int) Type {
+	t := c.typeOf(fl.ParameterType(i))
+	if !fl.Rest || i != len(fl.Parameters)-1 {
+		return t
+	}
+
+	if _, ok := t.(*Array); !ok {
+		return &Array{Element: Any}
+	}
+	return t
+}
+
+// block returns the type of the block's value, which is the value of its
+// last statement.
+func (c *checker) block(block *ast.BlockStatement, s *scope) Type {
+	if block == nil || len(block.Statements) == 0 {
+		return Null
+	}
+
+	inner := newScope(s)
+	var t Type = Null
+	for _, stmt := range block.Statements {
+		t = c.statement(stmt, inner)
+	}
+	return t
+}
+
+func endsWithReturn(block *ast.BlockStatement) bool {
+	if len(block.Statements) == 0 {
+		return false
+	}
+	_, ok := block.Statements[len(block.Statements)-1].(*ast.ReturnStatement)
+	return ok
+}
+
+func (c *checker) function(fl *ast.FunctionLiteral, s *scope) Type {
+	sig := &Func{Params: make([]Type, len(fl.Parameters)), Rest: fl.Rest}
+
+	inner := newScope(s)
+	for i, p := range fl.Parameters {
+		sig.Params[i] = c.parameterType(fl, i)
+		if t := c.typeOf(fl.ParameterType(i)); fl.Rest && i == len(fl.Parameters)-1 && t != Any && t != sig.Params[i] {
+			c.errorf(fl.ParameterType(i), "rest parameter %s must be an array, got %s", p.Value, t)
+		}
+		if d := fl.ParameterDefault(i); d != nil {
+			sig.Optional++
+			if t := c.expression(d, inner); !assignable(t, sig.Params[i]) {
+				c.errorf(d, "cannot use %s as %s in default value of %s", t, sig.Params[i], p.Value)
+			}
+		}
+		inner.vars[p.Value] = sig.Params[i]
+	}
+	for i := range fl.Parameters {
+		if pattern := fl.ParameterPattern(i); pattern != nil {
+			c.destructure(pattern, sig.Params[i], inner)
+		}
+	}
+
+	fn := &function{generator: fl.Generator}
+	if fl.ReturnType != nil {
+		fn.declared = c.typeOf(fl.ReturnType)
+	}
+
+	c.functions = append(c.functions, fn)
+	body := c.block(fl.Body, inner)
+	c.functions = c.functions[:len(c.functions)-1]
+
+	// A generator returns an iterator over the values it yields.
+	if fl.Generator {
+		var elem Type = Any
+		if fn.declared != nil {
+			elem = fn.declared
+		} else if len(fn.yields) > 0 {
+			elem = fn.yields[0]
+			for _, t := range fn.yields[1:] {
+				elem = join(elem, t)
+			}
+		}
+		sig.Return = &Iterator{Element: elem}
+		return sig
+	}
+
+	if !endsWithReturn(fl.Body) {
+		if fn.declared != nil && !assignable(body, fn.declared) {
+			node := ast.Node(fl.Body)
+			if len(fl.Body.Statements) > 0 {
+				node = fl.Body.Statements[len(fl.Body.Statements)-1]
+			}
+			c.errorf(node, "cannot return %s from function returning %s", body, fn.declared)
+		}
+		fn.returns = append(fn.returns, body)
+	}
+
+	if fn.declared != nil {
+		sig.Return = fn.declared
+	} else {
+		sig.Return = fn.returns[0]
+		for _, t := range fn.returns[1:] {
+			sig.Return = join(sig.Return, t)
+		}
+	}
+	return sig
+}
+
+func (c *checker) expression(exp ast.Expression, s *scope) Type {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.StringLiteral:
+		return String
+	case *ast.InterpolatedString:
+		for _, part := range exp.Parts {
+			c.expression(part, s)
+		}
+		return String
+	case *ast.Boolean:
+		return Bool
+	case *ast.Identifier:
+		return s.lookup(exp.Value)
+	case *ast.PrefixExpression:
+		return c.prefix(exp, s)
+	case *ast.SpawnExpression:
+		c.expression(exp.Call, s)
+		return Any
+	case *ast.SpreadExpression:
+		// The type of a spread is the type of the elements it spreads.
+		if t, ok := c.expression(exp.Value, s).(*Array); ok {
+			return t.Element
+		}
+		return Any
+	case *ast.InfixExpression:
+		return c.infix(exp, s)
+	case *ast.IfExpression:
+		c.expression(exp.Condition, s)
+		then := c.block(exp.Consequence, s)
+		if exp.Alternative == nil {
+			return join(then, Null)
+		}
+		return join(then, c.block(exp.Alternative, s))
+	case *ast.MatchExpression:
+		return c.match(exp, s)
+	case *ast.FunctionLiteral:
+		return c.function(exp, s)
+	case *ast.CallExpression:
+		return c.call(exp, s)
+	case *ast.ArrayLiteral:
+		var elem Type
+		for _, el := range exp.Elements {
+			t := c.expression(el, s)
+			if elem == nil {
+				elem = t
+			} else {
+				elem = join(elem, t)
+			}
+		}
+		if elem == nil {
+			elem = Any
+		}
+		return &Array{Element: elem}
+	case *ast.HashLiteral:
+		var key, value Type
+		for _, k := range exp.Keys {
+			kt, vt := c.expression(k, s), c.expression(exp.Pairs[k], s)
+			if key == nil {
+				key, value = kt, vt
+			} else {
+				key, value = join(key, kt), join(value, vt)
+			}
+		}
+		if key == nil {
+			key, value = Any, Any
+		}
+		return &Hash{Key: key, Value: value}
+	case *ast.IndexExpression:
+		return c.index(exp, s)
+	case *ast.MemberExpression:
+		c.expression(exp.Object, s)
+		return Any
+	case *ast.WithExpression:
+		t := c.expression(exp.Left, s)
+		for _, value := range exp.Values {
+			c.expression(value, s)
+		}
+		return t
+	}
+	return Any
+}
+
+func (c *checker) prefix(exp *ast.PrefixExpression, s *scope) Type {
+	right := c.expression(exp.Right, s)
+
+	switch exp.Operator {
+	case "!":
+		return Bool
+	case "-":
+		if right != Any && right != Int {
+			c.errorf(exp, "unknown operator: -%s", right)
+		}
+		return Int
+	}
+	return Any
+}
+
+func (c *checker) infix(exp *ast.InfixExpression, s *scope) Type {
+	left := c.expression(exp.Left, s)
+	right := c.expression(exp.Right, s)
+	op := exp.Operator
+
+	comparison := op == "<" || op == ">" || op == "==" || op == "!="
+
+	// An instance's operators are the protocol methods of its struct, which
+	// are only known at runtime.
+	_, leftInstance := left.(*Instance)
+	_, rightInstance := right.(*Instance)
+	if leftInstance || rightInstance {
+		if comparison {
+			return Bool
+		}
+		return Any
+	}
+
+	if left == Any || right == Any {
+		switch {
+		case comparison:
+			return Bool
+		case left != Any:
+			return left
+		default:
+			return right
+		}
+	}
+
+	switch {
+	case left == Int && right == Int:
+		if comparison {
+			return Bool
+		}
+		return Int
+	case op == "==" || op == "!=":
+		return Bool
+	case kind(left) != kind(right):
+		c.errorf(exp, "type mismatch: %s %s %s", left, op, right)
+		return Any
+	case left == String && op == "+":
+		return String
+	case (left == String || kind(left) == "array") && (op == "<" || op == ">"):
+		return Bool
+	}
+
+	c.errorf(exp, "unknown operator: %s %s %s", left, op, right)
+	return Any
+}
+
+func (c *checker) call(exp *ast.CallExpression, s *scope) Type {
+	if ident, ok := exp.Function.(*ast.Identifier); ok && (ident.Value == "quote" || ident.Value == "unquote") {
+		return Any
+	}
+
+	callee := c.expression(exp.Function, s)
+	args := make([]Type, len(exp.Arguments))
+	spread := false
+	for i, arg := range exp.Arguments {
+		args[i] = c.expression(arg, s)
+		if _, ok := arg.(*ast.SpreadExpression); ok {
+			spread = true
+		}
+	}
+
+	fn, ok := callee.(*Func)
+	if !ok {
+		if callee != Any {
+			c.errorf(exp, "not a function: %s", callee)
+		}
+		return Any
+	}
+
+	name := "function"
+	if ident, ok := exp.Function.(*ast.Identifier); ok {
+		name = ident.Value
+	}
+
+	// The number of arguments a spread passes is only known at runtime.
+	if spread {
+		return fn.Return
+	}
+
+	if want := fn.arity(len(args)); want != "" {
+		c.errorf(exp, "wrong number of arguments to %s. got=%d, want=%s", name, len(args), want)
+		return fn.Return
+	}
+	for i, arg := range args {
+		if !assignable(arg, fn.param(i)) {
+			c.errorf(exp.Arguments[i], "cannot use %s as %s in argument %d to %s", arg, fn.param(i), i+1, name)
+		}
+	}
+	return fn.Return
+}
+
+func (c *checker) index(exp *ast.IndexExpression, s *scope) Type {
+	left := c.expression(exp.Left, s)
+	index := c.expression(exp.Index, s)
+
+	switch left := left.(type) {
+	case *Array:
+		if !assignable(index, Int) {
+			c.errorf(exp.Index, "cannot index %s with %s", left, index)
+		}
+		return left.Element
+	case *Hash:
+		if !assignable(index, left.Key) {
+			c.errorf(exp.Index, "cannot index %s with %s", left, index)
+		}
+		return left.Value
+	case *Instance:
+		// An instance is indexed by the __index__ method of its struct.
+		return Any
+	}
+
+	if left != Any {
+		c.errorf(exp, "index operator not supported: %s", left)
+	}
+	return Any
+}
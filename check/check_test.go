@@ -0,0 +1,72 @@
+package check
+
+import (
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		testName string
+		input    string
+		expected []string
+	}{
+		{"unannotated code", "let f = fn(a, b) { a + b }; f(1, \"x\"); f(true);", []string{"1:40: wrong number of arguments to f. got=1, want=2"}},
+		{"let annotation", "let x: int = 5; let y: string = x;", []string{"1:33: cannot use int as string in let y"}},
+		{"inferred let", "let x = 5; let y = x + \"s\";", []string{"1:20: type mismatch: int + string"}},
+		{"array annotation", "let xs: [int] = [1, 2]; let ys: [string] = xs;", []string{"1:44: cannot use [int] as [string] in let ys"}},
+		{"hash annotation", "let h: {string: int} = {\"a\": 1}; h[1];", []string{"1:36: cannot index {string: int} with int"}},
+		{"hash value", "let h = {\"a\": 1}; let s: string = h[\"a\"];", []string{"1:35: cannot use int as string in let s"}},
+		{"array index", "let xs = [1]; xs[\"a\"]; xs[0] - \"b\";", []string{"1:18: cannot index [int] with string", "1:24: type mismatch: int - string"}},
+		{"argument types", "let add = fn(a: int, b: int): int { a + b }; add(1, \"two\");", []string{"1:53: cannot use string as int in argument 2 to add"}},
+		{"return type", "let f = fn(n: int): bool { n + 1 };", []string{"1:28: cannot return int from function returning bool"}},
+		{"return statement", "let f = fn(n: int): int { if (n > 1) { return \"big\"; } n };", []string{"1:47: cannot return string from function returning int"}},
+		{"inferred return", "let f = fn(n: int) { n * 2 }; let s: string = f(1);", []string{"1:47: cannot use int as string in let s"}},
+		{"mixed returns", "let f = fn(n) { if (n) { return 1; } \"s\" }; let s: bool = f(1);", []string{}},
+		{"recursion", "let fact = fn(n: int): int { if (n < 2) { return 1; } n * fact(\"n\") };", []string{"1:64: cannot use string as int in argument 1 to fact"}},
+		{"function types", "let add = fn(a: int, b: int): int { a + b }; let f: fn(int): int = add; let g: fn(int, int): int = add;", []string{"1:68: cannot use fn(int, int): int as fn(int): int in let f"}},
+		{"function parameter", "let apply = fn(f: fn(int): int, x: int): int { f(x) }; apply(fn(s: string): int { 1 }, 2);", []string{"1:62: cannot use fn(string): int as fn(int): int in argument 1 to apply"}},
+		{"prefix", "-\"s\"; !\"s\"; -1;", []string{"1:1: unknown operator: -string"}},
+		{"unknown operator", "\"a\" - \"b\"; \"a\" < \"b\"; [1] < [2]; true + false;", []string{"1:1: unknown operator: string - string", "1:34: unknown operator: bool + bool"}},
+		{"equality across types", "1 == \"1\"; true != [1];", []string{}},
+		{"not a function", "5(1); let s = \"x\"; s();", []string{"1:1: not a function: int", "1:20: not a function: string"}},
+		{"unknown type", "let u: foo = 1; let f = fn(a: [bar]) { a };", []string{"1:8: unknown type foo", "1:32: unknown type bar"}},
+		{"any", "let a: any = 1; let s: string = a; let xs: [any] = [1, \"x\"];", []string{}},
+		{"null", "let f = fn(x: int): null { if (x > 1) { puts(x) } }; let n: int = f(1);", []string{"1:67: cannot use null as int in let n"}},
+		{"scopes", "let x = 1; let f = fn() { let x = \"s\"; x - 1 }; x - 1;", []string{"1:40: type mismatch: string - int"}},
+		{"imports and members", "import \"lib\"; let n: int = lib.value; lib.f(\"x\") + 1;", []string{}},
+		{"for loops", "let xs: [int] = [1]; for (x in xs) { x + \"s\"; } for (c in \"ab\") { c - 1; } for (n in 5) {}", []string{"1:38: type mismatch: int + string", "1:86: cannot iterate over int"}},
+		{"generators", "let gen = fn(): int { yield \"s\"; }; let it: string = gen(); let ok: iterator = gen(); for (x in fn() { yield 1 }()) { x + \"s\" }", []string{"1:29: cannot yield string from generator of int", "1:54: cannot use iterator as string in let it", "1:119: type mismatch: int + string"}},
+		{"default values", "let f = fn(a: int, b: int = \"s\") { a }; f(); f(1); f(1, 2, 3);", []string{"1:29: cannot use string as int in default value of b", "1:41: wrong number of arguments to f. got=0, want=1 to 2", "1:52: wrong number of arguments to f. got=3, want=1 to 2"}},
+		{"rest parameters", "let f = fn(a, ...xs: [int]) { xs }; f(1, 2, \"s\"); let ys: string = f(1); let g = fn(...x: int) { x };", []string{"1:45: cannot use string as int in argument 3 to f", "1:68: cannot use [int] as string in let ys", "1:91: rest parameter x must be an array, got int"}},
+		{"spread arguments", "let f = fn(a: int) { a }; f(...[1, 2]); let xs: [string] = [...[1]];", []string{"1:60: cannot use [int] as [string] in let xs"}},
+		{"destructuring", "let [a, ...b] = [1, 2]; a + \"s\"; let c: int = b; let {d} = {\"d\": true}; -d; let [e] = 1;", []string{"1:25: type mismatch: int + string", "1:47: cannot use [int] as int in let c", "1:73: unknown operator: -bool", "1:81: cannot destructure int into [e]"}},
+		{"match", "let xs: [int] = [1]; let s: string = match (xs) { [a, ..._] => a, _ => 0 }; match (xs) { [b] => b + \"s\" };", []string{"1:38: cannot use int as string in let s", "1:97: type mismatch: int + string"}},
+		{"type patterns", "match (1) { xs: array => xs - 1, f: fn => f(1), p: P => p.x };", []string{"1:26: type mismatch: [any] - int"}},
+		{"interpolated strings", "let n: int = \"${1}\"; let s: string = \"${-true}\";", []string{"1:14: cannot use string as int in let n", "1:41: unknown operator: -bool"}},
+		{"structs", "struct P { x, fn get(n: int) { n + self.x } } P(1, 2); P(1).get(\"s\"); let q: int = P(1) with {x: 2};", []string{"1:47: wrong number of arguments to P. got=2, want=1", "1:84: cannot use P as int in let q"}},
+		{"struct types", "let f = fn(p: P): P { p }; struct P { x } struct Q { x } let p: P = P(1); let q: Q = p; f(Q(1)); p + 1; p[0]; match (p) { r: Q => r - 1 };", []string{"1:86: cannot use P as Q in let q", "1:91: cannot use Q as P in argument 1 to f"}},
+		{"exported struct", "export struct P { x } let p: P = P(1); let n: int = p;", []string{"1:53: cannot use P as int in let n"}},
+		{"quote", "quote(1 + \"s\");", []string{}},
+		{"parse error", "let x: = 1;", []string{"1:8: expected a type, got = instead"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			diags := Source([]byte(tt.input))
+
+			got := make([]string, len(diags))
+			for i, d := range diags {
+				got[i] = d.String()
+			}
+
+			if len(got) != len(tt.expected) {
+				t.Fatalf("wrong number of diagnostics. expected=%q, got=%q", tt.expected, got)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("wrong diagnostic. expected=%q, got=%q", tt.expected[i], got[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package check
+
+import (
+	"fmt"
+	"goscript/ast"
+	"strings"
+)
+
+type Type interface {
+	String() string
+}
+
+type basic string
+
+func (b basic) String() string { return string(b) }
+
+var (
+	Int    Type = basic("int")
+	String Type = basic("string")
+	Bool   Type = basic("bool")
+	Null   Type = basic("null")
+	Any    Type = basic("any")
+)
+
+var namedTypes = map[string]Type{
+	"int":      Int,
+	"string":   String,
+	"bool":     Bool,
+	"null":     Null,
+	"any":      Any,
+	"iterator": &Iterator{Element: Any},
+}
+
+type Array struct {
+	Element Type
+}
+
+func (a *Array) String() string { return "[" + a.Element.String() + "]" }
+
+type Hash struct {
+	Key   Type
+	Value Type
+}
+
+func (h *Hash) String() string { return "{" + h.Key.String() + ": " + h.Value.String() + "}" }
+
+// An Iterator is what calling a generator returns. Only its inferred type
+// knows the type of its values: an iterator annotation can have any.
+type Iterator struct {
+	Element Type
+}
+
+func (it *Iterator) String() string { return "iterator" }
+
+// An Instance is a value of the struct Name. Each struct declared in a
+// program has a single Instance type, so instances of different structs are
+// never assignable to each other.
+type Instance struct {
+	Name string
+}
+
+func (i *Instance) String() string { return i.Name }
+
+// A Func's last Optional parameters have default values, and if Rest is
+// set the last parameter is an array of the remaining arguments.
+type Func struct {
+	Params   []Type
+	Return   Type
+	Optional int
+	Rest     bool
+}
+
+func (f *Func) String() string {
+	params := make([]string, len(f.Params))
+	for i, p := range f.Params {
+		params[i] = p.String()
+		if f.Rest && i == len(f.Params)-1 {
+			params[i] = "..." + params[i]
+		} else if i >= f.fixed()-f.Optional {
+			params[i] += "?"
+		}
+	}
+	return "fn(" + strings.Join(params, ", ") + "): " + f.Return.String()
+}
+
+// fixed is the number of parameters that are not the rest parameter.
+func (f *Func) fixed() int {
+	if f.Rest {
+		return len(f.Params) - 1
+	}
+	return len(f.Params)
+}
+
+// arity describes the number of arguments f takes the way the evaluator
+// reports it, or returns "" if n is one of them.
+func (f *Func) arity(n int) string {
+	max := f.fixed()
+	min := max - f.Optional
+
+	switch {
+	case f.Rest && n < min:
+		return fmt.Sprintf("at least %d", min)
+	case f.Rest || (n >= min && n <= max):
+		return ""
+	case min == max:
+		return fmt.Sprintf("%d", min)
+	default:
+		return fmt.Sprintf("%d to %d", min, max)
+	}
+}
+
+// param returns the type of the i-th argument to f.
+func (f *Func) param(i int) Type {
+	if i < f.fixed() {
+		return f.Params[i]
+	}
+	if rest, ok := f.Params[len(f.Params)-1].(*Array); ok {
+		return rest.Element
+	}
+	return Any
+}
+
+// kind is what the evaluator compares when it looks for a type mismatch:
+// arrays of different element types are still both arrays at runtime.
+func kind(t Type) string {
+	switch t.(type) {
+	case *Array:
+		return "array"
+	case *Hash:
+		return "hash"
+	case *Func:
+		return "fn"
+	case *Iterator:
+		return "iterator"
+	default:
+		return t.String()
+	}
+}
+
+func assignable(from, to Type) bool {
+	if from == Any || to == Any {
+		return true
+	}
+
+	switch to := to.(type) {
+	case *Array:
+		from, ok := from.(*Array)
+		return ok && assignable(from.Element, to.Element)
+	case *Hash:
+		from, ok := from.(*Hash)
+		return ok && assignable(from.Key, to.Key) && assignable(from.Value, to.Value)
+	case *Iterator:
+		from, ok := from.(*Iterator)
+		return ok && assignable(from.Element, to.Element)
+	case *Func:
+		from, ok := from.(*Func)
+		if !ok || len(from.Params) != len(to.Params) || from.Rest != to.Rest {
+			return false
+		}
+		for i := range to.Params {
+			if !assignable(to.Params[i], from.Params[i]) {
+				return false
+			}
+		}
+		return assignable(from.Return, to.Return)
+	default:
+		return from == to
+	}
+}
+
+func join(a, b Type) Type {
+	if a.String() == b.String() {
+		return a
+	}
+	return Any
+}
+
+// patternType returns the type of the values a type pattern matches, which
+// is any for a function.
+func (c *checker) patternType(name string) Type {
+	switch name {
+	case "array":
+		return &Array{Element: Any}
+	case "hash":
+		return &Hash{Key: Any, Value: Any}
+	}
+	if named, ok := namedTypes[name]; ok {
+		return named
+	}
+	if inst, ok := c.structs[name]; ok {
+		return inst
+	}
+	return Any
+}
+
+// typeOf resolves an annotation once, so that an unknown type is reported
+// only once even though function signatures are resolved twice.
+func (c *checker) typeOf(t ast.TypeExpression) Type {
+	if t == nil {
+		return Any
+	}
+	if resolved, ok := c.types[t]; ok {
+		return resolved
+	}
+
+	resolved := c.resolve(t)
+	c.types[t] = resolved
+	return resolved
+}
+
+func (c *checker) resolve(t ast.TypeExpression) Type {
+	switch t := t.(type) {
+	case *ast.NamedType:
+		if named, ok := namedTypes[t.Name]; ok {
+			return named
+		}
+		if inst, ok := c.structs[t.Name]; ok {
+			return inst
+		}
+		c.errorf(t, "unknown type %s", t.Name)
+		return Any
+	case *ast.ArrayType:
+		return &Array{Element: c.typeOf(t.Element)}
+	case *ast.HashType:
+		return &Hash{Key: c.typeOf(t.Key), Value: c.typeOf(t.Value)}
+	case *ast.FunctionType:
+		f := &Func{Params: make([]Type, len(t.Parameters)), Return: c.typeOf(t.Return)}
+		for i, p := range t.Parameters {
+			f.Params[i] = c.typeOf(p)
+		}
+		return f
+	}
+	return Any
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"goscript/coverage"
+	"os"
+)
+
+func coverMain(args []string) int {
+	flags := flag.NewFlagSet("cover", flag.ExitOnError)
+	htmlFile := flags.String("html", "", "write an HTML report to `file` instead of printing to standard output")
+	color := flags.Bool("color", isTerminal(os.Stdout), "colourise the terminal report")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: goscript cover [-html file] [-color] profile.lcov")
+		return 2
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	prof, err := coverage.ReadLCOV(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", flags.Arg(0), err)
+		return 1
+	}
+
+	if *htmlFile == "" {
+		err = prof.WriteText(os.Stdout, *color)
+	} else {
+		err = writeHTMLReport(*htmlFile, prof)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func writeHTMLReport(path string, prof *coverage.Profile) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := prof.WriteHTML(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
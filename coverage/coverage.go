@@ -0,0 +1,232 @@
+package coverage
+
+import (
+	"goscript/ast"
+	"goscript/evaluator"
+	"goscript/object"
+	"sort"
+	"sync"
+)
+
+type Line struct {
+	Number int
+	Count  int64
+}
+
+type Branch struct {
+	Line   int
+	Block  int
+	Branch int
+	Taken  int64
+}
+
+type File struct {
+	Path     string
+	Lines    []Line
+	Branches []Branch
+}
+
+type Profile struct {
+	Files []*File
+}
+
+type statement struct {
+	line  int
+	count int64
+}
+
+type branches struct {
+	line  int
+	taken [2]int64
+}
+
+type file struct {
+	path       string
+	statements []*statement
+	ifs        []*branches
+}
+
+type Collector struct {
+	// Tasks run at the same time share the collector.
+	mu sync.Mutex
+
+	files      map[string]*file
+	statements map[ast.Node]*statement
+	ifs        map[*ast.IfExpression]*branches
+}
+
+func New() *Collector {
+	return &Collector{
+		files:      make(map[string]*file),
+		statements: make(map[ast.Node]*statement),
+		ifs:        make(map[*ast.IfExpression]*branches),
+	}
+}
+
+func (c *Collector) Start() {
+	evaluator.SetTracer(c)
+}
+
+func (c *Collector) Stop() *Profile {
+	evaluator.SetTracer(nil)
+	return c.Profile()
+}
+
+func (c *Collector) Profile() *Profile {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	paths := []string{}
+	for path := range c.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	prof := &Profile{}
+	for _, path := range paths {
+		prof.Files = append(prof.Files, c.files[path].profile())
+	}
+	return prof
+}
+
+// A line counts as executed as often as its most executed statement; code
+// skipped within a line shows up through the branches of its if expressions.
+func (f *file) profile() *File {
+	counts := make(map[int]int64)
+	for _, s := range f.statements {
+		if count, ok := counts[s.line]; !ok || s.count > count {
+			counts[s.line] = s.count
+		}
+	}
+
+	out := &File{Path: f.path}
+	for line, count := range counts {
+		out.Lines = append(out.Lines, Line{Number: line, Count: count})
+	}
+	sort.Slice(out.Lines, func(i, j int) bool { return out.Lines[i].Number < out.Lines[j].Number })
+
+	for i, b := range f.ifs {
+		out.Branches = append(out.Branches,
+			Branch{Line: b.line, Block: i, Branch: 0, Taken: b.taken[0]},
+			Branch{Line: b.line, Block: i, Branch: 1, Taken: b.taken[1]},
+		)
+	}
+	return out
+}
+
+// Modules parsed again (for example by a later run) produce new nodes, which
+// are matched to the counters of the first parse by their order in the file.
+func (c *Collector) EnterModule(path string, program *ast.Program) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, ok := c.files[path]
+	if !ok {
+		f = &file{path: path}
+		c.files[path] = f
+	}
+
+	stmts, ifs := 0, 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.BlockStatement, *ast.Program:
+			return true
+		case *ast.MacroLiteral:
+			return false
+		case *ast.CallExpression:
+			if ident, ok := node.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+				return false
+			}
+		case *ast.IfExpression:
+			if ifs == len(f.ifs) {
+				f.ifs = append(f.ifs, &branches{line: node.Token.Line})
+			}
+			c.ifs[node] = f.ifs[ifs]
+			ifs++
+		case ast.Statement:
+			if stmts == len(f.statements) {
+				f.statements = append(f.statements, &statement{line: ast.Pos(node).Line})
+			}
+			c.statements[node] = f.statements[stmts]
+			stmts++
+		}
+		return true
+	})
+}
+
+func (c *Collector) ExitModule(path string, result object.Object) {}
+
+func (c *Collector) Node(node ast.Node, env *object.Environment) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.statements[node]; ok {
+		s.count++
+	}
+}
+
+func (c *Collector) Branch(ie *ast.IfExpression, taken bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.ifs[ie]
+	if !ok {
+		return
+	}
+	if taken {
+		b.taken[0]++
+	} else {
+		b.taken[1]++
+	}
+}
+
+func (c *Collector) EnterFunction(fn *object.Function, call *ast.CallExpression) {}
+
+func (c *Collector) ExitFunction(fn *object.Function, result object.Object) {}
+
+type Summary struct {
+	Lines, LinesHit       int
+	Branches, BranchesHit int
+}
+
+func (f *File) Summary() Summary {
+	s := Summary{Lines: len(f.Lines), Branches: len(f.Branches)}
+	for _, l := range f.Lines {
+		if l.Count > 0 {
+			s.LinesHit++
+		}
+	}
+	for _, b := range f.Branches {
+		if b.Taken > 0 {
+			s.BranchesHit++
+		}
+	}
+	return s
+}
+
+func (p *Profile) Summary() Summary {
+	var total Summary
+	for _, f := range p.Files {
+		s := f.Summary()
+		total.Lines += s.Lines
+		total.LinesHit += s.LinesHit
+		total.Branches += s.Branches
+		total.BranchesHit += s.BranchesHit
+	}
+	return total
+}
+
+func percent(hit, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(hit) / float64(total)
+}
+
+func (s Summary) LinePercent() float64 {
+	return percent(s.LinesHit, s.Lines)
+}
+
+func (s Summary) BranchPercent() float64 {
+	return percent(s.BranchesHit, s.Branches)
+}
@@ -0,0 +1,236 @@
+package coverage
+
+import (
+	"bytes"
+	"goscript/evaluator"
+	"goscript/object"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const libSource = `export let sign = fn(x) {
+	if (x < 0) { -1 } else { 1 }
+};
+`
+
+const mainSource = `import "lib";
+let check = fn(x) {
+	if (x > 10) {
+		puts("big");
+	}
+	lib.sign(x)
+};
+check(5);
+check(-3);
+`
+
+func writeFiles(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{"lib.gs": libSource, "main.gs": mainSource}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func run(t *testing.T, c *Collector, path string) {
+	t.Helper()
+
+	evaluator.SetImporter(evaluator.NewImporter(nil))
+	evaluator.SetHost(&evaluator.Host{Stdout: io.Discard})
+	defer evaluator.SetHost(evaluator.DefaultHost())
+
+	c.Start()
+	result := evaluator.EvalFile(path, object.NewEnvironment())
+	c.Stop()
+
+	if errObj, ok := result.(*object.Error); ok {
+		t.Fatalf("script failed: %s", errObj.Message)
+	}
+}
+
+func TestCollector(t *testing.T) {
+	dir := writeFiles(t)
+	lib, main := filepath.Join(dir, "lib.gs"), filepath.Join(dir, "main.gs")
+
+	tests := []struct {
+		testName string
+		runs     int
+		expected *Profile
+	}{
+		{
+			"single run",
+			1,
+			&Profile{Files: []*File{
+				{
+					Path:     lib,
+					Lines:    []Line{{1, 1}, {2, 2}},
+					Branches: []Branch{{2, 0, 0, 1}, {2, 0, 1, 1}},
+				},
+				{
+					Path:     main,
+					Lines:    []Line{{1, 1}, {2, 1}, {3, 2}, {4, 0}, {6, 2}, {8, 1}, {9, 1}},
+					Branches: []Branch{{3, 0, 0, 0}, {3, 0, 1, 2}},
+				},
+			}},
+		},
+		{
+			"counts accumulate across runs",
+			2,
+			&Profile{Files: []*File{
+				{
+					Path:     lib,
+					Lines:    []Line{{1, 2}, {2, 4}},
+					Branches: []Branch{{2, 0, 0, 2}, {2, 0, 1, 2}},
+				},
+				{
+					Path:     main,
+					Lines:    []Line{{1, 2}, {2, 2}, {3, 4}, {4, 0}, {6, 4}, {8, 2}, {9, 2}},
+					Branches: []Branch{{3, 0, 0, 0}, {3, 0, 1, 4}},
+				},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			c := New()
+			for i := 0; i < tt.runs; i++ {
+				run(t, c, main)
+			}
+
+			prof := c.Profile()
+			if !reflect.DeepEqual(prof, tt.expected) {
+				for _, f := range prof.Files {
+					t.Logf("%s: %v %v", f.Path, f.Lines, f.Branches)
+				}
+				t.Errorf("wrong profile")
+			}
+		})
+	}
+}
+
+func TestLCOV(t *testing.T) {
+	prof := &Profile{Files: []*File{
+		{
+			Path:     "/src/a.gs",
+			Lines:    []Line{{1, 3}, {2, 0}},
+			Branches: []Branch{{2, 0, 0, 0}, {2, 0, 1, 0}, {5, 1, 0, 2}, {5, 1, 1, 0}},
+		},
+	}}
+
+	expected := `TN:
+SF:/src/a.gs
+DA:1,3
+DA:2,0
+LF:2
+LH:1
+BRDA:2,0,0,-
+BRDA:2,0,1,-
+BRDA:5,1,0,2
+BRDA:5,1,1,0
+BRF:4
+BRH:1
+end_of_record
+`
+
+	var buf bytes.Buffer
+	if err := prof.WriteLCOV(&buf); err != nil {
+		t.Fatalf("WriteLCOV returned error: %v", err)
+	}
+	if buf.String() != expected {
+		t.Errorf("wrong LCOV output.\nwant=%q\ngot= %q", expected, buf.String())
+	}
+
+	read, err := ReadLCOV(strings.NewReader(expected))
+	if err != nil {
+		t.Fatalf("ReadLCOV returned error: %v", err)
+	}
+	if !reflect.DeepEqual(read, prof) {
+		t.Errorf("LCOV did not round trip. got=%+v", read.Files[0])
+	}
+
+	errors := []struct {
+		testName string
+		input    string
+		expected string
+	}{
+		{"missing colon", "SF:/a.gs\nbogus\n", "line 2: malformed record \"bogus\""},
+		{"record outside file", "DA:1,1\n", "line 1: DA record outside of a file"},
+		{"bad number", "SF:/a.gs\nDA:x,1\n", "line 2: invalid number \"x\""},
+		{"missing fields", "SF:/a.gs\nBRDA:1,0\n", "line 2: expected 4 fields, got \"1,0\""},
+	}
+
+	for _, tt := range errors {
+		t.Run(tt.testName, func(t *testing.T) {
+			_, err := ReadLCOV(strings.NewReader(tt.input))
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("wrong error. expected=%q, got=%v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestReports(t *testing.T) {
+	dir := writeFiles(t)
+	lib := filepath.Join(dir, "lib.gs")
+
+	prof := &Profile{Files: []*File{
+		{
+			Path:     lib,
+			Lines:    []Line{{1, 1}, {2, 0}},
+			Branches: []Branch{{1, 0, 0, 1}, {1, 0, 1, 0}},
+		},
+	}}
+
+	var buf bytes.Buffer
+	if err := prof.WriteText(&buf, false); err != nil {
+		t.Fatalf("WriteText returned error: %v", err)
+	}
+
+	expected := lib + `: 50.0% of lines (1/2), 50.0% of branches (1/2)
+    1      1 ~ export let sign = fn(x) {
+    2      0 - 	if (x < 0) { -1 } else { 1 }
+    3          };
+
+total: 50.0% of lines (1/2), 50.0% of branches (1/2)
+`
+	if buf.String() != expected {
+		t.Errorf("wrong text report.\nwant=%q\ngot= %q", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := prof.WriteText(&buf, true); err != nil {
+		t.Fatalf("WriteText returned error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "\x1b[31m    2      0 - ") || !strings.Contains(buf.String(), "\x1b[33m    1      1 ~ ") {
+		t.Errorf("expected colourised lines, got=%q", buf.String())
+	}
+
+	buf.Reset()
+	if err := prof.WriteHTML(&buf); err != nil {
+		t.Fatalf("WriteHTML returned error: %v", err)
+	}
+	for _, want := range []string{
+		`<tr class="partial"><td class="num">1</td><td class="count">1</td>`,
+		`<tr class="uncovered"><td class="num">2</td><td class="count">0</td><td>	if (x &lt; 0) { -1 } else { 1 }</td></tr>`,
+		`<tr class=""><td class="num">3</td><td class="count"></td><td>};</td></tr>`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("HTML report is missing %q", want)
+		}
+	}
+
+	missing := &Profile{Files: []*File{{Path: filepath.Join(dir, "missing.gs")}}}
+	if err := missing.WriteText(io.Discard, false); err == nil {
+		t.Errorf("expected an error for a missing source file")
+	}
+}
@@ -0,0 +1,129 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func (p *Profile) WriteLCOV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	for _, f := range p.Files {
+		s := f.Summary()
+
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", f.Path)
+		for _, l := range f.Lines {
+			fmt.Fprintf(bw, "DA:%d,%d\n", l.Number, l.Count)
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\n", s.Lines, s.LinesHit)
+
+		for i, b := range f.Branches {
+			fmt.Fprintf(bw, "BRDA:%d,%d,%d,%s\n", b.Line, b.Block, b.Branch, branchTaken(f.Branches, i))
+		}
+		fmt.Fprintf(bw, "BRF:%d\nBRH:%d\n", s.Branches, s.BranchesHit)
+		fmt.Fprintf(bw, "end_of_record\n")
+	}
+
+	return bw.Flush()
+}
+
+// LCOV writes "-" for the branches of a block that was never evaluated.
+func branchTaken(branches []Branch, i int) string {
+	for _, b := range branches {
+		if b.Block == branches[i].Block && b.Taken > 0 {
+			return strconv.FormatInt(branches[i].Taken, 10)
+		}
+	}
+	return "-"
+}
+
+func ReadLCOV(r io.Reader) (*Profile, error) {
+	files := make(map[string]*File)
+	var current *File
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if line == "end_of_record" {
+			current = nil
+			continue
+		}
+
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: malformed record %q", lineNo, line)
+		}
+		kind, value := line[:i], line[i+1:]
+
+		if kind == "SF" {
+			current = files[value]
+			if current == nil {
+				current = &File{Path: value}
+				files[value] = current
+			}
+			continue
+		}
+
+		if current == nil {
+			if kind == "TN" {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: %s record outside of a file", lineNo, kind)
+		}
+
+		switch kind {
+		case "DA":
+			nums, err := parseFields(value, 2)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", lineNo, err)
+			}
+			current.Lines = append(current.Lines, Line{Number: int(nums[0]), Count: nums[1]})
+		case "BRDA":
+			nums, err := parseFields(value, 4)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", lineNo, err)
+			}
+			current.Branches = append(current.Branches, Branch{Line: int(nums[0]), Block: int(nums[1]), Branch: int(nums[2]), Taken: nums[3]})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	prof := &Profile{}
+	for _, f := range files {
+		prof.Files = append(prof.Files, f)
+	}
+	sort.Slice(prof.Files, func(i, j int) bool { return prof.Files[i].Path < prof.Files[j].Path })
+
+	return prof, nil
+}
+
+func parseFields(value string, n int) ([]int64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) < n {
+		return nil, fmt.Errorf("expected %d fields, got %q", n, value)
+	}
+
+	nums := make([]int64, n)
+	for i := 0; i < n; i++ {
+		if parts[i] == "-" {
+			continue
+		}
+		num, err := strconv.ParseInt(parts[i], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", parts[i])
+		}
+		nums[i] = num
+	}
+	return nums, nil
+}
@@ -0,0 +1,178 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type status int
+
+const (
+	notExecutable status = iota
+	covered
+	partial
+	uncovered
+)
+
+var ansiColors = map[status]string{
+	covered:   "\x1b[32m",
+	partial:   "\x1b[33m",
+	uncovered: "\x1b[31m",
+}
+
+var markers = map[status]byte{
+	notExecutable: ' ',
+	covered:       ' ',
+	partial:       '~',
+	uncovered:     '-',
+}
+
+type reportLine struct {
+	Number int
+	Count  string
+	Status status
+	Source string
+}
+
+func (f *File) report() ([]reportLine, error) {
+	src, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int]int64)
+	for _, l := range f.Lines {
+		counts[l.Number] = l.Count
+	}
+	missed := make(map[int]bool)
+	for _, b := range f.Branches {
+		if b.Taken == 0 {
+			missed[b.Line] = true
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(src), "\n"), "\n")
+	out := make([]reportLine, len(lines))
+	for i, text := range lines {
+		rl := reportLine{Number: i + 1, Source: text}
+
+		if count, ok := counts[rl.Number]; ok {
+			rl.Count = strconv.FormatInt(count, 10)
+			switch {
+			case count == 0:
+				rl.Status = uncovered
+			case missed[rl.Number]:
+				rl.Status = partial
+			default:
+				rl.Status = covered
+			}
+		}
+
+		out[i] = rl
+	}
+	return out, nil
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("%.1f%% of lines (%d/%d), %.1f%% of branches (%d/%d)",
+		s.LinePercent(), s.LinesHit, s.Lines, s.BranchPercent(), s.BranchesHit, s.Branches)
+}
+
+func (p *Profile) WriteText(w io.Writer, color bool) error {
+	bw := bufio.NewWriter(w)
+
+	for i, f := range p.Files {
+		lines, err := f.report()
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			fmt.Fprintln(bw)
+		}
+		fmt.Fprintf(bw, "%s: %s\n", f.Path, f.Summary())
+
+		for _, l := range lines {
+			row := fmt.Sprintf("%5d %6s %c %s", l.Number, l.Count, markers[l.Status], l.Source)
+			if code, ok := ansiColors[l.Status]; ok && color {
+				row = code + row + "\x1b[0m"
+			}
+			fmt.Fprintln(bw, row)
+		}
+	}
+
+	fmt.Fprintf(bw, "\ntotal: %s\n", p.Summary())
+	return bw.Flush()
+}
+
+var htmlTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>goscript coverage</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td { padding: 0 0.5em; white-space: pre; font-family: monospace; }
+td.num, td.count { text-align: right; color: #888; }
+tr.covered { background: #dfd; }
+tr.partial { background: #ffd; }
+tr.uncovered { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>Coverage: {{.Summary}}</h1>
+{{range .Files}}
+<h2 id="{{.Path}}">{{.Path}}</h2>
+<p>{{.Summary}}</p>
+<table>
+{{range .Lines}}<tr class="{{.Class}}"><td class="num">{{.Number}}</td><td class="count">{{.Count}}</td><td>{{.Source}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+var htmlClasses = map[status]string{
+	covered:   "covered",
+	partial:   "partial",
+	uncovered: "uncovered",
+}
+
+type htmlLine struct {
+	reportLine
+	Class string
+}
+
+type htmlFile struct {
+	Path    string
+	Summary Summary
+	Lines   []htmlLine
+}
+
+func (p *Profile) WriteHTML(w io.Writer) error {
+	data := struct {
+		Summary Summary
+		Files   []htmlFile
+	}{Summary: p.Summary()}
+
+	for _, f := range p.Files {
+		lines, err := f.report()
+		if err != nil {
+			return err
+		}
+
+		hf := htmlFile{Path: f.Path, Summary: f.Summary()}
+		for _, l := range lines {
+			hf.Lines = append(hf.Lines, htmlLine{reportLine: l, Class: htmlClasses[l.Status]})
+		}
+		data.Files = append(data.Files, hf)
+	}
+
+	return htmlTemplate.Execute(w, data)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"goscript/debug"
+	"goscript/evaluator"
+	"os"
+)
+
+func debugMain(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	opts := addRunFlags(flags)
+	dap := flags.Bool("dap", false, "speak the Debug Adapter Protocol on stdin and stdout")
+	flags.Parse(args)
+
+	// Breakpoints and stepping follow the code as written.
+	*opts.noOptimize = true
+
+	if *dap {
+		if flags.NArg() != 0 {
+			fmt.Fprintln(os.Stderr, "usage: goscript debug -dap")
+			return 2
+		}
+
+		server := debug.NewDAPServer(os.Stdin, os.Stdout, opts.configure())
+		if err := server.Serve(); err != nil {
+			fmt.Fprintln(os.Stderr, "goscript debug:", err)
+			return 1
+		}
+		return 0
+	}
+
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "usage: goscript debug [flags] file%s\n", evaluator.SourceExt)
+		return 2
+	}
+
+	opts.configure()
+
+	d, err := debug.New(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return debug.NewCLI(d, os.Stdin, os.Stdout).Run()
+}
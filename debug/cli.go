@@ -0,0 +1,254 @@
+package debug
+
+import (
+	"bufio"
+	"fmt"
+	"goscript/object"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const cliHelp = `commands:
+  break N, b N     set a breakpoint on line N
+  clear [N]        remove the breakpoint on line N, or all breakpoints
+  continue, c      run until the next breakpoint
+  step, s          step into the next statement
+  next, n          step over function calls
+  out, o           run until the current function returns
+  stack, bt        print the call stack
+  frame N, f N     select frame N for locals and print
+  locals           print the variables of the selected frame
+  print E, p E     evaluate E in the selected frame
+  list             show the source around the current line
+  quit, q          stop the program and exit
+`
+
+type CLI struct {
+	d      *Debugger
+	in     *bufio.Scanner
+	out    io.Writer
+	source []string
+	frame  int
+	line   int
+}
+
+func NewCLI(d *Debugger, in io.Reader, out io.Writer) *CLI {
+	c := &CLI{d: d, in: bufio.NewScanner(in), out: out}
+
+	if src, err := os.ReadFile(d.Path); err == nil {
+		c.source = strings.Split(string(src), "\n")
+	}
+
+	return c
+}
+
+func (c *CLI) Run() int {
+	c.d.Start(true)
+
+	for ev := range c.d.Events() {
+		if ev.Kind == Exited {
+			return c.exited(ev.Result)
+		}
+
+		c.frame = 0
+		c.line = ev.Line
+		fmt.Fprintf(c.out, "stopped at %s:%d (%s)\n", filepath.Base(c.d.Path), ev.Line, ev.Reason)
+		c.printLine(ev.Line)
+
+		c.prompt()
+	}
+
+	return 0
+}
+
+func (c *CLI) exited(result object.Object) int {
+	if errObj, ok := result.(*object.Error); ok {
+		fmt.Fprintln(c.out, errObj.Inspect())
+		return 1
+	}
+	if result == nil {
+		fmt.Fprintln(c.out, "program terminated")
+		return 0
+	}
+
+	fmt.Fprintln(c.out, "program exited")
+	return 0
+}
+
+func (c *CLI) prompt() {
+	for {
+		fmt.Fprint(c.out, "(debug) ")
+		if !c.in.Scan() {
+			c.d.Terminate()
+			return
+		}
+
+		fields := strings.Fields(c.in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		cmd, arg := fields[0], strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(c.in.Text()), fields[0]))
+
+		switch cmd {
+		case "continue", "c":
+			c.d.Continue()
+			return
+		case "step", "s":
+			c.d.StepIn()
+			return
+		case "next", "n":
+			c.d.StepOver()
+			return
+		case "out", "o":
+			c.d.StepOut()
+			return
+		case "quit", "q":
+			c.d.Terminate()
+			return
+		case "break", "b":
+			c.setBreakpoint(arg)
+		case "clear":
+			c.clearBreakpoint(arg)
+		case "stack", "bt":
+			c.printStack()
+		case "frame", "f":
+			c.selectFrame(arg)
+		case "locals":
+			c.printLocals()
+		case "print", "p":
+			c.print(arg)
+		case "list":
+			c.list()
+		case "help", "h":
+			fmt.Fprint(c.out, cliHelp)
+		default:
+			fmt.Fprintf(c.out, "unknown command %q, type help for a list of commands\n", cmd)
+		}
+	}
+}
+
+func (c *CLI) setBreakpoint(arg string) {
+	line, err := strconv.Atoi(arg)
+	if err != nil {
+		fmt.Fprintln(c.out, "usage: break LINE")
+		return
+	}
+
+	lines := append(c.d.Breakpoints(), line)
+	placed := c.d.SetBreakpoints(lines)
+	if placed[len(placed)-1] == 0 {
+		c.d.SetBreakpoints(lines[:len(lines)-1])
+		fmt.Fprintf(c.out, "no statement at or after line %d\n", line)
+		return
+	}
+
+	fmt.Fprintf(c.out, "breakpoint set at line %d\n", placed[len(placed)-1])
+}
+
+func (c *CLI) clearBreakpoint(arg string) {
+	if arg == "" {
+		c.d.SetBreakpoints(nil)
+		fmt.Fprintln(c.out, "all breakpoints cleared")
+		return
+	}
+
+	line, err := strconv.Atoi(arg)
+	if err != nil {
+		fmt.Fprintln(c.out, "usage: clear [LINE]")
+		return
+	}
+
+	lines := []int{}
+	for _, l := range c.d.Breakpoints() {
+		if l != line {
+			lines = append(lines, l)
+		}
+	}
+	c.d.SetBreakpoints(lines)
+	fmt.Fprintf(c.out, "breakpoint at line %d cleared\n", line)
+}
+
+func (c *CLI) printStack() {
+	frames, err := c.d.Frames()
+	if err != nil {
+		fmt.Fprintln(c.out, err)
+		return
+	}
+
+	for i, frame := range frames {
+		marker := " "
+		if i == c.frame {
+			marker = "*"
+		}
+		fmt.Fprintf(c.out, "%s#%d %s at line %d\n", marker, i, frame.Name, frame.Line)
+	}
+}
+
+func (c *CLI) selectFrame(arg string) {
+	frames, err := c.d.Frames()
+	if err != nil {
+		fmt.Fprintln(c.out, err)
+		return
+	}
+
+	index, err := strconv.Atoi(arg)
+	if err != nil || index < 0 || index >= len(frames) {
+		fmt.Fprintf(c.out, "frame must be between 0 and %d\n", len(frames)-1)
+		return
+	}
+
+	c.frame = index
+	c.line = frames[index].Line
+	fmt.Fprintf(c.out, "#%d %s at line %d\n", index, frames[index].Name, frames[index].Line)
+}
+
+func (c *CLI) printLocals() {
+	vars, err := c.d.Locals(c.frame)
+	if err != nil {
+		fmt.Fprintln(c.out, err)
+		return
+	}
+
+	for _, v := range vars {
+		fmt.Fprintf(c.out, "%s = %s\n", v.Name, v.Value)
+	}
+}
+
+func (c *CLI) print(expression string) {
+	if expression == "" {
+		fmt.Fprintln(c.out, "usage: print EXPRESSION")
+		return
+	}
+
+	result, err := c.d.Evaluate(c.frame, expression)
+	if err != nil {
+		fmt.Fprintln(c.out, "error:", err)
+		return
+	}
+
+	fmt.Fprintln(c.out, result)
+}
+
+func (c *CLI) printLine(line int) {
+	if line >= 1 && line <= len(c.source) {
+		fmt.Fprintf(c.out, "%4d\t%s\n", line, c.source[line-1])
+	}
+}
+
+func (c *CLI) list() {
+	for line := c.line - 3; line <= c.line+3; line++ {
+		if line < 1 || line > len(c.source) {
+			continue
+		}
+
+		marker := " "
+		if line == c.line {
+			marker = ">"
+		}
+		fmt.Fprintf(c.out, "%s%4d\t%s\n", marker, line, c.source[line-1])
+	}
+}
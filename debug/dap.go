@@ -0,0 +1,382 @@
+package debug
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goscript/evaluator"
+	"goscript/jsonrpc"
+	"goscript/object"
+	"io"
+	"path/filepath"
+	"sync"
+)
+
+type dapRequest struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type dapResponse struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+type dapEvent struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+type dapSource struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type dapBreakpoint struct {
+	Verified bool `json:"verified"`
+	Line     int  `json:"line,omitempty"`
+}
+
+type DAPServer struct {
+	r    *bufio.Reader
+	w    io.Writer
+	host *evaluator.Host
+
+	mu  sync.Mutex
+	seq int
+
+	d           *Debugger
+	stopOnEntry bool
+	pending     []int
+	exited      chan struct{}
+
+	// stopped is the thread that stopped last, whose frames are the only
+	// ones that can be inspected. It is guarded by mu.
+	stopped int
+}
+
+func NewDAPServer(r io.Reader, w io.Writer, host *evaluator.Host) *DAPServer {
+	s := &DAPServer{r: bufio.NewReader(r), w: w, host: host, exited: make(chan struct{})}
+
+	host.Stdout = &outputWriter{s: s, category: "stdout"}
+	host.Stderr = &outputWriter{s: s, category: "stderr"}
+
+	return s
+}
+
+type outputWriter struct {
+	s        *DAPServer
+	category string
+}
+
+func (o *outputWriter) Write(p []byte) (int, error) {
+	o.s.event("output", map[string]string{"category": o.category, "output": string(p)})
+	return len(p), nil
+}
+
+func (s *DAPServer) write(msg interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	switch msg := msg.(type) {
+	case *dapResponse:
+		msg.Seq = s.seq
+	case *dapEvent:
+		msg.Seq = s.seq
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return jsonrpc.WriteFrame(s.w, body)
+}
+
+func (s *DAPServer) event(name string, body interface{}) {
+	s.write(&dapEvent{Type: "event", Event: name, Body: body})
+}
+
+func (s *DAPServer) Serve() error {
+	evaluator.SetHost(s.host)
+
+	for {
+		frame, err := jsonrpc.ReadFrame(s.r)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		var req dapRequest
+		if err := json.Unmarshal(frame, &req); err != nil {
+			return err
+		}
+
+		body, err := s.handle(&req)
+
+		resp := &dapResponse{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: body}
+		if err != nil {
+			resp.Message = err.Error()
+		}
+		if err := s.write(resp); err != nil {
+			return err
+		}
+
+		if resume, ok := resumeCommands[req.Command]; ok && err == nil {
+			resume(s.d)
+		}
+
+		switch req.Command {
+		case "launch":
+			if err == nil {
+				s.event("initialized", nil)
+			}
+		case "configurationDone":
+			s.start()
+		case "disconnect", "terminate":
+			return nil
+		}
+	}
+}
+
+func (s *DAPServer) handle(req *dapRequest) (interface{}, error) {
+	switch req.Command {
+	case "initialize":
+		return map[string]bool{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+		}, nil
+
+	case "launch":
+		var args struct {
+			Program     string `json:"program"`
+			StopOnEntry bool   `json:"stopOnEntry"`
+		}
+		if err := decodeArgs(req, &args); err != nil {
+			return nil, err
+		}
+
+		d, err := New(args.Program)
+		if err != nil {
+			return nil, err
+		}
+		s.d, s.stopOnEntry = d, args.StopOnEntry
+		if s.pending != nil {
+			d.SetBreakpoints(s.pending)
+		}
+		return nil, nil
+
+	case "setBreakpoints":
+		var args struct {
+			Breakpoints []struct {
+				Line int `json:"line"`
+			} `json:"breakpoints"`
+		}
+		if err := decodeArgs(req, &args); err != nil {
+			return nil, err
+		}
+
+		lines := make([]int, len(args.Breakpoints))
+		for i, bp := range args.Breakpoints {
+			lines[i] = bp.Line
+		}
+
+		breakpoints := make([]dapBreakpoint, len(lines))
+		if s.d == nil {
+			s.pending = lines
+			return map[string]interface{}{"breakpoints": breakpoints}, nil
+		}
+
+		for i, line := range s.d.SetBreakpoints(lines) {
+			breakpoints[i] = dapBreakpoint{Verified: line != 0, Line: line}
+		}
+		return map[string]interface{}{"breakpoints": breakpoints}, nil
+
+	case "configurationDone":
+		if s.d == nil {
+			return nil, errors.New("no program has been launched")
+		}
+		return nil, nil
+
+	case "threads":
+		threads := []map[string]interface{}{}
+		if s.d != nil {
+			for _, t := range s.d.Threads() {
+				threads = append(threads, map[string]interface{}{"id": t.ID, "name": t.Name})
+			}
+		}
+		return map[string]interface{}{"threads": threads}, nil
+
+	case "stackTrace":
+		var args struct {
+			ThreadID int `json:"threadId"`
+		}
+		if err := decodeArgs(req, &args); err != nil {
+			return nil, err
+		}
+		if s.d == nil {
+			return nil, ErrNotPaused
+		}
+		if args.ThreadID != s.stoppedThread() {
+			return nil, fmt.Errorf("thread %d is not paused", args.ThreadID)
+		}
+		frames, err := s.d.Frames()
+		if err != nil {
+			return nil, err
+		}
+
+		source := dapSource{Name: filepath.Base(s.d.Path), Path: s.d.Path}
+		stackFrames := make([]map[string]interface{}, len(frames))
+		for i, frame := range frames {
+			stackFrames[i] = map[string]interface{}{
+				"id":     i,
+				"name":   frame.Name,
+				"line":   frame.Line,
+				"column": 1,
+				"source": source,
+			}
+		}
+		return map[string]interface{}{"stackFrames": stackFrames, "totalFrames": len(frames)}, nil
+
+	case "scopes":
+		var args struct {
+			FrameID int `json:"frameId"`
+		}
+		if err := decodeArgs(req, &args); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"scopes": []map[string]interface{}{
+				{"name": "Locals", "variablesReference": args.FrameID + 1, "expensive": false},
+			},
+		}, nil
+
+	case "variables":
+		var args struct {
+			VariablesReference int `json:"variablesReference"`
+		}
+		if err := decodeArgs(req, &args); err != nil {
+			return nil, err
+		}
+		if s.d == nil {
+			return nil, ErrNotPaused
+		}
+
+		vars, err := s.d.Locals(args.VariablesReference - 1)
+		if err != nil {
+			return nil, err
+		}
+
+		variables := make([]map[string]interface{}, len(vars))
+		for i, v := range vars {
+			variables[i] = map[string]interface{}{"name": v.Name, "value": v.Value, "type": v.Type, "variablesReference": 0}
+		}
+		return map[string]interface{}{"variables": variables}, nil
+
+	case "evaluate":
+		var args struct {
+			Expression string `json:"expression"`
+			FrameID    int    `json:"frameId"`
+		}
+		if err := decodeArgs(req, &args); err != nil {
+			return nil, err
+		}
+		if s.d == nil {
+			return nil, ErrNotPaused
+		}
+
+		result, err := s.d.Evaluate(args.FrameID, args.Expression)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"result": result, "variablesReference": 0}, nil
+
+	case "continue", "next", "stepIn", "stepOut":
+		if s.d == nil || !s.d.isPaused() {
+			return nil, ErrNotPaused
+		}
+		if req.Command == "continue" {
+			return map[string]bool{"allThreadsContinued": true}, nil
+		}
+		return nil, nil
+
+	case "disconnect", "terminate":
+		if s.d != nil && s.d.Terminate() == nil {
+			<-s.exited
+		}
+		return nil, nil
+
+	default:
+		return nil, errors.New("unsupported request: " + req.Command)
+	}
+}
+
+func (s *DAPServer) stoppedThread() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stopped
+}
+
+func decodeArgs(req *dapRequest, v interface{}) error {
+	if len(req.Arguments) == 0 {
+		return nil
+	}
+	return json.Unmarshal(req.Arguments, v)
+}
+
+var resumeCommands = map[string]func(*Debugger) error{
+	"continue": (*Debugger).Continue,
+	"next":     (*Debugger).StepOver,
+	"stepIn":   (*Debugger).StepIn,
+	"stepOut":  (*Debugger).StepOut,
+}
+
+func (s *DAPServer) start() {
+	if s.d == nil {
+		return
+	}
+
+	s.d.Start(s.stopOnEntry)
+
+	go func() {
+		for ev := range s.d.Events() {
+			if ev.Kind == Stopped {
+				s.mu.Lock()
+				s.stopped = ev.Thread
+				s.mu.Unlock()
+
+				// The other threads keep running until they stop in turn.
+				s.event("stopped", map[string]interface{}{
+					"reason":            ev.Reason,
+					"threadId":          ev.Thread,
+					"allThreadsStopped": false,
+				})
+				continue
+			}
+
+			exitCode := 0
+			if errObj, ok := ev.Result.(*object.Error); ok {
+				s.event("output", map[string]string{"category": "stderr", "output": errObj.Inspect() + "\n"})
+				exitCode = 1
+			}
+			s.event("exited", map[string]int{"exitCode": exitCode})
+			s.event("terminated", nil)
+			close(s.exited)
+			return
+		}
+	}()
+}
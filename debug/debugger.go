@@ -0,0 +1,519 @@
+package debug
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"goscript/ast"
+	"goscript/evaluator"
+	"goscript/lexer"
+	"goscript/object"
+	"goscript/parser"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var ErrNotPaused = errors.New("program is not paused")
+
+var errTerminated = errors.New("terminated")
+
+const (
+	ReasonEntry      = "entry"
+	ReasonBreakpoint = "breakpoint"
+	ReasonStep       = "step"
+)
+
+type EventKind int
+
+const (
+	Stopped EventKind = iota
+	Exited
+)
+
+// An Event's Thread is the id of the thread that stopped.
+type Event struct {
+	Kind   EventKind
+	Reason string
+	Line   int
+	Thread int
+	Result object.Object
+}
+
+type Thread struct {
+	ID   int
+	Name string
+}
+
+type Frame struct {
+	Name string
+	Line int
+	Env  *object.Environment
+}
+
+type Variable struct {
+	Name  string
+	Type  string
+	Value string
+}
+
+type mode int
+
+const (
+	modeContinue mode = iota
+	modeStepIn
+	modeStepOver
+	modeStepOut
+	modeTerminate
+)
+
+// A thread is the main program, a task or a generator, each of which runs in
+// a goroutine of its own and has its own frames. Only that goroutine uses
+// it, apart from its id and name, which are numbered from 1 for the main
+// program in the order the threads start and never change.
+type thread struct {
+	id         int
+	name       string
+	frames     []*Frame
+	inspecting bool
+}
+
+type command struct {
+	mode    mode
+	inspect func()
+	done    chan struct{}
+}
+
+type Debugger struct {
+	Path    string
+	Program *ast.Program
+
+	statements map[ast.Node]bool
+	lines      []int
+
+	mu          sync.Mutex
+	breakpoints map[int]bool
+	paused      bool
+	threads     map[uint64]*thread
+	lastID      int
+	mode        mode
+	stepping    *thread
+	stepDepth   int
+	terminated  bool
+
+	// pausing is held by the thread that is paused, so that the others
+	// wait for it to resume before they pause in turn.
+	pausing sync.Mutex
+
+	commands chan command
+	events   chan Event
+}
+
+func New(path string) (*Debugger, error) {
+	program, errObj := evaluator.ParseFile(path)
+	if errObj != nil {
+		return nil, errors.New(errObj.Message)
+	}
+
+	d := &Debugger{
+		Path:        path,
+		Program:     program,
+		statements:  make(map[ast.Node]bool),
+		breakpoints: make(map[int]bool),
+		threads:     make(map[uint64]*thread),
+		commands:    make(chan command),
+		events:      make(chan Event, 1),
+	}
+
+	seen := make(map[int]bool)
+	ast.Inspect(program, func(node ast.Node) bool {
+		if stmt, ok := node.(ast.Statement); ok {
+			if _, block := stmt.(*ast.BlockStatement); !block {
+				d.statements[stmt] = true
+				if line := ast.Pos(stmt).Line; !seen[line] {
+					seen[line] = true
+					d.lines = append(d.lines, line)
+				}
+			}
+		}
+		return true
+	})
+	sort.Ints(d.lines)
+
+	return d, nil
+}
+
+func (d *Debugger) Events() <-chan Event {
+	return d.events
+}
+
+func (d *Debugger) Start(stopOnEntry bool) {
+	if stopOnEntry {
+		d.mode = modeStepIn
+	}
+
+	go func() {
+		evaluator.SetTracer(d)
+		result := d.RunTask("main", func() object.Object {
+			return evaluator.EvalProgram(d.Path, d.Program, object.NewEnvironment())
+		})
+		evaluator.SetTracer(nil)
+
+		if d.isTerminated() {
+			result = nil
+		}
+		d.events <- Event{Kind: Exited, Result: result}
+	}()
+}
+
+// RunTask runs the main program, a task or a generator as a thread of its
+// own. A task that is terminated results in an error, which is never seen:
+// the thread that awaits it is terminated as soon as it runs again.
+func (d *Debugger) RunTask(name string, body func() object.Object) (result object.Object) {
+	if name == "" {
+		name = "fn"
+	}
+
+	id := goid()
+	d.mu.Lock()
+	d.lastID++
+	d.threads[id] = &thread{id: d.lastID, name: name, frames: []*Frame{{Name: name}}}
+	d.mu.Unlock()
+
+	defer func() {
+		d.mu.Lock()
+		delete(d.threads, id)
+		d.mu.Unlock()
+
+		if r := recover(); r != nil {
+			if r != errTerminated {
+				panic(r)
+			}
+			result = &object.Error{Message: errTerminated.Error()}
+		}
+	}()
+
+	return body()
+}
+
+// goid returns the id of the calling goroutine, which Go only exposes in
+// stack traces.
+func goid() uint64 {
+	buf := make([]byte, 64)
+	buf = bytes.TrimPrefix(buf[:runtime.Stack(buf, false)], []byte("goroutine "))
+	id, _ := strconv.ParseUint(string(buf[:bytes.IndexByte(buf, ' ')]), 10, 64)
+	return id
+}
+
+// thread returns the thread of the calling goroutine, or nil if it runs
+// nothing that is debugged.
+func (d *Debugger) thread() *thread {
+	id := goid()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.threads[id]
+}
+
+// Threads returns the threads that are running, ordered by id.
+func (d *Debugger) Threads() []Thread {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	threads := []Thread{}
+	for _, t := range d.threads {
+		threads = append(threads, Thread{ID: t.id, Name: t.name})
+	}
+	sort.Slice(threads, func(i, j int) bool { return threads[i].ID < threads[j].ID })
+
+	return threads
+}
+
+func (d *Debugger) isTerminated() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.terminated
+}
+
+// SetBreakpoints replaces all breakpoints and returns the line each one was
+// placed on, which is the first line at or after the requested one that
+// starts a statement, or 0 when there is none.
+func (d *Debugger) SetBreakpoints(lines []int) []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.breakpoints = make(map[int]bool)
+	placed := make([]int, len(lines))
+
+	for i, line := range lines {
+		j := sort.SearchInts(d.lines, line)
+		if j == len(d.lines) {
+			continue
+		}
+		placed[i] = d.lines[j]
+		d.breakpoints[d.lines[j]] = true
+	}
+
+	return placed
+}
+
+func (d *Debugger) Breakpoints() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lines := []int{}
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+
+	return lines
+}
+
+func (d *Debugger) Continue() error { return d.resume(modeContinue) }
+func (d *Debugger) StepIn() error   { return d.resume(modeStepIn) }
+func (d *Debugger) StepOver() error { return d.resume(modeStepOver) }
+func (d *Debugger) StepOut() error  { return d.resume(modeStepOut) }
+
+// Terminate stops a paused program. The Exited event that follows carries a
+// nil Result.
+func (d *Debugger) Terminate() error { return d.resume(modeTerminate) }
+
+func (d *Debugger) resume(m mode) error {
+	if !d.isPaused() {
+		return ErrNotPaused
+	}
+
+	d.commands <- command{mode: m}
+	return nil
+}
+
+func (d *Debugger) isPaused() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.paused
+}
+
+func (d *Debugger) inspect(fn func()) error {
+	if !d.isPaused() {
+		return ErrNotPaused
+	}
+
+	done := make(chan struct{})
+	d.commands <- command{inspect: fn, done: done}
+	<-done
+
+	return nil
+}
+
+func (d *Debugger) Frames() ([]Frame, error) {
+	var frames []Frame
+
+	err := d.inspect(func() {
+		t := d.thread()
+		for i := len(t.frames) - 1; i >= 0; i-- {
+			frames = append(frames, *t.frames[i])
+		}
+	})
+
+	return frames, err
+}
+
+// frame returns a frame of the paused thread, which is the one that runs
+// everything passed to inspect.
+func (d *Debugger) frame(index int) (*Frame, error) {
+	t := d.thread()
+	if index < 0 || index >= len(t.frames) {
+		return nil, fmt.Errorf("no frame %d", index)
+	}
+	return t.frames[len(t.frames)-1-index], nil
+}
+
+func (d *Debugger) Locals(index int) ([]Variable, error) {
+	var vars []Variable
+	var err error
+
+	inspectErr := d.inspect(func() {
+		var frame *Frame
+		if frame, err = d.frame(index); err != nil {
+			return
+		}
+
+		seen := make(map[string]bool)
+		outermost := index == len(d.thread().frames)-1
+		for env := frame.Env; env != nil && (outermost || env.Outer() != nil); env = env.Outer() {
+			for _, name := range env.Names() {
+				if seen[name] {
+					continue
+				}
+				seen[name] = true
+
+				val, _ := env.Get(name)
+				vars = append(vars, Variable{Name: name, Type: string(val.Type()), Value: inspect(val)})
+			}
+		}
+	})
+	if inspectErr != nil {
+		return nil, inspectErr
+	}
+
+	return vars, err
+}
+
+func (d *Debugger) Evaluate(index int, expression string) (string, error) {
+	var result string
+	var err error
+
+	inspectErr := d.inspect(func() {
+		var frame *Frame
+		if frame, err = d.frame(index); err != nil {
+			return
+		}
+
+		p := parser.New(lexer.New(expression))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			err = errors.New(strings.Join(p.Errors(), "; "))
+			return
+		}
+
+		t := d.thread()
+		t.inspecting = true
+		val := evaluator.Eval(program, frame.Env)
+		t.inspecting = false
+
+		if errObj, ok := val.(*object.Error); ok {
+			err = errors.New(errObj.Message)
+			return
+		}
+		result = inspect(val)
+	})
+	if inspectErr != nil {
+		return "", inspectErr
+	}
+
+	return result, err
+}
+
+func inspect(obj object.Object) string {
+	switch obj := obj.(type) {
+	case nil:
+		return "null"
+	case *object.Function:
+		params := make([]string, len(obj.Parameters))
+		for i, p := range obj.Parameters {
+			params[i] = p.Value
+			if obj.Rest && i == len(obj.Parameters)-1 {
+				params[i] = "..." + p.Value
+			}
+		}
+		return "fn(" + strings.Join(params, ", ") + ")"
+	default:
+		return obj.Inspect()
+	}
+}
+
+func (d *Debugger) Node(node ast.Node, env *object.Environment) {
+	t := d.thread()
+	if t == nil || t.inspecting || !d.statements[node] {
+		return
+	}
+	if d.isTerminated() {
+		panic(errTerminated)
+	}
+
+	frame := t.frames[len(t.frames)-1]
+	frame.Line = ast.Pos(node).Line
+	frame.Env = env
+
+	if reason, ok := d.shouldStop(t, frame.Line); ok {
+		d.pause(t, reason, frame.Line)
+	}
+}
+
+// shouldStop reports whether t stops at line. Only the thread that was
+// paused last takes steps; the others only stop at breakpoints.
+func (d *Debugger) shouldStop(t *thread, line int) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	depth := len(t.frames)
+	stepping := d.stepping == nil || d.stepping == t
+
+	switch {
+	case stepping && d.mode == modeStepIn:
+		if d.stepDepth == 0 {
+			return ReasonEntry, true
+		}
+		return ReasonStep, true
+	case stepping && d.mode == modeStepOver && depth <= d.stepDepth:
+		return ReasonStep, true
+	case stepping && d.mode == modeStepOut && depth < d.stepDepth:
+		return ReasonStep, true
+	case d.breakpoints[line]:
+		return ReasonBreakpoint, true
+	}
+
+	return "", false
+}
+
+func (d *Debugger) pause(t *thread, reason string, line int) {
+	d.pausing.Lock()
+	defer d.pausing.Unlock()
+	if d.isTerminated() {
+		panic(errTerminated)
+	}
+
+	d.mu.Lock()
+	d.paused = true
+	d.mu.Unlock()
+
+	d.events <- Event{Kind: Stopped, Reason: reason, Line: line, Thread: t.id}
+
+	for cmd := range d.commands {
+		if cmd.inspect != nil {
+			cmd.inspect()
+			close(cmd.done)
+			continue
+		}
+
+		d.mu.Lock()
+		d.paused = false
+		d.mode = cmd.mode
+		d.stepping = t
+		d.stepDepth = len(t.frames)
+		d.terminated = cmd.mode == modeTerminate
+		d.mu.Unlock()
+
+		if cmd.mode == modeTerminate {
+			panic(errTerminated)
+		}
+		return
+	}
+}
+
+func (d *Debugger) EnterFunction(fn *object.Function, call *ast.CallExpression) {
+	t := d.thread()
+	if t == nil || t.inspecting {
+		return
+	}
+
+	name := fn.Name
+	if name == "" {
+		name = "fn"
+	}
+	t.frames = append(t.frames, &Frame{Name: name, Line: ast.Pos(fn.Body).Line})
+}
+
+func (d *Debugger) ExitFunction(fn *object.Function, result object.Object) {
+	t := d.thread()
+	if t == nil || t.inspecting {
+		return
+	}
+
+	t.frames = t.frames[:len(t.frames)-1]
+}
@@ -0,0 +1,484 @@
+package debug
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"goscript/evaluator"
+	"goscript/jsonrpc"
+	"goscript/object"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSource = `let add = fn(a, b) {
+	let sum = a + b;
+	sum
+};
+let x = add(1, 2);
+puts(x);
+let y = add(x, 10);
+puts(y);
+`
+
+func writeScript(t *testing.T, src string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "main.gs")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestDebugger(t *testing.T, src string) (*Debugger, *strings.Builder) {
+	t.Helper()
+
+	var out strings.Builder
+	evaluator.SetHost(&evaluator.Host{Stdout: &out})
+	t.Cleanup(func() { evaluator.SetHost(evaluator.DefaultHost()) })
+
+	d, err := New(writeScript(t, src))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	return d, &out
+}
+
+func expectStop(t *testing.T, d *Debugger, reason string, line int) {
+	t.Helper()
+
+	ev := <-d.Events()
+	if ev.Kind != Stopped || ev.Reason != reason || ev.Line != line {
+		t.Fatalf("wrong event. want stopped (%s) at %d, got=%+v", reason, line, ev)
+	}
+}
+
+func expectExit(t *testing.T, d *Debugger) object.Object {
+	t.Helper()
+
+	ev := <-d.Events()
+	if ev.Kind != Exited {
+		t.Fatalf("expected exit. got=%+v", ev)
+	}
+	return ev.Result
+}
+
+func TestBreakpointsAndInspection(t *testing.T) {
+	d, out := newTestDebugger(t, testSource)
+
+	if placed := d.SetBreakpoints([]int{2, 4, 100}); !reflect.DeepEqual(placed, []int{2, 5, 0}) {
+		t.Errorf("wrong breakpoint placement. got=%v", placed)
+	}
+	d.SetBreakpoints([]int{2})
+
+	d.Start(false)
+	expectStop(t, d, ReasonBreakpoint, 2)
+
+	frames, err := d.Frames()
+	if err != nil {
+		t.Fatalf("Frames returned error: %v", err)
+	}
+	if len(frames) != 2 || frames[0].Name != "add" || frames[0].Line != 2 || frames[1].Name != "main" || frames[1].Line != 5 {
+		t.Errorf("wrong frames. got=%+v", frames)
+	}
+
+	locals, err := d.Locals(0)
+	if err != nil {
+		t.Fatalf("Locals returned error: %v", err)
+	}
+	expected := []Variable{{"a", "INTEGER", "1"}, {"b", "INTEGER", "2"}}
+	if !reflect.DeepEqual(locals, expected) {
+		t.Errorf("wrong locals.\nwant=%+v\ngot= %+v", expected, locals)
+	}
+
+	locals, _ = d.Locals(1)
+	if !reflect.DeepEqual(locals, []Variable{{"add", "FUNCTION", "fn(a, b)"}}) {
+		t.Errorf("wrong main locals. got=%+v", locals)
+	}
+
+	if result, err := d.Evaluate(0, "a * 100 + b"); err != nil || result != "102" {
+		t.Errorf("wrong evaluation. got=%q, %v", result, err)
+	}
+	if _, err := d.Evaluate(0, "missing"); err == nil || err.Error() != "identifier not found: missing" {
+		t.Errorf("expected evaluation error. got=%v", err)
+	}
+	if _, err := d.Evaluate(5, "1"); err == nil {
+		t.Errorf("expected error for unknown frame")
+	}
+
+	d.Continue()
+	expectStop(t, d, ReasonBreakpoint, 2)
+
+	if result, _ := d.Evaluate(0, "a"); result != "3" {
+		t.Errorf("wrong value in second call. got=%q", result)
+	}
+
+	d.SetBreakpoints(nil)
+	d.Continue()
+	if result, ok := expectExit(t, d).(*object.Error); ok {
+		t.Errorf("unexpected error. got=%s", result.Message)
+	}
+
+	if out.String() != "3\n13\n" {
+		t.Errorf("wrong program output. got=%q", out.String())
+	}
+
+	if err := d.Continue(); err != ErrNotPaused {
+		t.Errorf("expected ErrNotPaused. got=%v", err)
+	}
+}
+
+func TestStepping(t *testing.T) {
+	d, _ := newTestDebugger(t, testSource)
+
+	d.Start(true)
+	expectStop(t, d, ReasonEntry, 1)
+
+	steps := []struct {
+		step func() error
+		line int
+	}{
+		{d.StepOver, 5},
+		{d.StepIn, 2},
+		{d.StepIn, 3},
+		{d.StepOver, 6},
+		{d.StepOver, 7},
+		{d.StepIn, 2},
+		{d.StepOut, 8},
+	}
+
+	for _, s := range steps {
+		if err := s.step(); err != nil {
+			t.Fatalf("step returned error: %v", err)
+		}
+		expectStop(t, d, ReasonStep, s.line)
+	}
+
+	d.Terminate()
+	if result := expectExit(t, d); result != nil {
+		t.Errorf("terminated program should have no result. got=%v", result)
+	}
+}
+
+func TestTasks(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		frames []string
+		output string
+	}{
+		{
+			"task",
+			"let worker = fn(n) {\n\tlet doubled = n * 2;\n\tdoubled\n};\nputs(await(spawn worker(21)));\n",
+			[]string{"worker", "task"},
+			"42\n",
+		},
+		{
+			"generator",
+			"let count = fn(n) {\n\tfor (i in range(n)) { yield i * 2 }\n};\nputs(collect(count(2)));\n",
+			[]string{"count"},
+			"[0, 2]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, terminate := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/terminate=%t", tt.name, terminate), func(t *testing.T) {
+				d, out := newTestDebugger(t, tt.src)
+				d.SetBreakpoints([]int{2})
+
+				d.Start(false)
+				expectStop(t, d, ReasonBreakpoint, 2)
+
+				frames, err := d.Frames()
+				if err != nil {
+					t.Fatalf("Frames returned error: %v", err)
+				}
+				var names []string
+				for _, frame := range frames {
+					names = append(names, frame.Name)
+				}
+				if !reflect.DeepEqual(names, tt.frames) || frames[0].Line != 2 {
+					t.Errorf("wrong frames. got=%+v", frames)
+				}
+
+				if terminate {
+					d.Terminate()
+					if result := expectExit(t, d); result != nil {
+						t.Errorf("terminated program should have no result. got=%v", result)
+					}
+					return
+				}
+
+				d.SetBreakpoints(nil)
+				d.Continue()
+				if result, ok := expectExit(t, d).(*object.Error); ok {
+					t.Errorf("unexpected error. got=%s", result.Message)
+				}
+				if out.String() != tt.output {
+					t.Errorf("wrong program output. got=%q", out.String())
+				}
+			})
+		}
+	}
+}
+
+func TestCLI(t *testing.T) {
+	d, _ := newTestDebugger(t, testSource)
+
+	in := strings.NewReader("b 2\nc\nbt\nlocals\np a + b\nf 1\nn\nlocals\nbogus\nclear\nc\n")
+	var out strings.Builder
+	evaluator.SetHost(&evaluator.Host{Stdout: &out})
+
+	if code := NewCLI(d, in, &out).Run(); code != 0 {
+		t.Errorf("wrong exit code. got=%d", code)
+	}
+
+	expected := `stopped at main.gs:1 (entry)
+   1	let add = fn(a, b) {
+(debug) breakpoint set at line 2
+(debug) stopped at main.gs:2 (breakpoint)
+   2		let sum = a + b;
+(debug) *#0 add at line 2
+ #1 main at line 5
+(debug) a = 1
+b = 2
+(debug) 3
+(debug) #1 main at line 5
+(debug) stopped at main.gs:3 (step)
+   3		sum
+(debug) a = 1
+b = 2
+sum = 3
+(debug) unknown command "bogus", type help for a list of commands
+(debug) all breakpoints cleared
+(debug) 3
+13
+program exited
+`
+	if out.String() != expected {
+		t.Errorf("wrong output.\nwant=%q\ngot= %q", expected, out.String())
+	}
+}
+
+type dapClient struct {
+	t   *testing.T
+	r   *bufio.Reader
+	w   io.Writer
+	seq int
+}
+
+func (c *dapClient) send(command string, args interface{}) {
+	c.t.Helper()
+
+	c.seq++
+	body, _ := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	if err := jsonrpc.WriteFrame(c.w, body); err != nil {
+		c.t.Fatalf("writing %s failed: %v", command, err)
+	}
+}
+
+func (c *dapClient) read() map[string]interface{} {
+	c.t.Helper()
+
+	body, err := jsonrpc.ReadFrame(c.r)
+	if err != nil {
+		c.t.Fatalf("reading message failed: %v", err)
+	}
+
+	msg := make(map[string]interface{})
+	json.Unmarshal(body, &msg)
+	return msg
+}
+
+func (c *dapClient) expect(kind, name string) map[string]interface{} {
+	c.t.Helper()
+
+	msg := c.read()
+	key := "command"
+	if kind == "event" {
+		key = "event"
+	}
+	if msg["type"] != kind || msg[key] != name {
+		c.t.Fatalf("expected %s %s. got=%v", kind, name, msg)
+	}
+	if kind == "response" && msg["success"] != true {
+		c.t.Fatalf("%s failed: %v", name, msg["message"])
+	}
+	return msg
+}
+
+func body(msg map[string]interface{}) map[string]interface{} {
+	b, _ := msg["body"].(map[string]interface{})
+	return b
+}
+
+// startDAP serves a DAP session and launches src in it.
+func startDAP(t *testing.T, src string) (*dapClient, string, chan error) {
+	t.Helper()
+
+	path := writeScript(t, src)
+
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	server := NewDAPServer(serverIn, serverOut, &evaluator.Host{})
+	done := make(chan error, 1)
+	go func() { done <- server.Serve() }()
+	t.Cleanup(func() { evaluator.SetHost(evaluator.DefaultHost()) })
+
+	c := &dapClient{t: t, r: bufio.NewReader(clientIn), w: clientOut}
+
+	c.send("initialize", map[string]string{"adapterID": "goscript"})
+	if body(c.expect("response", "initialize"))["supportsConfigurationDoneRequest"] != true {
+		t.Errorf("missing capability")
+	}
+
+	c.send("launch", map[string]interface{}{"program": path})
+	c.expect("response", "launch")
+	c.expect("event", "initialized")
+
+	return c, path, done
+}
+
+func TestDAP(t *testing.T) {
+	c, path, done := startDAP(t, testSource)
+
+	c.send("setBreakpoints", map[string]interface{}{"source": map[string]string{"path": path}, "breakpoints": []map[string]int{{"line": 2}, {"line": 99}}})
+	bps := body(c.expect("response", "setBreakpoints"))["breakpoints"].([]interface{})
+	if bps[0].(map[string]interface{})["verified"] != true || bps[1].(map[string]interface{})["verified"] != false {
+		t.Errorf("wrong breakpoint verification. got=%v", bps)
+	}
+
+	c.send("configurationDone", nil)
+	c.expect("response", "configurationDone")
+
+	stopped := body(c.expect("event", "stopped"))
+	if stopped["reason"] != "breakpoint" {
+		t.Errorf("wrong stop reason. got=%v", stopped["reason"])
+	}
+
+	c.send("stackTrace", map[string]int{"threadId": 1})
+	frames := body(c.expect("response", "stackTrace"))["stackFrames"].([]interface{})
+	top := frames[0].(map[string]interface{})
+	if len(frames) != 2 || top["name"] != "add" || top["line"] != float64(2) {
+		t.Errorf("wrong stack. got=%v", frames)
+	}
+
+	c.send("scopes", map[string]int{"frameId": 0})
+	scopes := body(c.expect("response", "scopes"))["scopes"].([]interface{})
+	ref := scopes[0].(map[string]interface{})["variablesReference"]
+
+	c.send("variables", map[string]interface{}{"variablesReference": ref})
+	vars := body(c.expect("response", "variables"))["variables"].([]interface{})
+	if len(vars) != 2 || vars[0].(map[string]interface{})["name"] != "a" || vars[0].(map[string]interface{})["value"] != "1" {
+		t.Errorf("wrong variables. got=%v", vars)
+	}
+
+	c.send("evaluate", map[string]interface{}{"expression": "a + b", "frameId": 0})
+	if body(c.expect("response", "evaluate"))["result"] != "3" {
+		t.Errorf("wrong evaluation")
+	}
+
+	c.send("setBreakpoints", map[string]interface{}{"source": map[string]string{"path": path}, "breakpoints": []int{}})
+	c.expect("response", "setBreakpoints")
+
+	c.send("continue", map[string]int{"threadId": 1})
+	c.expect("response", "continue")
+
+	output := c.expect("event", "output")
+	if body(output)["output"] != "3\n" || body(output)["category"] != "stdout" {
+		t.Errorf("wrong output event. got=%v", output)
+	}
+	c.expect("event", "output")
+	if body(c.expect("event", "exited"))["exitCode"] != float64(0) {
+		t.Errorf("wrong exit code")
+	}
+	c.expect("event", "terminated")
+
+	c.send("disconnect", nil)
+	c.expect("response", "disconnect")
+
+	if err := <-done; err != nil {
+		t.Errorf("Serve returned error: %v", err)
+	}
+}
+
+func TestDAPThreads(t *testing.T) {
+	c, path, done := startDAP(t, "let worker = fn(n) {\n\tlet doubled = n * 2;\n\tdoubled\n};\nputs(await(spawn worker(21)));\n")
+
+	c.send("setBreakpoints", map[string]interface{}{"source": map[string]string{"path": path}, "breakpoints": []map[string]int{{"line": 2}}})
+	c.expect("response", "setBreakpoints")
+	c.send("configurationDone", nil)
+	c.expect("response", "configurationDone")
+
+	stopped := body(c.expect("event", "stopped"))
+	if stopped["threadId"] != float64(2) {
+		t.Errorf("wrong stopped thread. got=%v", stopped["threadId"])
+	}
+
+	c.send("threads", nil)
+	threads := body(c.expect("response", "threads"))["threads"]
+	expected := []interface{}{
+		map[string]interface{}{"id": float64(1), "name": "main"},
+		map[string]interface{}{"id": float64(2), "name": "task"},
+	}
+	if !reflect.DeepEqual(threads, expected) {
+		t.Errorf("wrong threads. got=%v", threads)
+	}
+
+	c.send("stackTrace", map[string]int{"threadId": 1})
+	if msg := c.read(); msg["success"] != false || msg["message"] != "thread 1 is not paused" {
+		t.Errorf("expected an error for a running thread. got=%v", msg)
+	}
+
+	c.send("stackTrace", map[string]int{"threadId": 2})
+	frames := body(c.expect("response", "stackTrace"))["stackFrames"].([]interface{})
+	if len(frames) != 2 || frames[0].(map[string]interface{})["name"] != "worker" {
+		t.Errorf("wrong stack. got=%v", frames)
+	}
+
+	c.send("disconnect", nil)
+	c.expect("event", "exited")
+	c.expect("event", "terminated")
+	c.expect("response", "disconnect")
+
+	if err := <-done; err != nil {
+		t.Errorf("Serve returned error: %v", err)
+	}
+}
+
+func TestDAPErrors(t *testing.T) {
+	var in bytes.Buffer
+	for i, command := range []string{"launch", "stackTrace", "pause"} {
+		body, _ := json.Marshal(map[string]interface{}{"seq": i + 1, "type": "request", "command": command, "arguments": map[string]string{"program": "/does/not/exist.gs"}})
+		jsonrpc.WriteFrame(&in, body)
+	}
+
+	var out bytes.Buffer
+	if err := NewDAPServer(&in, &out, &evaluator.Host{}).Serve(); err != nil {
+		t.Fatalf("Serve returned error: %v", err)
+	}
+	defer evaluator.SetHost(evaluator.DefaultHost())
+
+	r := bufio.NewReader(&out)
+	expected := []string{"open /does/not/exist.gs: no such file or directory", "program is not paused", "unsupported request: pause"}
+	for _, want := range expected {
+		frame, err := jsonrpc.ReadFrame(r)
+		if err != nil {
+			t.Fatalf("ReadFrame returned error: %v", err)
+		}
+
+		var resp dapResponse
+		json.Unmarshal(frame, &resp)
+		if resp.Success || resp.Message != want {
+			t.Errorf("wrong response. want failure %q, got=%+v", want, resp)
+		}
+	}
+}
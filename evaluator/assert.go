@@ -0,0 +1,98 @@
+package evaluator
+
+import (
+	"goscript/diff"
+	"goscript/object"
+	"strings"
+)
+
+func Apply(fn object.Object, args ...object.Object) object.Object {
+	return applyFunction(fn, args)
+}
+
+func assertionFailure(format string, a ...interface{}) *object.Error {
+	err := newError(format, a...)
+	err.Assertion = true
+	return err
+}
+
+func assertionMessage(name string, args []object.Object, index int) (string, *object.Error) {
+	if len(args) <= index {
+		return name + " failed", nil
+	}
+
+	msg, ok := args[index].(*object.String)
+	if !ok {
+		return "", newError("message passed to `%s` must be STRING, got=%s", name, typeName(args[index]))
+	}
+	return name + " failed: " + msg.Value, nil
+}
+
+func assert(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	msg, err := assertionMessage("assert", args, 1)
+	if err != nil {
+		return err
+	}
+
+	if !isTruthy(args[0]) {
+		return assertionFailure("%s", msg)
+	}
+	return NULL
+}
+
+func assertEq(args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+	}
+
+	msg, err := assertionMessage("assert_eq", args, 2)
+	if err != nil {
+		return err
+	}
+
+	got, want := args[0], args[1]
+	if object.Equal(got, want) {
+		return NULL
+	}
+
+	if got.Inspect() == want.Inspect() {
+		return assertionFailure("%s\ngot:  %s %s\nwant: %s %s", msg, got.Type(), got.Inspect(), want.Type(), want.Inspect())
+	}
+	return assertionFailure("%s\n%s", msg, strings.TrimSuffix(diff.Unified("want", "got", want.Inspect()+"\n", got.Inspect()+"\n"), "\n"))
+}
+
+func assertError(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	var substring string
+	if len(args) == 2 {
+		s, ok := args[1].(*object.String)
+		if !ok {
+			return newError("second argument to `assert_error` must be STRING, got=%s", typeName(args[1]))
+		}
+		substring = s.Value
+	}
+
+	switch args[0].(type) {
+	case *object.Function, *object.Builtin:
+	default:
+		return newError("first argument to `assert_error` must be FUNCTION, got=%s", typeName(args[0]))
+	}
+
+	result := applyFunction(args[0], nil)
+	errObj, ok := result.(*object.Error)
+	if !ok {
+		return assertionFailure("assert_error failed: expected an error, got %s", result.Inspect())
+	}
+	if !strings.Contains(errObj.Message, substring) {
+		return assertionFailure("assert_error failed: error %q does not contain %q", errObj.Message, substring)
+	}
+
+	return &object.String{Value: errObj.Message}
+}
@@ -1,35 +1,31 @@
 package evaluator
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"goscript/format"
 	"goscript/object"
+	"sort"
 )
 
 var builtins = map[string]*object.Builtin{
-	"len": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-
-			switch arg := args[0].(type) {
-			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
-			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
-			default:
-				return newError("argument to `len` not supported, got %s", args[0].Type())
-			}
-		},
-	},
-
 	"first": {
+		Params: []string{"array"},
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
+			if it, ok := args[0].(*object.Iterator); ok {
+				value := it.Next()
+				it.Stop()
+				if value == nil {
+					return NULL
+				}
+				return value
+			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `first` must be ARRAY, got=%s", args[0].Type())
+				return newError("argument to `first` must be ARRAY, got=%s", typeName(args[0]))
 			}
 
 			arr := args[0].(*object.Array)
@@ -42,15 +38,23 @@ var builtins = map[string]*object.Builtin{
 	},
 
 	"last": {
+		Params: []string{"array"},
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `first` must be ARRAY, got=%s", args[0].Type())
+			arr, ok := args[0].(*object.Array)
+			if it, isIterator := args[0].(*object.Iterator); isIterator {
+				elements, err := collectAll(it)
+				if err != nil {
+					return err
+				}
+				arr, ok = &object.Array{Elements: elements}, true
+			}
+			if !ok {
+				return newError("argument to `first` must be ARRAY, got=%s", typeName(args[0]))
 			}
 
-			arr := args[0].(*object.Array)
 			length := len(arr.Elements)
 			if len(arr.Elements) > 0 {
 				return arr.Elements[length - 1]
@@ -61,12 +65,25 @@ var builtins = map[string]*object.Builtin{
 	},
 
 	"rest": {
+		Params: []string{"array"},
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
+			if it, ok := args[0].(*object.Iterator); ok {
+				skipped := false
+				return object.NewIterator(func() object.Object {
+					if !skipped {
+						skipped = true
+						if value := it.Next(); value == nil || isError(value) {
+							return value
+						}
+					}
+					return it.Next()
+				}, it.Stop)
+			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `first` must be ARRAY, got=%s", args[0].Type())
+				return newError("argument to `first` must be ARRAY, got=%s", typeName(args[0]))
 			}
 
 			arr := args[0].(*object.Array)
@@ -82,12 +99,23 @@ var builtins = map[string]*object.Builtin{
 	},
 
 	"push": {
+		Params: []string{"array", "value"},
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			if it, ok := args[0].(*object.Iterator); ok {
+				pushed := false
+				return object.NewIterator(func() object.Object {
+					if value := it.Next(); value != nil || pushed {
+						return value
+					}
+					pushed = true
+					return args[1]
+				}, it.Stop)
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `first` must be ARRAY, got=%s", args[0].Type())
+				return newError("argument to `push` must be ARRAY, got=%s", typeName(args[0]))
 			}
 
 			arr := args[0].(*object.Array)
@@ -100,13 +128,188 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 
+	"sort": {
+		Params: []string{"array"},
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if it, isIterator := args[0].(*object.Iterator); isIterator {
+				elements, err := collectAll(it)
+				if err != nil {
+					return err
+				}
+				arr, ok = &object.Array{Elements: elements}, true
+			}
+			if !ok {
+				return newError("argument to `sort` must be ARRAY, got=%s", typeName(args[0]))
+			}
+
+			for _, el := range arr.Elements {
+				if _, ok := object.Compare(arr.Elements[0], el); !ok {
+					return newError("cannot compare %s with %s", arr.Elements[0].Inspect(), el.Inspect())
+				}
+			}
+
+			sorted := make([]object.Object, len(arr.Elements))
+			copy(sorted, arr.Elements)
+
+			var err *object.Error
+			sort.SliceStable(sorted, func(i, j int) bool {
+				c, ok := object.Compare(sorted[i], sorted[j])
+				if !ok && err == nil {
+					err = newError("cannot compare %s with %s", sorted[i].Inspect(), sorted[j].Inspect())
+				}
+				return c < 0
+			})
+			if err != nil {
+				return err
+			}
+
+			return &object.Array{Elements: sorted}
+		},
+	},
+
+	"json_encode": {
+		Params: []string{"value", "indent?"},
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			var out bytes.Buffer
+			if err := jsonEncode(args[0], &out); err != nil {
+				return err
+			}
+
+			if len(args) == 2 {
+				indent, err := jsonIndent(args[1])
+				if err != nil {
+					return err
+				}
+
+				var indented bytes.Buffer
+				json.Indent(&indented, out.Bytes(), "", indent)
+				return &object.String{Value: indented.String()}
+			}
+
+			return &object.String{Value: out.String()}
+		},
+	},
+
+	"json_decode": {
+		Params: []string{"json"},
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if args[0].Type() != object.STRING_OBJ {
+				return newError("argument to `json_decode` must be STRING, got=%s", typeName(args[0]))
+			}
+
+			return jsonDecode(args[0].(*object.String).Value)
+		},
+	},
+
+	"source": {
+		Params: []string{"quote"},
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			quote, ok := args[0].(*object.Quote)
+			if !ok {
+				return newError("argument to `source` must be QUOTE, got=%s", typeName(args[0]))
+			}
+
+			return &object.String{Value: format.Node(quote.Node)}
+		},
+	},
+
 	"puts": {
+		Params: []string{"args..."},
 		Fn: func(args ...object.Object) object.Object {
+			outputMu.Lock()
+			defer outputMu.Unlock()
+
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(host.Stdout, arg.Inspect())
 			}
 
 			return NULL
 		},
 	},
+
+	"print": {
+		Params: []string{"args..."},
+		Fn: func(args ...object.Object) object.Object {
+			writeOutput(host.Stdout, args)
+			return NULL
+		},
+	},
+
+	"eprint": {
+		Params: []string{"args..."},
+		Fn: func(args ...object.Object) object.Object {
+			writeOutput(host.Stderr, args)
+			return NULL
+		},
+	},
+}
+
+// The builtins below call back into the evaluator, which itself refers to
+// the builtins table, so they cannot be part of its initializer and are
+// added to it here instead.
+func init() {
+	late := map[string]*object.Builtin{
+		"len":          {Params: []string{"arg"}, Fn: length},
+		"assert":       {Params: []string{"condition", "message?"}, Fn: assert},
+		"assert_eq":    {Params: []string{"got", "want", "message?"}, Fn: assertEq},
+		"assert_error": {Params: []string{"fn", "substring?"}, Fn: assertError},
+		"channel":      {Params: []string{"capacity?"}, Fn: channel},
+		"send":         {Params: []string{"channel", "value"}, Fn: send},
+		"recv":         {Params: []string{"channel"}, Fn: recv},
+		"close":        {Params: []string{"channel"}, Fn: closeChannel},
+		"await":        {Params: []string{"task"}, Fn: await},
+		"select":       {Params: []string{"cases", "default?"}, Fn: selectCase},
+		"range":        {Params: []string{"start", "stop?", "step?"}, Fn: rangeIterator},
+		"enumerate":    {Params: []string{"iterable"}, Fn: enumerate},
+		"take":         {Params: []string{"iterable", "n"}, Fn: take},
+		"chain":        {Params: []string{"iterables..."}, Fn: chain},
+		"next":         {Params: []string{"iterator"}, Fn: next},
+		"collect":      {Params: []string{"iterable"}, Fn: collect},
+		"str":          {Params: []string{"value"}, Fn: str},
+		"format":       {Params: []string{"format", "args..."}, Fn: formatBuiltin("format")},
+		"sprintf":      {Params: []string{"format", "args..."}, Fn: formatBuiltin("sprintf")},
+	}
+
+	for name, builtin := range late {
+		builtins[name] = builtin
+	}
+}
+
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	if builtin, ok := builtins[name]; ok {
+		return builtin, true
+	}
+
+	if b, ok := ioBuiltins[name]; ok {
+		return b.builtin, true
+	}
+
+	return nil, false
+}
+
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins)+len(ioBuiltins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	for name := range ioBuiltins {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
 }
@@ -21,6 +21,10 @@ func isError(obj object.Object) bool {
 }
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	if tracer != nil {
+		tracer.Node(node, env)
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node, env)
@@ -36,6 +40,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
+	case *ast.SpawnExpression:
+		return evalSpawnExpression(node, env)
 	case *ast.InfixExpression:
 		left := Eval(node.Left, env)
 		if isError(left) {
@@ -50,25 +56,59 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalBlockStatement(node, env)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
+	case *ast.YieldStatement:
+		return evalYieldStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if node.Pattern != nil {
+			if err := destructure(node.Pattern, val, env); err != nil {
+				return err
+			}
+		} else {
+			bind(node.Name, val, env)
+		}
+	case *ast.StructStatement:
+		return evalStructStatement(node, env)
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+	case *ast.ExportStatement:
+		return Eval(node.Statement, env)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{
+			Parameters: params,
+			Defaults:   node.Defaults,
+			Patterns:   node.Patterns,
+			Rest:       node.Rest,
+			Env:        env,
+			Body:       body,
+			Name:       node.Name,
+			Locals:     node.Locals,
+			Generator:  node.Generator,
+		}
+	case *ast.MacroLiteral:
+		return newError("macro literals can only be bound by a top-level let statement")
 	case *ast.CallExpression:
+		if isQuoteCall(node) {
+			return quote(node, env)
+		}
+
 		function := Eval(node.Function, env)
 		if isError(function) {
 			return function
@@ -78,9 +118,11 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		return callFunction(node, function, args)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
 	case *ast.ArrayLiteral:
@@ -99,6 +141,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.MemberExpression:
+		return evalMemberExpression(node, env)
+	case *ast.WithExpression:
+		return evalWithExpression(node, env)
 	}
 
 	return nil
@@ -119,7 +165,7 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newError("unknown operator: %s%s", operator, typeName(right))
 	}
 }
 
@@ -138,7 +184,7 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+		return newError("unknown operator: -%s", typeName(right))
 	}
 
 	value := right.(*object.Integer).Value
@@ -146,19 +192,25 @@ func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 }
 
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
+	if result, ok := evalOperatorMethod(operator, left, right); ok {
+		return result
+	}
+
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolean(left == right)
+		return nativeBoolean(object.Equal(left, right))
 	case operator == "!=":
-		return nativeBoolean(left != right)
-	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return nativeBoolean(!object.Equal(left, right))
+	case left.Type() != right.Type() || typeName(left) != typeName(right):
+		return newError("type mismatch: %s %s %s", typeName(left), operator, typeName(right))
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
+	case left.Type() == object.ARRAY_OBJ && right.Type() == object.ARRAY_OBJ:
+		return evalArrayInfixExpression(operator, left, right)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError("unknown operator: %s %s %s", typeName(left), operator, typeName(right))
 	}
 }
 
@@ -184,7 +236,7 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "!=":
 		return nativeBoolean(leftValue != rightValue)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError("unknown operator: %s %s %s", typeName(left), operator, typeName(right))
 	}
 }
 
@@ -195,6 +247,10 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 		return condition
 	}
 
+	if bt, ok := tracer.(BranchTracer); ok {
+		bt.Branch(ie, isTruthy(condition))
+	}
+
 	if isTruthy(condition) {
 		return Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
@@ -251,8 +307,19 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
+// A local whose slot is still empty, because its let has not run, is looked
+// up by name like an unresolved identifier, which finds any outer binding.
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-	if val, ok := env.Get(node.Value); ok {
+	scope := env
+	if b := node.Binding; b != nil {
+		if b.Global {
+			scope = env.Root()
+		} else if val := env.Slot(b.Depth, b.Slot); val != nil {
+			return val
+		}
+	}
+
+	if val, ok := scope.Get(node.Value); ok {
 		return val
 	}
 
@@ -260,6 +327,10 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return builtin
 	}
 
+	if builtin, ok := lookupIOBuiltin(node.Value); ok {
+		return builtin
+	}
+
 	return newError("identifier not found: " + node.Value)
 }
 
@@ -271,48 +342,167 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	var result []object.Object
 
 	for _, e := range exps {
+		spread, ok := e.(*ast.SpreadExpression)
+		if ok {
+			e = spread.Value
+		}
+
 		evaluated := Eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
 
-		result = append(result, evaluated)
+		if !ok {
+			result = append(result, evaluated)
+			continue
+		}
+
+		it, iterable := iterate(evaluated)
+		if !iterable {
+			return []object.Object{newError("cannot spread %s", typeName(evaluated))}
+		}
+		elements, err := collectAll(it)
+		if err != nil {
+			return []object.Object{err}
+		}
+		result = append(result, elements...)
 	}
 
 	return result
 }
 
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
-	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
-	}
-
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
-	return &object.String{Value: leftVal + rightVal}
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "<":
+		return nativeBoolean(leftVal < rightVal)
+	case ">":
+		return nativeBoolean(leftVal > rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", typeName(left), operator, typeName(right))
+	}
+}
+
+func evalArrayInfixExpression(operator string, left, right object.Object) object.Object {
+	if operator != "<" && operator != ">" {
+		return newError("unknown operator: %s %s %s", typeName(left), operator, typeName(right))
+	}
+
+	c, ok := object.Compare(left, right)
+	if !ok {
+		return newError("cannot compare %s with %s", left.Inspect(), right.Inspect())
+	}
+
+	if operator == "<" {
+		return nativeBoolean(c < 0)
+	}
+	return nativeBoolean(c > 0)
 }
 
 func applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendEnv := extendFunctionEnv(fn, args)
+		extendEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
+		if fn.Generator {
+			return newGenerator(fn, extendEnv)
+		}
 		evaluated := Eval(fn.Body, extendEnv)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
 		return fn.Fn(args...)
+	case *object.Struct:
+		return construct(fn, args)
 	default:
-		return newError("not a function: %s", fn.Type())
+		return newError("not a function: %s", typeName(fn))
+	}
+}
+
+// extendFunctionEnv binds the parameters of fn to args. A missing argument
+// takes the parameter's default value, which is evaluated in the new
+// environment so that it can refer to the parameters before it.
+func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, object.Object) {
+	if err := checkArity(fn, len(args)); err != nil {
+		return nil, err
+	}
+
+	var env *object.Environment
+	bindParam := func(paramIdx int, val object.Object) *object.Error {
+		if fn.Locals != nil {
+			env.SetSlot(paramIdx, val)
+		} else {
+			env.Set(fn.Parameters[paramIdx].Value, val)
+		}
+		if paramIdx < len(fn.Patterns) && fn.Patterns[paramIdx] != nil {
+			return destructure(fn.Patterns[paramIdx], val, env)
+		}
+		return nil
+	}
+
+	if fn.Locals != nil {
+		env = object.NewFrame(fn.Env, fn.Locals)
+	} else {
+		env = object.NewEncloseEnvironment(fn.Env)
 	}
+
+	for paramIdx := range fn.Parameters {
+		var val object.Object
+		switch {
+		case fn.Rest && paramIdx == len(fn.Parameters)-1:
+			rest := make([]object.Object, len(args)-paramIdx)
+			copy(rest, args[paramIdx:])
+			val = &object.Array{Elements: rest}
+		case paramIdx < len(args):
+			val = args[paramIdx]
+		default:
+			val = Eval(fn.Defaults[paramIdx], env)
+			if isError(val) {
+				return nil, val
+			}
+		}
+
+		if err := bindParam(paramIdx, val); err != nil {
+			return nil, err
+		}
+	}
+
+	return env, nil
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
-	env := object.NewEncloseEnvironment(fn.Env)
+// checkArity reports a call of fn with the wrong number of arguments. The
+// parser only allows defaults on trailing parameters, before any rest one.
+func checkArity(fn *object.Function, got int) *object.Error {
+	max := len(fn.Parameters)
+	if fn.Rest {
+		max--
+	}
+	min := max
+	for min > 0 && min <= len(fn.Defaults) && fn.Defaults[min-1] != nil {
+		min--
+	}
 
-	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+	var want string
+	switch {
+	case fn.Rest && got < min:
+		want = fmt.Sprintf("at least %d", min)
+	case fn.Rest || (got >= min && got <= max):
+		return nil
+	case min == max:
+		want = fmt.Sprintf("%d", min)
+	default:
+		want = fmt.Sprintf("%d to %d", min, max)
 	}
 
-	return env
+	if fn.Name == "" {
+		return newError("wrong number of arguments. got=%d, want=%s", got, want)
+	}
+	return newError("wrong number of arguments to %s. got=%d, want=%s", fn.Name, got, want)
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -324,13 +514,17 @@ func unwrapReturnValue(obj object.Object) object.Object {
 }
 
 func evalIndexExpression(left, index object.Object) object.Object {
+	if result, ok := callProtocol(left, "__index__", index); ok {
+		return result
+	}
+
 	switch{
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
-		return newError("index operator not supported: %s", left.Type())
+		return newError("index operator not supported: %s", typeName(left))
 	}
 }
 
@@ -347,43 +541,42 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 }
 
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
+	for _, keyNode := range node.Keys {
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.AsHashable(key)
 		if !ok {
-			return newError("Unusable as hash key: %s", key.Type())
+			return newError("Unusable as hash key: %s", typeName(key))
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(node.Pairs[keyNode], env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
+	key, ok := object.AsHashable(index)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newError("unusable as hash key: %s", typeName(index))
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(key)
 	if !ok {
 		return NULL
 	}
 
-	return pair.Value
-}
\ No newline at end of file
+	return value
+}
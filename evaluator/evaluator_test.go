@@ -2,10 +2,18 @@ package evaluator
 
 import (
 	"fmt"
+	"goscript/ast"
 	"goscript/lexer"
 	"goscript/object"
+	"goscript/optimize"
 	"goscript/parser"
+	"goscript/resolver"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -47,6 +55,7 @@ func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
+	resolver.Resolve(program)
 	env := object.NewEnvironment()
 
 	return Eval(program, env)
@@ -89,6 +98,23 @@ func TestEvaBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", "(1 < 2) == false", false},
 		{"(1 > 2) == true", "(1 > 2) == true", false},
 		{"(1 > 2) == false", "(1 > 2) == false", true},
+		{`"a" == "a"`, `"a" == "a"`, true},
+		{`"a" != "a"`, `"a" != "a"`, false},
+		{`"a" == "b"`, `"a" == "b"`, false},
+		{`"a" < "b"`, `"a" < "b"`, true},
+		{`"b" > "ab"`, `"b" > "ab"`, true},
+		{"[1, 2] == [1, 2]", "[1, 2] == [1, 2]", true},
+		{"[1, 2] != [1, 2]", "[1, 2] != [1, 2]", false},
+		{"[1, 2] == [2, 1]", "[1, 2] == [2, 1]", false},
+		{"[1, [2]] == [1, [2]]", "[1, [2]] == [1, [2]]", true},
+		{"[1, 2] < [1, 3]", "[1, 2] < [1, 3]", true},
+		{"[1, 2] < [1, 2, 0]", "[1, 2] < [1, 2, 0]", true},
+		{"[2] > [1, 9]", "[2] > [1, 9]", true},
+		{`{"a": 1, "b": 2} == {"b": 2, "a": 1}`, `{"a": 1, "b": 2} == {"b": 2, "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, `{"a": 1} == {"a": 2}`, false},
+		{"first([]) == first([])", "first([]) == first([])", true},
+		{`1 == "1"`, `1 == "1"`, false},
+		{`[1] != "1"`, `[1] != "1"`, true},
 	}
 
 	for _, tt := range tests {
@@ -274,6 +300,16 @@ if (10 > 1) {
 			"foobar",
 			"identifier not found: foobar",
 		},
+		{
+			`[1] < ["a"]`,
+			`[1] < ["a"]`,
+			`cannot compare [1] with [a]`,
+		},
+		{
+			`"a" < 1`,
+			`"a" < 1`,
+			"type mismatch: STRING < INTEGER",
+		},
 		{
 			`"Hello" - "World" case`,
 			`"Hello" - "World"`,
@@ -284,6 +320,11 @@ if (10 > 1) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			`{"name": "Monkey"}[[1, fn(x) { x }]];`,
+			`{"name": "Monkey"}[[1, fn(x) { x }]];`,
+			"unusable as hash key: ARRAY",
+		},
 	}
 
 	for _, tt := range tests {
@@ -372,6 +413,449 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestResolvedFrames(t *testing.T) {
+	tests := []struct {
+		testName string
+		input    string
+		expected int64
+	}{
+		{"closure", "let adder = fn(x) { fn(y) { x + y } }; adder(2)(3);", 5},
+		{"closure over a later local", "let f = fn() { let g = fn() { h() }; let h = fn() { 4 }; g() }; f();", 4},
+		{"recursion", "let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5);", 120},
+		{"let in an untaken branch", "let x = 1; let f = fn(a) { if (a) { let x = 2; } x }; f(false);", 1},
+		{"let in a taken branch", "let x = 1; let f = fn(a) { if (a) { let x = 2; } x }; f(true);", 2},
+		{"value refers to the outer binding", "let x = 1; let f = fn() { let x = x + 1; x }; f();", 2},
+		{"use before a shadowing let", "let x = 1; let f = fn() { let y = x; let x = 2; y + x }; f();", 3},
+		{"frames are not shared", "let f = fn(n) { let m = n * 2; m }; f(1) + f(2);", 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			err := testIntegerObject(testEval(tt.input), tt.expected)
+			if err != nil {
+				t.Errorf("[ERROR] %v", err)
+			}
+		})
+	}
+}
+
+func TestOptimizedPrograms(t *testing.T) {
+	tests := []struct {
+		testName string
+		input    string
+	}{
+		{"arithmetic", "let day = 60 * 60 * 24; let f = fn(n) { n * day }; f(2) + -(3 - 1);"},
+		{"dead branches", "let f = fn(x) { if (1 > 2) { return 0; } if (true) { let y = x * 2; } y }; f(4);"},
+		{"return from a pruned if", "let f = fn() { if (true) { return 1; } 2 }; f();"},
+		{"value of a pruned if", "let f = fn() { 1; if (false) { 2 } }; f();"},
+		{"constant used by a closure", "let n = 10; let add = fn(x) { x + n }; [add(1), add(2)];"},
+		{"conditional let", "let x = 1; let f = fn(c) { if (c) { let x = 2; } x }; [f(true), f(false)];"},
+		{"string constants", "let greeting = \"hello\" + \", \" + \"world\"; {greeting: !true};"},
+		{"runtime errors are kept", "let x = 5; x + true;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			want := testEval(tt.input)
+
+			program := parser.New(lexer.New(tt.input)).ParseProgram()
+			resolver.Resolve(program)
+			optimize.Program(program)
+			got := Eval(program, object.NewEnvironment())
+
+			if got.Inspect() != want.Inspect() {
+				t.Errorf("optimized program gives %s, want %s", got.Inspect(), want.Inspect())
+			}
+		})
+	}
+}
+
+func TestTasks(t *testing.T) {
+	tests := []struct {
+		testName string
+		input    string
+		expected string
+	}{
+		{"spawn and await", "let t = spawn fn() { 1 + 2 }; await(t);", "3"},
+		{"spawn a call", "let add = fn(a, b) { a + b }; await(spawn add(1, 2));", "3"},
+		{"spawn a builtin", "await(spawn len(\"four\"));", "4"},
+		{"unbuffered channel", "let ch = channel(); spawn fn() { send(ch, 5) }; recv(ch);", "5"},
+		{"buffered channel", "let ch = channel(2); send(ch, 1); send(ch, 2); close(ch); [recv(ch), recv(ch), recv(ch)];", "[1, 2, null]"},
+		{
+			"workers",
+			"let results = channel(); let worker = fn(n) { send(results, n * n) }; spawn worker(1); spawn worker(2); spawn worker(3); recv(results) + recv(results) + recv(results);",
+			"14",
+		},
+		{
+			"pipeline",
+			"let nums = channel(); let squares = channel(); spawn fn() { send(nums, 2); send(nums, 3); close(nums) }(); spawn fn() { let loop = fn() { let n = recv(nums); if (n) { send(squares, n * n); loop() } else { close(squares) } }; loop() }(); [recv(squares), recv(squares), recv(squares)];",
+			"[4, 9, null]",
+		},
+		{"select default", "let ch = channel(); select([[ch, fn(v) { v }]], fn() { \"empty\" });", "empty"},
+		{"select ready case", "let a = channel(1); let b = channel(1); send(b, 2); select([[a, fn(v) { [\"a\", v] }], [b, fn(v) { [\"b\", v] }]]);", "[b, 2]"},
+		{"select send", "let ch = channel(1); select([[ch, 7, fn() { recv(ch) }]]);", "7"},
+		{"select blocks", "let ch = channel(); spawn fn() { send(ch, 1) }(); select([[ch, fn(v) { v + 1 }]]);", "2"},
+		{"select closed channel", "let ch = channel(); close(ch); select([[ch, fn(v) { v }]]);", "null"},
+		{"errors are awaited", "await(spawn fn() { 1 + true });", "ERROR type mismatch: INTEGER + BOOLEAN"},
+		{"deadlock", "let ch = channel(); recv(ch);", "ERROR recv: deadlock: all tasks are blocked"},
+		{"deadlock between tasks", "let ch = channel(); let t = spawn fn() { recv(ch) }; await(t);", "ERROR await: deadlock: all tasks are blocked"},
+		{"send on closed channel", "let ch = channel(1); close(ch); send(ch, 1);", "ERROR send: send on closed channel"},
+		{"close closed channel", "let ch = channel(); close(ch); close(ch);", "ERROR close: close of closed channel"},
+		{"spawn a value", "spawn 1;", "ERROR cannot spawn INTEGER"},
+		{"invalid select case", "select([1]);", "ERROR case 0 passed to `select` must be [channel, handler] or [channel, value, handler], got=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			got := testEval(tt.input).Inspect()
+			if got != tt.expected {
+				t.Errorf("wrong result. got=%q, want=%q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFunctionArguments(t *testing.T) {
+	tests := []struct {
+		testName string
+		input    string
+		expected string
+	}{
+		{"too few arguments", "let add = fn(a, b) { a + b }; add(1);", "ERROR wrong number of arguments to add. got=1, want=2"},
+		{"too many arguments", "let add = fn(a, b) { a + b }; add(1, 2, 3);", "ERROR wrong number of arguments to add. got=3, want=2"},
+		{"anonymous function", "fn(a) { a }();", "ERROR wrong number of arguments. got=0, want=1"},
+		{"default value", "let f = fn(x, y = 10) { x + y }; [f(1), f(1, 2)];", "[11, 3]"},
+		{"default refers to a parameter", "let f = fn(x, y = x * 2) { y }; f(4);", "8"},
+		{"default is evaluated per call", "let f = fn(xs = []) { push(xs, 1) }; [f(), f()];", "[[1], [1]]"},
+		{"default error", "let f = fn(x = 1 + true) { x }; f();", "ERROR type mismatch: INTEGER + BOOLEAN"},
+		{"defaults arity", "let f = fn(x, y = 1, z = 2) { x }; f();", "ERROR wrong number of arguments to f. got=0, want=1 to 3"},
+		{"rest parameter", "let f = fn(first, ...others) { [first, others] }; [f(1), f(1, 2, 3)];", "[[1, []], [1, [2, 3]]]"},
+		{"rest arity", "let f = fn(a, b, ...c) { a }; f(1);", "ERROR wrong number of arguments to f. got=1, want=at least 2"},
+		{"spread", "let add = fn(a, b, c) { a + b + c }; let xs = [2, 3]; add(1, ...xs);", "6"},
+		{"spread an iterator", "let f = fn(...xs) { xs }; f(...range(3), 3);", "[0, 1, 2, 3]"},
+		{"spread into a builtin", "puts(...[]); len(...[\"abc\"]);", "3"},
+		{"spread in an array", "let xs = [2, 3]; [1, ...xs, 4];", "[1, 2, 3, 4]"},
+		{"spread arity", "let f = fn(a) { a }; f(...[1, 2]);", "ERROR wrong number of arguments to f. got=2, want=1"},
+		{"spread a non iterable", "let f = fn(a) { a }; f(...1);", "ERROR cannot spread INTEGER"},
+		{"inspect", "fn(a, b = 1, ...c) { a };", "fn(a, b = 1, ...c) {\na\n}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			got := testEval(tt.input).Inspect()
+			if got != tt.expected {
+				t.Errorf("wrong result. got=%q, want=%q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		testName string
+		input    string
+		expected string
+	}{
+		{"array", "let [a, b] = [1, 2]; a * 10 + b;", "12"},
+		{"rest", "let [head, ...tail] = [1, 2, 3]; [head, tail];", "[1, [2, 3]]"},
+		{"empty rest", "let [x, ...xs] = [1]; xs;", "[]"},
+		{"hash", `let {name, age: years} = {"name": "ann", "age": 3}; [name, years];`, "[ann, 3]"},
+		{"nested", `let [{x}, [y, z]] = [{"x": 1}, [2, 3]]; x + y + z;`, "6"},
+		{"in a function", "let f = fn() { let [a, b] = [1, 2]; a + b }; f();", "3"},
+		{"parameters", `let f = fn([a, b], {c}, d = 4) { a + b + c + d }; f([1, 2], {"c": 3});`, "10"},
+		{"closure over a parameter pattern", "let f = fn([a]) { fn() { a } }; f([7])();", "7"},
+		{"too many elements", "let [a] = [1, 2];", "ERROR expected 1 elements to destructure into [a], got 2"},
+		{"too few elements", "let [a, b, ...c] = [1];", "ERROR expected at least 2 elements to destructure into [a, b, ...c], got 1"},
+		{"not an array", "let [a] = 1;", "ERROR expected ARRAY to destructure into [a], got INTEGER"},
+		{"not a hash", "let {a} = [1];", "ERROR expected HASH to destructure into {a}, got ARRAY"},
+		{"missing key", `let {a, b} = {"a": 1};`, `ERROR missing key "b" to destructure into {a, b}`},
+		{"parameter shape", "let f = fn([a]) { a }; f(1);", "ERROR expected ARRAY to destructure into [a], got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			got := testEval(tt.input).Inspect()
+			if got != tt.expected {
+				t.Errorf("wrong result. got=%q, want=%q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	describe := `let describe = fn(x) {
+		match (x) {
+			0 => "zero",
+			-1 => "minus one",
+			n: int if n > 100 => "big",
+			int => "int",
+			s: string => "string " + s,
+			true => "yes",
+			[] => "empty",
+			[first, ..._] if first == 1 => "starts with one",
+			[a, [b, c]] => a + b + c,
+			{kind: "circle", radius} => radius * 3,
+			{kind} => "shape " + kind,
+			_ => "other",
+		}
+	};
+	`
+
+	tests := []struct {
+		testName string
+		input    string
+		expected string
+	}{
+		{"integer literal", "describe(0)", "zero"},
+		{"negative literal", "describe(-1)", "minus one"},
+		{"type with a guard", "describe(500)", "big"},
+		{"type", "describe(5)", "int"},
+		{"named type", `describe("go")`, "string go"},
+		{"boolean literal", "describe(true)", "yes"},
+		{"empty array", "describe([])", "empty"},
+		{"rest with a guard", "describe([1, 2, 3])", "starts with one"},
+		{"nested array", "describe([2, [3, 4]])", "9"},
+		{"hash with a literal", `describe({"kind": "circle", "radius": 2})`, "6"},
+		{"hash with more keys", `describe({"kind": "square", "side": 2})`, "shape square"},
+		{"wildcard", "describe(false)", "other"},
+		{"no arm matches", "match (3) { 1 => 1, 2 => 2 }", "ERROR no match for 3"},
+		{"guard error", "match (3) { n if n + true => 1 }", "ERROR type mismatch: INTEGER + BOOLEAN"},
+		{"bindings stay in the arm", "let n = 1; match (2) { n => n }; n;", "1"},
+		{"closure over a binding", "let f = match ([5]) { [n] => fn() { n } }; f();", "5"},
+		{"in a function", "let f = fn(x) { let y = 10; match (x) { [n] => n + y, _ => y } }; f([1]) + f(2);", "21"},
+		{"collection and function types", `let kind = fn(x) { match (x) { array => "array", hash => "hash", f: fn => "fn", iterator => "iterator", _ => "other" } }; [kind([1]), kind({}), kind(len), kind(fn() {}), kind(range(1)), kind(1)]`, "[array, hash, fn, fn, iterator, other]"},
+		{"struct types", `struct P { x } struct Q { x } let f = fn(v) { match (v) { p: P => p.x, _: Q => "q", _ => "other" } }; [f(P(1)), f(Q(2)), f({"x": 3})]`, "[1, q, other]"},
+		{"yield in an arm", "let g = fn(xs) { for (x in xs) { match (x) { [a, b] => if (true) { yield a + b }, _ => 0 } } }; collect(g([[1, 2], 5, [3, 4]]));", "[3, 7]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			got := testEval(describe + tt.input).Inspect()
+			if got != tt.expected {
+				t.Errorf("wrong result. got=%q, want=%q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStructs(t *testing.T) {
+	point := `struct Point {
+		x,
+		y,
+		fn add(other) { Point(self.x + other.x, self.y + other.y) },
+		fn scale(k) { self with {x: self.x * k, y: self.y * k} },
+		fn getter() { fn() { self.x } },
+	}
+	`
+
+	tests := []struct {
+		testName string
+		input    string
+		expected string
+	}{
+		{"inspect", "Point(1, 2)", "Point{x: 1, y: 2}"},
+		{"field", "Point(1, 2).y", "2"},
+		{"method", "Point(1, 2).add(Point(3, 4))", "Point{x: 4, y: 6}"},
+		{"method with with", "Point(1, 2).scale(10)", "Point{x: 10, y: 20}"},
+		{"closure over self", "let get = Point(7, 8).getter(); get();", "7"},
+		{"detached method", "let add = Point(1, 1).add; add(Point(1, 2));", "Point{x: 2, y: 3}"},
+		{"with copies", "let p = Point(1, 2); let q = p with {y: 5}; [p, q];", "[Point{x: 1, y: 2}, Point{x: 1, y: 5}]"},
+		{"equality", "[Point(1, 2) == Point(1, 2), Point(1, 2) != Point(2, 1)];", "[true, true]"},
+		{"type", "struct Empty {} let e = Empty(); [e, e == Empty()];", "[Empty{}, true]"},
+		{"inside a function", "let f = fn(n) { struct Box { v, fn get() { self.v + n } } Box(1).get() }; f(2);", "3"},
+		{"unknown field", "Point(1, 2).z", "ERROR Point has no field or method z"},
+		{"unknown field in with", "Point(1, 2) with {z: 1}", "ERROR Point has no field z"},
+		{"with on a hash", `{"x": 1} with {x: 2}`, "ERROR with needs a struct value, got HASH"},
+		{"constructor arity", "Point(1)", "ERROR wrong number of arguments to Point. got=1, want=2"},
+		{"method arity", "Point(1, 2).add()", "ERROR wrong number of arguments to add. got=0, want=1"},
+		{"type mismatch", "Point(1, 2) + 1", "ERROR type mismatch: Point + INTEGER"},
+		{"named like an array", "struct ARRAY { x } first(ARRAY(1))", "ERROR argument to `first` must be ARRAY, got=ARRAY"},
+		{"named like a string", `struct STRING { x } STRING(1) + "a"`, "ERROR type mismatch: STRING + STRING"},
+		{"named like an integer", "struct INTEGER { x } INTEGER(1) + 1", "ERROR type mismatch: INTEGER + INTEGER"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			got := testEval(point + tt.input).Inspect()
+			if got != tt.expected {
+				t.Errorf("wrong result. got=%q, want=%q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStringFormatting(t *testing.T) {
+	tests := []struct {
+		testName string
+		input    string
+		expected string
+	}{
+		{"interpolation", `let name = "Ann"; let n = 2; "Hello ${name}, you have ${n + 1} items"`, "Hello Ann, you have 3 items"},
+		{"values are inspected", `"${[1, "a"]} ${{"k": true}} ${if (false) { 1 }} ${fn(x) { x }(4)}"`, "[1, a] {k: true} null 4"},
+		{"nested strings", `let n = 1; "a${ "b${n}" + "}" }c"`, "ab1}c"},
+		{"escaped", `"$${n}"`, "${n}"},
+		{"str method", `struct P { x, fn __str__() { "P(${self.x})" } } "${P(1)}"`, "P(1)"},
+		{"errors propagate", `"a ${1 + true} b"`, "ERROR type mismatch: INTEGER + BOOLEAN"},
+		{"str", `[str(1), str("a"), str([1, "b"]), str(true)]`, "[1, a, [1, b], true]"},
+		{"str of a struct", `struct P { x } str(P(1))`, "P{x: 1}"},
+		{"str arity", `str()`, "ERROR wrong number of arguments. got=0, want=1"},
+		{"format integers", `format("%d|%5d|%-5d|%05d|%x|%X|%o|%b|%+d", 42, 42, 42, 42, 255, 255, 8, 5, 3)`, "42|   42|42   |00042|ff|FF|10|101|+3"},
+		{"format strings", `format("%s|%6s|%-6s|%.2s|%q|%v|%x", "ab", "ab", "ab", "abc", "a", [1], "hi")`, `ab|    ab|ab    |ab|"a"|[1]|6869`},
+		{"format booleans and percent", `format("%t %d%%", false, 50)`, "false 50%"},
+		{"sprintf", `sprintf("%s=%d", "x", 1)`, "x=1"},
+		{"format str method", `struct P { fn __str__() { "p" } } format("<%3s>", P())`, "<  p>"},
+		{"missing argument", `format("%d %d", 1)`, "ERROR missing argument for %d passed to `format`"},
+		{"too many arguments", `sprintf("%d", 1, 2)`, "ERROR too many arguments to `sprintf`. got=2, want=1"},
+		{"wrong type", `format("%d", "a")`, "ERROR argument for %d passed to `format` must be INTEGER, got=STRING"},
+		{"unknown verb", `format("%y", 1)`, "ERROR unknown verb %y in format string"},
+		{"unfinished verb", `format("%5", 1)`, "ERROR unfinished verb %5 in format string"},
+		{"format string", `format(1)`, "ERROR argument to `format` must be STRING, got=INTEGER"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			got := testEval(tt.input).Inspect()
+			if got != tt.expected {
+				t.Errorf("wrong result. got=%q, want=%q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOperatorOverloading(t *testing.T) {
+	vec := `struct Vec {
+		x,
+		y,
+		fn __add__(other) { Vec(self.x + other.x, self.y + other.y) },
+		fn __mul__(k) { Vec(self.x * k, self.y * k) },
+		fn __eq__(other) { self.x * self.x + self.y * self.y == other.x * other.x + other.y * other.y },
+		fn __lt__(other) { self.x < other.x },
+		fn __index__(i) { [self.x, self.y][i] },
+		fn __len__() { 2 },
+		fn __str__() { "(" + json_encode(self.x) + ", " + json_encode(self.y) + ")" },
+	}
+	struct Bad { fn __len__() { "long" }, fn __str__() { 1 } }
+	struct N {
+		n,
+		fn __add__(other) { N(self.n + other) },
+		fn __rsub__(other) { N(other - self.n) },
+		fn __eq__(other) { self.n == other },
+		fn __lt__(other) { self.n < other },
+	}
+	`
+
+	tests := []struct {
+		testName string
+		input    string
+		expected string
+	}{
+		{"add", "Vec(1, 2) + Vec(3, 4)", "(4, 6)"},
+		{"mul", "Vec(1, 2) * 3", "(3, 6)"},
+		{"eq", "Vec(3, 4) == Vec(4, 3)", "true"},
+		{"not eq", "Vec(3, 4) != Vec(4, 3)", "false"},
+		{"lt", "Vec(1, 9) < Vec(2, 0)", "true"},
+		{"gt swaps the operands", "Vec(1, 9) > Vec(2, 0)", "false"},
+		{"add with the instance on the right", "1 + N(2)", "N{n: 3}"},
+		{"sub with the instance on the right", "10 - N(3)", "N{n: 7}"},
+		{"eq with the instance on the right", "[2 == N(2), 3 == N(2), 2 != N(2)]", "[true, false, false]"},
+		{"lt with the instance on the left", "[N(1) < 5, N(5) < 5]", "[true, false]"},
+		{"lt with the instance on the right", "[1 < N(5), 5 < N(5), 9 < N(5)]", "[true, false, false]"},
+		{"gt with the instance on the left", "[N(5) > 1, N(5) > 5, N(5) > 9]", "[true, false, false]"},
+		{"gt with the instance on the right", "[9 > N(5), 1 > N(5)]", "[true, false]"},
+		{"no reflected method", "2 * N(1)", "ERROR type mismatch: INTEGER * N"},
+		{"index", "Vec(1, 2)[1]", "2"},
+		{"len", "len(Vec(1, 2))", "2"},
+		{"builtin types are unchanged", `[1 + 2, "a" + "b", len("abc"), [1][0]]`, `[3, ab, 3, 1]`},
+		{"structural equality without __eq__", "Bad() == Bad()", "true"},
+		{"missing method", "Vec(1, 2) - Vec(1, 2)", "ERROR unknown operator: Vec - Vec"},
+		{"missing index", "Bad()[0]", "ERROR index operator not supported: Bad"},
+		{"errors propagate", "Vec(1, 2) + 1", "ERROR member access not supported: INTEGER"},
+		{"len must return an integer", "len(Bad())", "ERROR __len__ of Bad must return INTEGER, got STRING"},
+		{"str must return a string", "str(Bad())", "ERROR __str__ of Bad must return STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			got := testEval(vec + tt.input).Inspect()
+			if got != tt.expected {
+				t.Errorf("wrong result. got=%q, want=%q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStrMethodInOutput(t *testing.T) {
+	var out strings.Builder
+	SetHost(&Host{Stdout: &out})
+	defer SetHost(DefaultHost())
+
+	testEval(`struct P { x, fn __str__() { "P" + json_encode(self.x) } } puts(P(1), "a"); print([P(2)], P(3));`)
+
+	if got, want := out.String(), "P1\na\n[P2] P3"; got != want {
+		t.Errorf("wrong output. got=%q, want=%q", got, want)
+	}
+}
+
+func TestIterators(t *testing.T) {
+	tests := []struct {
+		testName string
+		input    string
+		expected string
+	}{
+		{"for over an array", "let sum = 0; for (x in [1, 2, 3]) { let sum = sum + x; } sum;", "6"},
+		{"for over a string", "let s = \"\"; for (c in \"abc\") { let s = c + s; } s;", "cba"},
+		{"for over hash keys", "let ks = []; for (k in {\"a\": 1, \"b\": 2}) { let ks = push(ks, k); } ks;", "[a, b]"},
+		{"for over a channel", "let ch = channel(2); send(ch, 1); send(ch, 2); close(ch); let sum = 0; for (x in ch) { let sum = sum + x; } sum;", "3"},
+		{"return from a loop", "let find = fn(xs) { for (x in xs) { if (x > 1) { return x; } } 0 }; find([1, 2, 3]);", "2"},
+		{"loop value", "let f = fn() { for (x in []) {} }; f();", "null"},
+		{"generator", "let gen = fn() { yield 1; yield 2; }; collect(gen());", "[1, 2]"},
+		{"generator with arguments", "let count = fn(n) { for (i in range(n)) { yield i * i; } }; collect(count(4));", "[0, 1, 4, 9]"},
+		{"generator is lazy", "let ch = channel(1); let gen = fn() { send(ch, 1); yield 1; }; let g = gen(); select([[ch, fn(v) { v }]], fn() { \"not started\" });", "not started"},
+		{"infinite generator", "let nat = fn() { let loop = fn(n) { n }; for (i in range(0, 1000000000)) { yield loop(i); } }; collect(take(nat(), 3));", "[0, 1, 2]"},
+		{"next", "let gen = fn() { yield 1; }; let g = gen(); [next(g), next(g), next(g)];", "[1, null, null]"},
+		{"first stops", "let gen = fn() { yield 1; yield 1 + true; }; first(gen());", "1"},
+		{"len of an iterator", "len(range(4));", "4"},
+		{"last of an iterator", "[last(range(4)), last(range(0))];", "[3, null]"},
+		{"rest of an iterator", "let gen = fn() { yield 1; yield 2; yield 3; }; collect(rest(gen()));", "[2, 3]"},
+		{"rest is lazy", "let nat = fn() { for (i in range(0, 1000000000)) { yield i; } }; collect(take(rest(nat()), 2));", "[1, 2]"},
+		{"push onto an iterator", "collect(push(range(2), 5));", "[0, 1, 5]"},
+		{"len of a failing iterator", "let gen = fn() { yield 1; yield 1 + true; }; len(gen());", "ERROR type mismatch: INTEGER + BOOLEAN"},
+		{"generator error", "let gen = fn() { yield 1; yield 1 + true; }; collect(gen());", "ERROR type mismatch: INTEGER + BOOLEAN"},
+		{"error in the loop body", "let gen = fn() { yield 1; }; for (x in gen()) { x + true; }", "ERROR type mismatch: INTEGER + BOOLEAN"},
+		{"range", "[collect(range(3)), collect(range(2, 5)), collect(range(5, 0, -2)), collect(range(0))];", "[[0, 1, 2], [2, 3, 4], [5, 3, 1], []]"},
+		{"range step 0", "range(0, 1, 0);", "ERROR step passed to `range` must not be 0"},
+		{"enumerate", "collect(enumerate([\"a\", \"b\"]));", "[[0, a], [1, b]]"},
+		{"take", "collect(take([1, 2, 3], 2));", "[1, 2]"},
+		{"chain", "collect(chain([1], range(2, 4), \"a\"));", "[1, 2, 3, a]"},
+		{"sort an iterator", "sort(chain([3, 1], [2]));", "[1, 2, 3]"},
+		{"self iteration", "let gen = fn() { yield next(it); }; let it = gen(); next(it);", "ERROR iterator is already running"},
+		{"not iterable", "for (x in 1) {}", "ERROR cannot iterate over INTEGER"},
+		{"not iterable argument", "take(1, 2);", "ERROR argument to `take` must be iterable, got=INTEGER"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			got := testEval(tt.input).Inspect()
+			if got != tt.expected {
+				t.Errorf("wrong result. got=%q, want=%q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAbandonedGenerators(t *testing.T) {
+	before := runtime.NumGoroutine()
+	testEval("let gen = fn() { yield 1; yield 2; }; for (i in range(10)) { next(gen()); }")
+
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("abandoned generators were not stopped. goroutines before=%d, after=%d", before, n)
+	}
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 
@@ -410,6 +894,9 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("hello world")`, 11},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`len(push([1], 2))`, 2},
+		{`push([1])`, "wrong number of arguments. got=1, want=2"},
+		{`push(1, 2)`, "argument to `push` must be ARRAY, got=INTEGER"},
 	}
 
 	for _, tt := range tests {
@@ -559,26 +1046,30 @@ func TestHashLiterals(t *testing.T) {
 		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
 	}
 
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
+	expected := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{TRUE, 5},
+		{FALSE, 6},
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
 	}
 
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+	for _, tt := range expected {
+		value, ok := result.Get(tt.key)
 		if !ok {
 			t.Errorf("no pair for given key in Pairs")
+			continue
 		}
 
-		err := testIntegerObject(pair.Value, expectedValue)
+		err := testIntegerObject(value, tt.value)
 		if err != nil {
 			t.Errorf("[ERROR] %v\n", err)
 		}
@@ -626,6 +1117,26 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{[1, "a"]: 5}[[1, "a"]]`,
+			`{[1, "a"]: 5}[[1, "a"]]`,
+			5,
+		},
+		{
+			`{[1, [2, 3]]: 5}[[1, [2, 3]]]`,
+			`{[1, [2, 3]]: 5}[[1, [2, 3]]]`,
+			5,
+		},
+		{
+			`{[1, 2]: 5}[[2, 1]]`,
+			`{[1, 2]: 5}[[2, 1]]`,
+			nil,
+		},
+		{
+			`{1: 5}["1"]`,
+			`{1: 5}["1"]`,
+			nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -645,4 +1156,525 @@ func TestHashIndexExpressions(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestSortBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`sort([3, 1, 2])`, "[1, 2, 3]"},
+		{`sort(["b", "c", "a"])`, "[a, b, c]"},
+		{`sort([[2, 1], [1, 2], [1]])`, "[[1], [1, 2], [2, 1]]"},
+		{`sort([])`, "[]"},
+		{`let a = [2, 1]; sort(a); a`, "[2, 1]"},
+		{`sort([1, "a"])`, "ERROR cannot compare 1 with a"},
+		{`sort(1)`, "ERROR argument to `sort` must be ARRAY, got=INTEGER"},
+		{`struct M { n, fn __lt__(other) { self.n < other.n } } sort([M(3), M(1), M(2)])`, "[M{n: 1}, M{n: 2}, M{n: 3}]"},
+		{`struct M { n, fn __lt__(other) { self.n < other.n } } [M(1), M(2)] < [M(1), M(3)]`, "true"},
+		{`struct M { n, fn __lt__(other) { self.n < other.n } } sort([M(1), 1])`, "ERROR cannot compare M{n: 1} with 1"},
+		{`struct P { x } sort([P(2), P(1)])`, "ERROR cannot compare P{x: 2} with P{x: 2}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			evaluated := testEval(tt.input)
+			if evaluated.Inspect() != tt.expected {
+				t.Errorf("wrong result. expected=%q, got=%q", tt.expected, evaluated.Inspect())
+			}
+		})
+	}
+}
+
+func TestJSONEncode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json_encode(1)`, `1`},
+		{`json_encode("a<b")`, `"a<b"`},
+		{`json_encode(first([]))`, `null`},
+		{`json_encode([1, true, "x"])`, `[1,true,"x"]`},
+		{`json_encode({"b": 1, "a": [2]})`, `{"b":1,"a":[2]}`},
+		{`json_encode({"a": [1]}, 2)`, "{\n  \"a\": [\n    1\n  ]\n}"},
+		{`json_encode({"a": 1}, "--")`, "{\n--\"a\": 1\n}"},
+		{`json_encode(fn(x) { x })`, "ERROR json_encode: unsupported value FUNCTION"},
+		{`json_encode({1: 2})`, "ERROR json_encode: hash key must be STRING, got INTEGER"},
+		{`json_encode(1, true)`, "ERROR json_encode: indent must be INTEGER or STRING, got BOOLEAN"},
+		{`json_decode(json_encode({"a": [1, {"b": false}]}))`, "{a: [1, {b: false}]}"},
+		{`struct P { x, y } json_encode(P(1, "s"))`, `{"x":1,"y":"s"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			evaluated := testEval(tt.input)
+			if evaluated.Inspect() != tt.expected {
+				t.Errorf("wrong result. expected=%q, got=%q", tt.expected, evaluated.Inspect())
+			}
+		})
+	}
+}
+
+func TestJSONDecode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"z": 1, "a": [true, null, "s"]}`, "{z: 1, a: [true, null, s]}"},
+		{`  42 `, "42"},
+		{`{"a": 1, "a": 2}`, "{a: 2}"},
+		{`1.5`, "ERROR json_decode: unsupported number 1.5"},
+		{`[1,`, "ERROR json_decode: unexpected end of JSON input"},
+		{`1 2`, "ERROR json_decode: unexpected data after top-level value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			decoded := jsonDecode(tt.input)
+			if decoded.Inspect() != tt.expected {
+				t.Errorf("wrong result. expected=%q, got=%q", tt.expected, decoded.Inspect())
+			}
+		})
+	}
+}
+
+func writeModules(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestImport(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"lib/math.gs":     `export let double = fn(x) { x * 2 }; let hidden = 1; export let twice = fn(x) { double(double(x)) };`,
+		"lib/cycle_a.gs":  `import "cycle_b"; export let a = 1;`,
+		"lib/cycle_b.gs":  `import "cycle_a"; export let b = 1;`,
+		"vendor/ext.gs":   `import "../lib/math"; export let quad = fn(x) { math.twice(x) };`,
+		"lib/bad-name.gs": `export let x = 1;`,
+		"lib/broken.gs":   `let = 1;`,
+		"lib/shapes.gs":   `export struct Point { x, y, fn sum() { self.x + self.y } }`,
+	})
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"implicit name", `import "math"; math.double(2)`, "4"},
+		{"explicit name", `import m from "./math"; m.twice(3)`, "12"},
+		{"cached", `import a from "math"; import b from "math.gs"; a == b`, "true"},
+		{"search path", `import "ext"; ext.quad(1)`, "4"},
+		{"unexported", `import "math"; math.hidden`, "ERROR module math has no export hidden"},
+		{"not found", `import "nope"`, "ERROR module not found: nope"},
+		{"invalid name", `import "bad-name"`, `ERROR cannot use "bad-name" as a module name, use import name from "bad-name"`},
+		{"renamed invalid name", `import bad from "bad-name"; bad.x`, "1"},
+		{"member of non module", `let a = 1; a.b`, "ERROR member access not supported: INTEGER"},
+		{"exported struct", `import "shapes"; shapes.Point(1, 2).sum()`, "3"},
+		{"use before a shadowing let", `let x = 1; let f = fn() { let y = x; let x = 2; y + x }; f()`, "3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetImporter(NewImporter([]string{filepath.Join(dir, "vendor")}))
+			defer SetImporter(NewImporter(nil))
+
+			main := filepath.Join(dir, "lib", "main.gs")
+			if err := os.WriteFile(main, []byte(tt.input), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			evaluated := EvalFile(main, object.NewEnvironment())
+			if evaluated.Inspect() != tt.expected {
+				t.Errorf("wrong result. expected=%q, got=%q", tt.expected, evaluated.Inspect())
+			}
+		})
+	}
+
+	t.Run("cycle", func(t *testing.T) {
+		SetImporter(NewImporter(nil))
+		defer SetImporter(NewImporter(nil))
+
+		evaluated := EvalFile(filepath.Join(dir, "lib", "cycle_a.gs"), object.NewEnvironment())
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+		}
+		if !strings.HasPrefix(errObj.Message, "import cycle: ") || !strings.HasSuffix(errObj.Message, "cycle_a.gs") {
+			t.Errorf("wrong error message. got=%q", errObj.Message)
+		}
+	})
+
+	t.Run("parse error", func(t *testing.T) {
+		SetImporter(NewImporter(nil))
+		defer SetImporter(NewImporter(nil))
+
+		evaluated := EvalFile(filepath.Join(dir, "lib", "broken.gs"), object.NewEnvironment())
+		if !isError(evaluated) || !strings.Contains(evaluated.Inspect(), "expected next token to be IDENT") {
+			t.Errorf("wrong result. got=%q", evaluated.Inspect())
+		}
+	})
+}
+
+func TestIOBuiltins(t *testing.T) {
+	root := writeModules(t, map[string]string{
+		"data/config.txt": "hello",
+		"data/b.txt":      "",
+		"secret.txt":      "secret",
+	})
+	writable := filepath.Join(root, "out")
+	if err := os.Mkdir(writable, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "escaped.txt"), filepath.Join(writable, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("GOSCRIPT_TEST_VAR", "value")
+	defer os.Unsetenv("GOSCRIPT_TEST_VAR")
+
+	granted := Capabilities{
+		ReadRoot: filepath.Join(root, "data"),
+		WriteDir: writable,
+		Stdin:    true,
+		Env:      true,
+	}
+
+	tests := []struct {
+		name     string
+		caps     Capabilities
+		input    string
+		expected string
+	}{
+		{"read_file", granted, `read_file("config.txt")`, "hello"},
+		{"read_file escape", granted, `read_file("../secret.txt")`, "ERROR read_file: ../secret.txt is outside of " + filepath.Join(root, "data")},
+		{"read_file denied", Capabilities{}, `read_file("config.txt")`, "ERROR read_file is not permitted by the host"},
+		{"list_dir", granted, `list_dir(".")`, "[b.txt, config.txt]"},
+		{"write_file", granted, `write_file("x.txt", "data")`, "null"},
+		{"write_file escape", granted, `write_file("../x.txt", "data")`, "ERROR write_file: ../x.txt is outside of " + writable},
+		{"write_file dangling symlink", granted, `write_file("link.txt", "data")`, "ERROR write_file: link.txt is a dangling symbolic link"},
+		{"write_file denied", Capabilities{ReadRoot: root}, `write_file("x.txt", "data")`, "ERROR write_file is not permitted by the host"},
+		{"read_line", granted, `[read_line(), read_line(), read_line()]`, "[first, second, null]"},
+		{"read_line denied", Capabilities{}, `read_line()`, "ERROR read_line is not permitted by the host"},
+		{"env", granted, `[env("GOSCRIPT_TEST_VAR"), env("GOSCRIPT_UNSET_VAR")]`, "[value, null]"},
+		{"env denied", Capabilities{}, `env("GOSCRIPT_TEST_VAR")`, "ERROR env is not permitted by the host"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetHost(&Host{Stdin: strings.NewReader("first\r\nsecond\n"), Caps: tt.caps})
+			defer SetHost(DefaultHost())
+
+			evaluated := testEval(tt.input)
+			if evaluated.Inspect() != tt.expected {
+				t.Errorf("wrong result. expected=%q, got=%q", tt.expected, evaluated.Inspect())
+			}
+		})
+	}
+
+	data, err := os.ReadFile(filepath.Join(writable, "x.txt"))
+	if err != nil || string(data) != "data" {
+		t.Errorf("write_file did not write the file. got=%q, err=%v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "escaped.txt")); err == nil {
+		t.Errorf("write_file wrote through a dangling symlink")
+	}
+}
+
+func TestOutputBuiltins(t *testing.T) {
+	var stdout, stderr strings.Builder
+	SetHost(&Host{Stdout: &stdout, Stderr: &stderr})
+	defer SetHost(DefaultHost())
+
+	testEval(`puts("a", [1]); print("b", 2); eprint("c")`)
+
+	if stdout.String() != "a\n[1]\nb 2" {
+		t.Errorf("wrong stdout. got=%q", stdout.String())
+	}
+	if stderr.String() != "c" {
+		t.Errorf("wrong stderr. got=%q", stderr.String())
+	}
+}
+
+func TestAssertBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`assert(1 < 2)`, nil},
+		{`assert(1 > 2)`, "assert failed"},
+		{`assert(false, "math is broken")`, "assert failed: math is broken"},
+		{`assert(false, 1)`, "message passed to `assert` must be STRING, got=INTEGER"},
+		{`assert()`, "wrong number of arguments. got=0, want=1 or 2"},
+		{`assert_eq([1, {"a": 2}], [1, {"a": 2}])`, nil},
+		{`assert_eq(1 + 1, 3)`, "assert_eq failed\n--- want\n+++ got\n@@ -1 +1 @@\n-3\n+2"},
+		{`assert_eq("1", 1, "types")`, "assert_eq failed: types\ngot:  STRING 1\nwant: INTEGER 1"},
+		{`assert_eq(1)`, "wrong number of arguments. got=1, want=2 or 3"},
+		{`assert_error(fn() { 1 + true })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`assert_error(fn() { 1 + true }, "mismatch")`, "type mismatch: INTEGER + BOOLEAN"},
+		{`assert_error(fn() { len(1) }, "mismatch")`, "assert_error failed: error \"argument to `len` not supported, got INTEGER\" does not contain \"mismatch\""},
+		{`assert_error(fn() { [1] })`, "assert_error failed: expected an error, got [1]"},
+		{`assert_error(1)`, "first argument to `assert_error` must be FUNCTION, got=INTEGER"},
+		{`assert_error(fn() { 1 }, 2)`, "second argument to `assert_error` must be STRING, got=INTEGER"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			evaluated := testEval(tt.input)
+
+			switch expected := tt.expected.(type) {
+			case nil:
+				if evaluated != NULL {
+					t.Errorf("expected NULL. got=%T (%+v)", evaluated, evaluated)
+				}
+			case string:
+				var message string
+				switch obj := evaluated.(type) {
+				case *object.Error:
+					message = obj.Message
+				case *object.String:
+					message = obj.Value
+				default:
+					t.Fatalf("object is not Error or String. got=%T (%+v)", evaluated, evaluated)
+				}
+				if message != expected {
+					t.Errorf("wrong message. expected=%q, got=%q", expected, message)
+				}
+			}
+		})
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`let foobar = 8; quote(foobar)`, `foobar`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote("hi"))`, `hi`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`let q = quote(4 + 4); quote(unquote(4 + 4) + unquote(q))`, `(8 + (4 + 4))`},
+		{`let f = fn(x) { quote(unquote(x) + 1) }; f(1); f(2)`, `(2 + 1)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			evaluated := testEval(tt.input)
+
+			quote, ok := evaluated.(*object.Quote)
+			if !ok {
+				t.Fatalf("expected *object.Quote. got=%T (%+v)", evaluated, evaluated)
+			}
+
+			if quote.Node == nil {
+				t.Fatalf("quote.Node is nil")
+			}
+
+			if quote.Node.String() != tt.expected {
+				t.Errorf("not equal. got=%q, want=%q", quote.Node.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestQuoteErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(1, 2)`, "wrong number of arguments to quote. got=2, want=1"},
+		{`quote(unquote())`, "wrong number of arguments to unquote. got=0, want=1"},
+		{`quote(unquote([1]))`, "cannot unquote ARRAY"},
+		{`quote(unquote(x))`, "identifier not found: x"},
+		{`macro(x) { x }`, "macro literals can only be bound by a top-level let statement"},
+		{`source(1)`, "argument to `source` must be QUOTE, got=INTEGER"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			evaluated := testEval(tt.input)
+
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+			}
+			if errObj.Message != tt.expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+			}
+		})
+	}
+}
+
+func testParseProgram(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+
+	env := object.NewEnvironment()
+	program := testParseProgram(input)
+
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Wrong number of statements. got=%d", len(program.Statements))
+	}
+
+	if _, ok := env.Get("number"); ok {
+		t.Fatalf("number should not be defined")
+	}
+	if _, ok := env.Get("function"); ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment.")
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("Wrong number of macro parameters. got=%d", len(macro.Parameters))
+	}
+	if macro.Parameters[0].String() != "x" || macro.Parameters[1].String() != "y" {
+		t.Fatalf("wrong parameters. got=%v", macro.Parameters)
+	}
+
+	if macro.Body.String() != "(x + y)" {
+		t.Fatalf("body is not %q. got=%q", "(x + y)", macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		testName string
+		input    string
+		expected string
+	}{
+		{
+			"infix",
+			`let infixExpression = macro() { quote(1 + 2); }; infixExpression();`,
+			`(1 + 2)`,
+		},
+		{
+			"reverse",
+			`let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); }; reverse(2 + 2, 10 - 5);`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			"unless",
+			`let unless = macro(cond, cons, alt) {
+				quote(if (!(unquote(cond))) { unquote(cons); } else { unquote(alt); });
+			};
+			unless(10 > 5, puts("not greater"), puts("greater"));`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+		{
+			"expanded twice",
+			`let twice = macro(x) { quote(unquote(x) + unquote(x)) }; twice(1); twice(2);`,
+			`1 + 1; 2 + 2;`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			expected := testParseProgram(tt.expected)
+			program := testParseProgram(tt.input)
+
+			env := object.NewEnvironment()
+			DefineMacros(program, env)
+			if err := ExpandMacros(program, env); err != nil {
+				t.Fatalf("ExpandMacros returned error: %s", err.Message)
+			}
+
+			if program.String() != expected.String() {
+				t.Errorf("not equal. want=%q, got=%q", expected.String(), program.String())
+			}
+		})
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let m = macro(a) { quote(a) }; m(1, 2)`, "wrong number of arguments to macro m. got=2, want=1"},
+		{`let m = macro() { 1 }; m()`, "macro m must return a quoted expression, got INTEGER"},
+		{`let m = macro() { x }; m()`, "identifier not found: x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			program := testParseProgram(tt.input)
+
+			env := object.NewEnvironment()
+			DefineMacros(program, env)
+
+			err := ExpandMacros(program, env)
+			if err == nil {
+				t.Fatalf("expected error %q", tt.expected)
+			}
+			if err.Message != tt.expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, err.Message)
+			}
+		})
+	}
+}
+
+func TestMacroWithSource(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.gs")
+	src := `let assert = macro(cond) {
+	quote(if (!(unquote(cond))) { puts("assertion failed: " + unquote(source(cond))) })
+};
+let x = 1;
+assert(x + 1 == 2);
+assert(x * 2 > 3);
+`
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout strings.Builder
+	SetHost(&Host{Stdout: &stdout})
+	defer SetHost(DefaultHost())
+
+	result := EvalFile(path, object.NewEnvironment())
+	if errObj, ok := result.(*object.Error); ok {
+		t.Fatalf("EvalFile returned error: %s", errObj.Message)
+	}
+
+	if stdout.String() != "assertion failed: x * 2 > 3\n" {
+		t.Errorf("wrong output. got=%q", stdout.String())
+	}
+}
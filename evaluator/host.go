@@ -0,0 +1,250 @@
+package evaluator
+
+import (
+	"bufio"
+	"fmt"
+	"goscript/object"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Capabilities struct {
+	ReadRoot string
+	WriteDir string
+	Stdin    bool
+	Env      bool
+}
+
+type Host struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	Caps   Capabilities
+
+	lines *bufio.Reader
+}
+
+func DefaultHost() *Host {
+	return &Host{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+}
+
+var host = DefaultHost()
+
+func SetHost(h *Host) {
+	if h.Stdin == nil {
+		h.Stdin = strings.NewReader("")
+	}
+	if h.Stdout == nil {
+		h.Stdout = io.Discard
+	}
+	if h.Stderr == nil {
+		h.Stderr = io.Discard
+	}
+	host = h
+}
+
+type ioBuiltin struct {
+	builtin *object.Builtin
+	granted func(caps Capabilities) bool
+}
+
+var ioBuiltins = map[string]ioBuiltin{
+	"read_file": {
+		granted: func(caps Capabilities) bool { return caps.ReadRoot != "" },
+		builtin: &object.Builtin{
+			Params: []string{"path"},
+			Fn: func(args ...object.Object) object.Object {
+				path, err := pathArgument("read_file", args, host.Caps.ReadRoot)
+				if err != nil {
+					return err
+				}
+
+				data, ioErr := os.ReadFile(path)
+				if ioErr != nil {
+					return newError("read_file: %s", ioErr)
+				}
+
+				return &object.String{Value: string(data)}
+			},
+		},
+	},
+
+	"list_dir": {
+		granted: func(caps Capabilities) bool { return caps.ReadRoot != "" },
+		builtin: &object.Builtin{
+			Params: []string{"path"},
+			Fn: func(args ...object.Object) object.Object {
+				path, err := pathArgument("list_dir", args, host.Caps.ReadRoot)
+				if err != nil {
+					return err
+				}
+
+				entries, ioErr := os.ReadDir(path)
+				if ioErr != nil {
+					return newError("list_dir: %s", ioErr)
+				}
+
+				names := make([]string, 0, len(entries))
+				for _, entry := range entries {
+					names = append(names, entry.Name())
+				}
+				sort.Strings(names)
+
+				elements := make([]object.Object, len(names))
+				for i, name := range names {
+					elements[i] = &object.String{Value: name}
+				}
+
+				return &object.Array{Elements: elements}
+			},
+		},
+	},
+
+	"write_file": {
+		granted: func(caps Capabilities) bool { return caps.WriteDir != "" },
+		builtin: &object.Builtin{
+			Params: []string{"path", "content"},
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				content, ok := args[1].(*object.String)
+				if !ok {
+					return newError("second argument to `write_file` must be STRING, got=%s", typeName(args[1]))
+				}
+
+				path, err := pathArgument("write_file", args[:1], host.Caps.WriteDir)
+				if err != nil {
+					return err
+				}
+
+				if ioErr := os.WriteFile(path, []byte(content.Value), 0644); ioErr != nil {
+					return newError("write_file: %s", ioErr)
+				}
+
+				return NULL
+			},
+		},
+	},
+
+	"read_line": {
+		granted: func(caps Capabilities) bool { return caps.Stdin },
+		builtin: &object.Builtin{
+			Params: []string{},
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments. got=%d, want=0", len(args))
+				}
+
+				if host.lines == nil {
+					host.lines = bufio.NewReader(host.Stdin)
+				}
+
+				line, err := host.lines.ReadString('\n')
+				if err != nil && line == "" {
+					if err == io.EOF {
+						return NULL
+					}
+					return newError("read_line: %s", err)
+				}
+
+				return &object.String{Value: strings.TrimRight(line, "\r\n")}
+			},
+		},
+	},
+
+	"env": {
+		granted: func(caps Capabilities) bool { return caps.Env },
+		builtin: &object.Builtin{
+			Params: []string{"name"},
+			Fn: func(args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				name, ok := args[0].(*object.String)
+				if !ok {
+					return newError("argument to `env` must be STRING, got=%s", typeName(args[0]))
+				}
+
+				value, ok := os.LookupEnv(name.Value)
+				if !ok {
+					return NULL
+				}
+
+				return &object.String{Value: value}
+			},
+		},
+	},
+}
+
+func lookupIOBuiltin(name string) (object.Object, bool) {
+	b, ok := ioBuiltins[name]
+	if !ok {
+		return nil, false
+	}
+
+	if !b.granted(host.Caps) {
+		return newError("%s is not permitted by the host", name), true
+	}
+
+	return b.builtin, true
+}
+
+func pathArgument(name string, args []object.Object, root string) (string, *object.Error) {
+	if len(args) != 1 {
+		return "", newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	arg, ok := args[0].(*object.String)
+	if !ok {
+		return "", newError("argument to `%s` must be STRING, got=%s", name, typeName(args[0]))
+	}
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", newError("%s: %s", name, err)
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+
+	path := arg.Value
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path = filepath.Clean(path)
+
+	resolved := path
+	if p, err := filepath.EvalSymlinks(path); err == nil {
+		resolved = p
+	} else if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		// A dangling link would be followed by the write to wherever it points.
+		return "", newError("%s: %s is a dangling symbolic link", name, arg.Value)
+	} else if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		resolved = filepath.Join(dir, filepath.Base(path))
+	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", newError("%s: %s is outside of %s", name, arg.Value, root)
+	}
+
+	return resolved, nil
+}
+
+// outputMu keeps the output of tasks that print at the same time apart.
+var outputMu sync.Mutex
+
+func writeOutput(w io.Writer, args []object.Object) {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = arg.Inspect()
+	}
+
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	fmt.Fprint(w, strings.Join(parts, " "))
+}
@@ -0,0 +1,345 @@
+package evaluator
+
+import (
+	"goscript/ast"
+	"goscript/object"
+	"runtime"
+	"sync"
+)
+
+// errStopped unwinds the body of a generator that is stopped at a yield.
+var errStopped = &object.Error{Message: "generator stopped"}
+
+// A generator runs the body of a generator function in its own goroutine,
+// one yield at a time: the goroutine only runs between a resume and the next
+// step, while the caller of Next waits for that step, so the two never run
+// at the same time.
+type generator struct {
+	resume chan bool
+	steps  chan object.Object
+}
+
+// generators maps the frame of each running generator body to its generator,
+// which is how a yield finds where to send its value.
+var generators sync.Map
+
+// newGenerator returns the iterator of a call to a generator function. The
+// body does not start until the first value is asked for. An iterator that
+// is dropped before its end, as next(gen()) does, is stopped once it is
+// garbage collected, so that its body does not wait at a yield forever.
+func newGenerator(fn *object.Function, env *object.Environment) *object.Iterator {
+	g := &generator{resume: make(chan bool), steps: make(chan object.Object)}
+	started := false
+
+	it := object.NewIterator(func() object.Object {
+		if !started {
+			started = true
+			g.start(fn, env)
+		}
+		g.resume <- true
+		return <-g.steps
+	}, func() {
+		if started {
+			g.resume <- false
+			<-g.steps
+		}
+	})
+	runtime.SetFinalizer(it, func(it *object.Iterator) {
+		go it.Stop()
+	})
+	return it
+}
+
+func (g *generator) start(fn *object.Function, env *object.Environment) {
+	generators.Store(env, g)
+
+	go func() {
+		<-g.resume
+		result := runTask(fn.Name, func() object.Object {
+			return Eval(fn.Body, env)
+		})
+		generators.Delete(env)
+
+		if result != errStopped && isError(result) {
+			g.steps <- result
+			return
+		}
+		g.steps <- nil
+	}()
+}
+
+// yield hands value to the caller of Next and waits to be resumed. It
+// returns false if the generator is stopped instead.
+func (g *generator) yield(value object.Object) bool {
+	g.steps <- value
+	return <-g.resume
+}
+
+func evalYieldStatement(node *ast.YieldStatement, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	// The yield may be in the frame of a match arm inside the body.
+	for e := env; e != nil; e = e.Outer() {
+		if g, ok := generators.Load(e); ok {
+			if !g.(*generator).yield(val) {
+				return errStopped
+			}
+			return NULL
+		}
+	}
+	return newError("yield outside of a generator")
+}
+
+func evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	it, ok := iterate(iterable)
+	if !ok {
+		return newError("cannot iterate over %s", typeName(iterable))
+	}
+	defer it.Stop()
+
+	for {
+		value := it.Next()
+		if value == nil {
+			return NULL
+		}
+		if isError(value) {
+			return value
+		}
+
+		bind(node.Variable, value, env)
+
+		result := Eval(node.Body, env)
+		if result != nil {
+			if rt := result.Type(); rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+		}
+	}
+}
+
+// iterate returns an iterator over the elements of an array, the characters
+// of a string, the keys of a hash, the values received on a channel until it
+// is closed, or the values of an iterator.
+func iterate(obj object.Object) (*object.Iterator, bool) {
+	switch obj := obj.(type) {
+	case *object.Iterator:
+		return obj, true
+	case *object.Array:
+		return elements(obj.Elements), true
+	case *object.String:
+		runes := []rune(obj.Value)
+		chars := make([]object.Object, len(runes))
+		for i, r := range runes {
+			chars[i] = &object.String{Value: string(r)}
+		}
+		return elements(chars), true
+	case *object.Hash:
+		keys := make([]object.Object, 0, obj.Len())
+		for _, pair := range obj.Pairs() {
+			keys = append(keys, pair.Key)
+		}
+		return elements(keys), true
+	case *object.Channel:
+		return object.NewIterator(func() object.Object {
+			value, ok, err := obj.Recv()
+			if err != nil {
+				return newError("recv: %s", err)
+			}
+			if !ok {
+				return nil
+			}
+			return value
+		}, nil), true
+	default:
+		return nil, false
+	}
+}
+
+func elements(els []object.Object) *object.Iterator {
+	i := 0
+	return object.NewIterator(func() object.Object {
+		if i == len(els) {
+			return nil
+		}
+		i++
+		return els[i-1]
+	}, nil)
+}
+
+func iterableArgument(name string, arg object.Object) (*object.Iterator, *object.Error) {
+	it, ok := iterate(arg)
+	if !ok {
+		return nil, newError("argument to `%s` must be iterable, got=%s", name, typeName(arg))
+	}
+	return it, nil
+}
+
+// collectAll drains it into a slice, stopping at the first error.
+func collectAll(it *object.Iterator) ([]object.Object, *object.Error) {
+	var result []object.Object
+	for value := it.Next(); value != nil; value = it.Next() {
+		if err, ok := value.(*object.Error); ok {
+			return nil, err
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
+
+func integerArgument(name string, arg object.Object) (int64, *object.Error) {
+	i, ok := arg.(*object.Integer)
+	if !ok {
+		return 0, newError("argument to `%s` must be INTEGER, got=%s", name, typeName(arg))
+	}
+	return i.Value, nil
+}
+
+// rangeIterator implements range(stop) and range(start, stop, step?), which
+// count from start, or 0, up to but excluding stop.
+func rangeIterator(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newError("wrong number of arguments. got=%d, want=1 to 3", len(args))
+	}
+
+	bounds := []int64{0, 0, 1}
+	for i, arg := range args {
+		n, err := integerArgument("range", arg)
+		if err != nil {
+			return err
+		}
+		bounds[i] = n
+	}
+	if len(args) == 1 {
+		bounds[0], bounds[1] = 0, bounds[0]
+	}
+
+	current, stop, step := bounds[0], bounds[1], bounds[2]
+	if step == 0 {
+		return newError("step passed to `range` must not be 0")
+	}
+
+	return object.NewIterator(func() object.Object {
+		if (step > 0 && current >= stop) || (step < 0 && current <= stop) {
+			return nil
+		}
+		current += step
+		return &object.Integer{Value: current - step}
+	}, nil)
+}
+
+func enumerate(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	it, err := iterableArgument("enumerate", args[0])
+	if err != nil {
+		return err
+	}
+
+	var i int64
+	return object.NewIterator(func() object.Object {
+		value := it.Next()
+		if value == nil || isError(value) {
+			return value
+		}
+		i++
+		return &object.Array{Elements: []object.Object{&object.Integer{Value: i - 1}, value}}
+	}, it.Stop)
+}
+
+// take stops the iterable it takes from once it has taken n values, so a
+// generator that would run forever is not left waiting at its yield.
+func take(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	it, err := iterableArgument("take", args[0])
+	if err != nil {
+		return err
+	}
+	n, err := integerArgument("take", args[1])
+	if err != nil {
+		return err
+	}
+
+	return object.NewIterator(func() object.Object {
+		if n <= 0 {
+			it.Stop()
+			return nil
+		}
+		n--
+		return it.Next()
+	}, it.Stop)
+}
+
+func chain(args ...object.Object) object.Object {
+	its := make([]*object.Iterator, len(args))
+	for i, arg := range args {
+		it, err := iterableArgument("chain", arg)
+		if err != nil {
+			return err
+		}
+		its[i] = it
+	}
+
+	return object.NewIterator(func() object.Object {
+		for len(its) > 0 {
+			if value := its[0].Next(); value != nil {
+				return value
+			}
+			its = its[1:]
+		}
+		return nil
+	}, func() {
+		for _, it := range its {
+			it.Stop()
+		}
+	})
+}
+
+// next returns the next value of an iterator, or null once it is exhausted.
+func next(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	it, ok := args[0].(*object.Iterator)
+	if !ok {
+		return newError("argument to `next` must be ITERATOR, got=%s", typeName(args[0]))
+	}
+
+	if value := it.Next(); value != nil {
+		return value
+	}
+	return NULL
+}
+
+func collect(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	it, err := iterableArgument("collect", args[0])
+	if err != nil {
+		return err
+	}
+
+	elements, err := collectAll(it)
+	if err != nil {
+		return err
+	}
+	if elements == nil {
+		elements = []object.Object{}
+	}
+	return &object.Array{Elements: elements}
+}
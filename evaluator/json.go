@@ -0,0 +1,175 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"goscript/object"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func jsonEncode(obj object.Object, out *bytes.Buffer) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		out.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *object.Boolean:
+		out.WriteString(strconv.FormatBool(obj.Value))
+	case *object.Null:
+		out.WriteString("null")
+	case *object.String:
+		jsonEncodeString(obj.Value, out)
+	case *object.Array:
+		out.WriteString("[")
+		for i, el := range obj.Elements {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			if err := jsonEncode(el, out); err != nil {
+				return err
+			}
+		}
+		out.WriteString("]")
+	case *object.Hash:
+		out.WriteString("{")
+		for i, pair := range obj.Pairs() {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return newError("json_encode: hash key must be STRING, got %s", typeName(pair.Key))
+			}
+			if i > 0 {
+				out.WriteString(",")
+			}
+			jsonEncodeString(key.Value, out)
+			out.WriteString(":")
+			if err := jsonEncode(pair.Value, out); err != nil {
+				return err
+			}
+		}
+		out.WriteString("}")
+	case *object.Instance:
+		out.WriteString("{")
+		for i, value := range obj.Values {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			jsonEncodeString(obj.Struct.Fields[i], out)
+			out.WriteString(":")
+			if err := jsonEncode(value, out); err != nil {
+				return err
+			}
+		}
+		out.WriteString("}")
+	default:
+		return newError("json_encode: unsupported value %s", typeName(obj))
+	}
+
+	return nil
+}
+
+func jsonEncodeString(s string, out *bytes.Buffer) {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	out.Truncate(out.Len() - 1)
+}
+
+func jsonIndent(arg object.Object) (string, *object.Error) {
+	switch arg := arg.(type) {
+	case *object.Integer:
+		if arg.Value < 0 {
+			return "", newError("json_encode: indent must not be negative, got %d", arg.Value)
+		}
+		return strings.Repeat(" ", int(arg.Value)), nil
+	case *object.String:
+		return arg.Value, nil
+	default:
+		return "", newError("json_encode: indent must be INTEGER or STRING, got %s", typeName(arg))
+	}
+}
+
+func jsonDecode(input string) object.Object {
+	dec := json.NewDecoder(strings.NewReader(input))
+	dec.UseNumber()
+
+	obj, err := jsonDecodeValue(dec)
+	if err != nil {
+		return newError("json_decode: %s", err)
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return newError("json_decode: unexpected data after top-level value")
+	}
+
+	return obj
+}
+
+func jsonDecodeValue(dec *json.Decoder) (object.Object, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			return jsonDecodeArray(dec)
+		}
+		return jsonDecodeObject(dec)
+	case json.Number:
+		value, err := strconv.ParseInt(tok.String(), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported number %s", tok)
+		}
+		return &object.Integer{Value: value}, nil
+	case string:
+		return &object.String{Value: tok}, nil
+	case bool:
+		return nativeBoolean(tok), nil
+	default:
+		return NULL, nil
+	}
+}
+
+func jsonDecodeArray(dec *json.Decoder) (object.Object, error) {
+	elements := []object.Object{}
+
+	for dec.More() {
+		el, err := jsonDecodeValue(dec)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, el)
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	return &object.Array{Elements: elements}, nil
+}
+
+func jsonDecodeObject(dec *json.Decoder) (object.Object, error) {
+	hash := object.NewHash()
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := jsonDecodeValue(dec)
+		if err != nil {
+			return nil, err
+		}
+
+		hash.Set(&object.String{Value: tok.(string)}, value)
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	return hash, nil
+}
@@ -0,0 +1,115 @@
+package evaluator
+
+import (
+	"goscript/ast"
+	"goscript/object"
+)
+
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	statements := program.Statements[:0]
+
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			statements = append(statements, stmt)
+			continue
+		}
+
+		lit, ok := let.Value.(*ast.MacroLiteral)
+		if !ok || let.Name == nil {
+			statements = append(statements, stmt)
+			continue
+		}
+
+		env.Set(let.Name.Value, &object.Macro{Parameters: lit.Parameters, Body: lit.Body, Env: env})
+	}
+
+	program.Statements = statements
+}
+
+func ExpandMacros(program *ast.Program, env *object.Environment) *object.Error {
+	var errObj *object.Error
+
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || errObj != nil {
+			return node
+		}
+
+		macro, ok := lookupMacro(call, env)
+		if !ok {
+			return node
+		}
+
+		for _, arg := range call.Arguments {
+			if _, ok := arg.(*ast.SpreadExpression); ok {
+				errObj = newError("cannot spread arguments to macro %s", call.Function)
+				return node
+			}
+		}
+
+		if len(call.Arguments) != len(macro.Parameters) {
+			errObj = newError("wrong number of arguments to macro %s. got=%d, want=%d",
+				call.Function, len(call.Arguments), len(macro.Parameters))
+			return node
+		}
+
+		evaluated := unwrapReturnValue(Eval(macro.Body, extendMacroEnv(macro, quoteArgs(call))))
+		if err, ok := evaluated.(*object.Error); ok {
+			errObj = err
+			return node
+		}
+
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			errObj = newError("macro %s must return a quoted expression, got %s", call.Function, typeOf(evaluated))
+			return node
+		}
+
+		return ast.Copy(quote.Node)
+	})
+
+	return errObj
+}
+
+func lookupMacro(call *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, false
+	}
+
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
+
+func quoteArgs(call *ast.CallExpression) []*object.Quote {
+	args := []*object.Quote{}
+
+	for _, arg := range call.Arguments {
+		args = append(args, &object.Quote{Node: arg})
+	}
+
+	return args
+}
+
+func extendMacroEnv(macro *object.Macro, args []*object.Quote) *object.Environment {
+	extended := object.NewEncloseEnvironment(macro.Env)
+
+	for paramIdx, param := range macro.Parameters {
+		extended.Set(param.Value, args[paramIdx])
+	}
+
+	return extended
+}
+
+func typeOf(obj object.Object) object.ObjectType {
+	if obj == nil {
+		return object.NULL_OBJ
+	}
+	return obj.Type()
+}
@@ -0,0 +1,258 @@
+package evaluator
+
+import (
+	"goscript/ast"
+	"goscript/lexer"
+	"goscript/object"
+	"goscript/optimize"
+	"goscript/parser"
+	"goscript/resolver"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const SourceExt = ast.SourceExt
+
+type Importer struct {
+	SearchPath []string
+
+	// Optimize runs the optimiser over every module after it is resolved.
+	Optimize bool
+
+	modules map[string]*object.Module
+	loading []string
+}
+
+func NewImporter(searchPath []string) *Importer {
+	return &Importer{
+		SearchPath: searchPath,
+		modules:    make(map[string]*object.Module),
+	}
+}
+
+var importer = NewImporter(nil)
+
+func SetImporter(im *Importer) {
+	importer = im
+}
+
+func EvalFile(path string, env *object.Environment) object.Object {
+	program, errObj := ParseFile(path)
+	if errObj != nil {
+		return errObj
+	}
+
+	return EvalProgram(path, program, env)
+}
+
+func ParseFile(path string) (*ast.Program, *object.Error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, newError("%s", err)
+	}
+
+	return importer.parseFile(abs)
+}
+
+// EvalProgram evaluates program as the main module of a new run, whose tasks
+// are scheduled apart from those of earlier runs.
+func EvalProgram(path string, program *ast.Program, env *object.Environment) object.Object {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return newError("%s", err)
+	}
+
+	object.ResetScheduler()
+
+	return importer.evalModule(abs, program, env)
+}
+
+func (im *Importer) parseFile(path string) (*ast.Program, *object.Error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, newError("%s", err)
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, newError("%s: %s", path, strings.Join(p.Errors(), "; "))
+	}
+
+	macros := object.NewEnvironment()
+	DefineMacros(program, macros)
+	if errObj := ExpandMacros(program, macros); errObj != nil {
+		return nil, newError("%s: %s", path, errObj.Message)
+	}
+
+	if errs := resolver.Resolve(program); len(errs) != 0 {
+		msgs := make([]string, len(errs))
+		for i, err := range errs {
+			msgs[i] = err.Error()
+		}
+		return nil, newError("%s: %s", path, strings.Join(msgs, "; "))
+	}
+
+	if im.Optimize {
+		optimize.Program(program)
+	}
+
+	return program, nil
+}
+
+func (im *Importer) evalModule(path string, program *ast.Program, env *object.Environment) object.Object {
+	im.loading = append(im.loading, path)
+	defer func() { im.loading = im.loading[:len(im.loading)-1] }()
+
+	mt, ok := tracer.(ModuleTracer)
+	if !ok {
+		return Eval(program, env)
+	}
+
+	mt.EnterModule(path, program)
+	result := Eval(program, env)
+	mt.ExitModule(path, result)
+
+	return result
+}
+
+func (im *Importer) Import(spec string) (*object.Module, *object.Error) {
+	path, ok := im.resolve(spec)
+	if !ok {
+		return nil, newError("module not found: %s", spec)
+	}
+
+	for i, loading := range im.loading {
+		if loading == path {
+			cycle := append(append([]string{}, im.loading[i:]...), path)
+			return nil, newError("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	if mod, ok := im.modules[path]; ok {
+		return mod, nil
+	}
+
+	program, errObj := im.parseFile(path)
+	if errObj != nil {
+		return nil, errObj
+	}
+
+	env := object.NewEnvironment()
+	result := im.evalModule(path, program, env)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errObj
+	}
+
+	mod := &object.Module{
+		Name:    ast.ModuleName(path),
+		Path:    path,
+		Exports: make(map[string]object.Object),
+	}
+
+	for _, stmt := range program.Statements {
+		export, ok := stmt.(*ast.ExportStatement)
+		if !ok {
+			continue
+		}
+
+		name := export.Name().Value
+		if val, ok := env.Get(name); ok {
+			mod.Exports[name] = val
+		}
+	}
+
+	im.modules[path] = mod
+
+	return mod, nil
+}
+
+func (im *Importer) resolve(spec string) (string, bool) {
+	if filepath.Ext(spec) == "" {
+		spec += SourceExt
+	}
+
+	base := "."
+	if len(im.loading) > 0 {
+		base = filepath.Dir(im.loading[len(im.loading)-1])
+	}
+
+	dirs := []string{base}
+	if !filepath.IsAbs(spec) && !strings.HasPrefix(spec, "./") && !strings.HasPrefix(spec, "../") {
+		dirs = append(dirs, im.SearchPath...)
+	}
+
+	for _, dir := range dirs {
+		path := spec
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, spec)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			continue
+		}
+
+		return abs, true
+	}
+
+	return "", false
+}
+
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	mod, err := importer.Import(node.Path.Value)
+	if err != nil {
+		return err
+	}
+
+	name := mod.Name
+	if node.Name != nil {
+		name = node.Name.Value
+	} else if !isIdentifier(name) {
+		return newError("cannot use %q as a module name, use import name from %q", name, node.Path.Value)
+	}
+
+	env.Set(name, mod)
+
+	return nil
+}
+
+func evalMemberExpression(node *ast.MemberExpression, env *object.Environment) object.Object {
+	obj := Eval(node.Object, env)
+	if isError(obj) {
+		return obj
+	}
+
+	switch obj := obj.(type) {
+	case *object.Module:
+		val, ok := obj.Exports[node.Property.Value]
+		if !ok {
+			return newError("module %s has no export %s", obj.Name, node.Property.Value)
+		}
+		return val
+	case *object.Instance:
+		return member(obj, node.Property.Value)
+	default:
+		return newError("member access not supported: %s", typeName(obj))
+	}
+}
+
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, ch := range name {
+		if !('a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,160 @@
+package evaluator
+
+import "goscript/object"
+
+// operatorMethods names the method a struct defines to overload an infix
+// operator. != is the negation of __eq__, and < and > both use __lt__.
+var operatorMethods = map[string]string{
+	"+":  "__add__",
+	"-":  "__sub__",
+	"*":  "__mul__",
+	"/":  "__div__",
+	"==": "__eq__",
+}
+
+// reflectedMethods names the method of the right operand that is called with
+// the left one when only the right operand overloads the operator. The
+// operators whose operands commute reuse the same method.
+var reflectedMethods = map[string]string{
+	"+":  "__add__",
+	"-":  "__rsub__",
+	"*":  "__mul__",
+	"/":  "__rdiv__",
+	"==": "__eq__",
+}
+
+// protocolMethod returns the method called name of obj bound to it, if obj
+// is an instance of a struct that defines one.
+func protocolMethod(obj object.Object, name string) (object.Object, bool) {
+	inst, ok := obj.(*object.Instance)
+	if !ok {
+		return nil, false
+	}
+	if _, ok := inst.Struct.Methods[name]; !ok {
+		return nil, false
+	}
+	return member(inst, name), true
+}
+
+func callProtocol(obj object.Object, name string, args ...object.Object) (object.Object, bool) {
+	method, ok := protocolMethod(obj, name)
+	if !ok {
+		return nil, false
+	}
+	return applyFunction(method, args), true
+}
+
+func expectResult(obj object.Object, name string, result object.Object, want object.ObjectType) object.Object {
+	if isError(result) || result.Type() == want {
+		return result
+	}
+	return newError("%s of %s must return %s, got %s", name, typeName(obj), want, typeName(result))
+}
+
+// evalOperatorMethod applies the method overloading operator, if either
+// operand defines it. The left operand's method is preferred. The second
+// result is false when neither does, and the built-in rules apply instead.
+func evalOperatorMethod(operator string, left, right object.Object) (object.Object, bool) {
+	switch operator {
+	case "!=":
+		result, ok := evalOperatorMethod("==", left, right)
+		if !ok || isError(result) {
+			return result, ok
+		}
+		return nativeBoolean(!isTruthy(result)), true
+	case "<":
+		if result, ok := callProtocol(left, "__lt__", right); ok {
+			return truth(result), true
+		}
+		if _, ok := protocolMethod(right, "__lt__"); ok {
+			return greaterThan(right, left), true
+		}
+		return nil, false
+	case ">":
+		if result, ok := callProtocol(right, "__lt__", left); ok {
+			return truth(result), true
+		}
+		if _, ok := protocolMethod(left, "__lt__"); ok {
+			return greaterThan(left, right), true
+		}
+		return nil, false
+	}
+
+	name, ok := operatorMethods[operator]
+	if !ok {
+		return nil, false
+	}
+
+	result, ok := callProtocol(left, name, right)
+	if !ok {
+		result, ok = callProtocol(right, reflectedMethods[operator], left)
+	}
+	if !ok {
+		return nil, false
+	}
+	if operator == "==" {
+		return truth(result), true
+	}
+	return result, true
+}
+
+// greaterThan reports whether inst, which defines __lt__, is greater than
+// other: it is neither less than nor equal to it.
+func greaterThan(inst, other object.Object) object.Object {
+	less, _ := callProtocol(inst, "__lt__", other)
+	if isError(less) {
+		return less
+	}
+	if isTruthy(less) {
+		return FALSE
+	}
+	equal := evalInfixExpression("==", inst, other)
+	if isError(equal) {
+		return equal
+	}
+	return nativeBoolean(!isTruthy(equal))
+}
+
+func truth(result object.Object) object.Object {
+	if isError(result) {
+		return result
+	}
+	return nativeBoolean(isTruthy(result))
+}
+
+// toString converts obj to the text it is printed as, which an instance can
+// choose with a __str__ method.
+func toString(obj object.Object) object.Object {
+	if result, ok := callProtocol(obj, "__str__"); ok {
+		return expectResult(obj, "__str__", result, object.STRING_OBJ)
+	}
+	if str, ok := obj.(*object.String); ok {
+		return str
+	}
+	return &object.String{Value: obj.Inspect()}
+}
+
+// length is the len builtin, which an instance supports with a __len__
+// method. It counts the values of an iterator by exhausting it.
+func length(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.String:
+		return &object.Integer{Value: int64(len(arg.Value))}
+	case *object.Array:
+		return &object.Integer{Value: int64(len(arg.Elements))}
+	case *object.Iterator:
+		elements, err := collectAll(arg)
+		if err != nil {
+			return err
+		}
+		return &object.Integer{Value: int64(len(elements))}
+	}
+	if result, ok := callProtocol(args[0], "__len__"); ok {
+		return expectResult(args[0], "__len__", result, object.INTEGER_OBJ)
+	}
+	return newError("argument to `len` not supported, got %s", typeName(args[0]))
+}
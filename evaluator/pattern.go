@@ -0,0 +1,199 @@
+package evaluator
+
+import (
+	"goscript/ast"
+	"goscript/object"
+)
+
+// bind sets a name bound by a let, a loop or a pattern, in its slot if the
+// resolver gave it one. Binding _ does nothing.
+func bind(ident *ast.Identifier, val object.Object, env *object.Environment) {
+	if ast.IsWildcard(ident) {
+		return
+	}
+	if b := ident.Binding; b != nil && !b.Global {
+		env.SetSlot(b.Slot, val)
+	} else {
+		env.Set(ident.Value, val)
+	}
+}
+
+// destructure binds the names of pattern to the matching parts of val. The
+// shape of val must match the pattern exactly: an array pattern without a
+// rest name needs as many elements as it has, and a hash pattern needs each
+// of its keys.
+func destructure(pattern ast.Expression, val object.Object, env *object.Environment) *object.Error {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		bind(pattern, val, env)
+	case *ast.ArrayPattern:
+		arr, ok := val.(*object.Array)
+		if !ok {
+			return newError("expected ARRAY to destructure into %s, got %s", pattern, typeName(val))
+		}
+
+		n := len(pattern.Elements)
+		switch {
+		case pattern.Rest == nil && len(arr.Elements) != n:
+			return newError("expected %d elements to destructure into %s, got %d", n, pattern, len(arr.Elements))
+		case len(arr.Elements) < n:
+			return newError("expected at least %d elements to destructure into %s, got %d", n, pattern, len(arr.Elements))
+		}
+
+		for i, el := range pattern.Elements {
+			if err := destructure(el, arr.Elements[i], env); err != nil {
+				return err
+			}
+		}
+		if pattern.Rest != nil {
+			rest := make([]object.Object, len(arr.Elements)-n)
+			copy(rest, arr.Elements[n:])
+			bind(pattern.Rest, &object.Array{Elements: rest}, env)
+		}
+	case *ast.HashPattern:
+		hash, ok := val.(*object.Hash)
+		if !ok {
+			return newError("expected HASH to destructure into %s, got %s", pattern, typeName(val))
+		}
+
+		for i, key := range pattern.Keys {
+			value, ok := hash.Get(&object.String{Value: key.Value})
+			if !ok {
+				return newError("missing key %q to destructure into %s", key.Value, pattern)
+			}
+			if err := destructure(pattern.Values[i], value, env); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// patternTypes maps the types a match pattern can test for to the types of
+// the values they match. Any other type names a struct.
+var patternTypes = map[string][]object.ObjectType{
+	"int":      {object.INTEGER_OBJ},
+	"string":   {object.STRING_OBJ},
+	"bool":     {object.BOOLEAN_OBJ},
+	"null":     {object.NULL_OBJ},
+	"array":    {object.ARRAY_OBJ},
+	"hash":     {object.HASH_OBJ},
+	"iterator": {object.ITERATOR_OBJ},
+	"fn":       {object.FUNCTION_OBJ, object.BUILTIN_OBJ},
+}
+
+func hasType(val object.Object, name string) bool {
+	types, ok := patternTypes[name]
+	if !ok {
+		inst, ok := val.(*object.Instance)
+		return ok && inst.Struct.Name == name
+	}
+
+	for _, t := range types {
+		if val.Type() == t {
+			return true
+		}
+	}
+	return false
+}
+
+// evalMatchExpression tries the arms in order, each in a new frame that
+// holds the names its pattern binds, and evaluates the body of the first one
+// that matches.
+func evalMatchExpression(me *ast.MatchExpression, env *object.Environment) object.Object {
+	val := Eval(me.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	for _, arm := range me.Arms {
+		armEnv := object.NewFrame(env, arm.Locals)
+		if !match(arm.Pattern, val, armEnv) {
+			continue
+		}
+
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isError(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		return Eval(arm.Body, armEnv)
+	}
+
+	return newError("no match for %s", val.Inspect())
+}
+
+// match reports whether val has the shape of pattern, binding the names of
+// the pattern as it goes. Unlike a destructuring let, a hash pattern matches
+// hashes with more keys than it names.
+func match(pattern ast.Expression, val object.Object, env *object.Environment) bool {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		bind(pattern, val, env)
+		return true
+	case *ast.TypePattern:
+		if !hasType(val, pattern.Type) {
+			return false
+		}
+		if pattern.Name != nil {
+			bind(pattern.Name, val, env)
+		}
+		return true
+	case *ast.ArrayPattern:
+		arr, ok := val.(*object.Array)
+		if !ok {
+			return false
+		}
+
+		n := len(pattern.Elements)
+		if len(arr.Elements) < n || pattern.Rest == nil && len(arr.Elements) != n {
+			return false
+		}
+
+		for i, el := range pattern.Elements {
+			if !match(el, arr.Elements[i], env) {
+				return false
+			}
+		}
+		if pattern.Rest != nil {
+			rest := make([]object.Object, len(arr.Elements)-n)
+			copy(rest, arr.Elements[n:])
+			bind(pattern.Rest, &object.Array{Elements: rest}, env)
+		}
+		return true
+	case *ast.HashPattern:
+		hash, ok := val.(*object.Hash)
+		if !ok {
+			return false
+		}
+
+		for i, key := range pattern.Keys {
+			value, ok := hash.Get(&object.String{Value: key.Value})
+			if !ok || !match(pattern.Values[i], value, env) {
+				return false
+			}
+		}
+		return true
+	default:
+		return equalLiterals(Eval(pattern, env), val)
+	}
+}
+
+func equalLiterals(lit, val object.Object) bool {
+	switch lit := lit.(type) {
+	case *object.Integer:
+		v, ok := val.(*object.Integer)
+		return ok && v.Value == lit.Value
+	case *object.String:
+		v, ok := val.(*object.String)
+		return ok && v.Value == lit.Value
+	default:
+		return lit == val
+	}
+}
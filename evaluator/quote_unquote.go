@@ -0,0 +1,88 @@
+package evaluator
+
+import (
+	"goscript/ast"
+	"goscript/object"
+	"goscript/token"
+	"strconv"
+)
+
+func isQuoteCall(node *ast.CallExpression) bool {
+	return isCallTo(node, "quote")
+}
+
+func isUnquoteCall(node ast.Node) bool {
+	call, ok := node.(*ast.CallExpression)
+	return ok && isCallTo(call, "unquote")
+}
+
+func isCallTo(call *ast.CallExpression, name string) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == name
+}
+
+func quote(call *ast.CallExpression, env *object.Environment) object.Object {
+	if len(call.Arguments) != 1 {
+		return newError("wrong number of arguments to quote. got=%d, want=1", len(call.Arguments))
+	}
+
+	node, err := evalUnquoteCalls(ast.Copy(call.Arguments[0]), env)
+	if err != nil {
+		return err
+	}
+
+	return &object.Quote{Node: node}
+}
+
+func evalUnquoteCalls(quoted ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	var errObj *object.Error
+
+	node := ast.Modify(quoted, func(node ast.Node) ast.Node {
+		if errObj != nil || !isUnquoteCall(node) {
+			return node
+		}
+
+		call := node.(*ast.CallExpression)
+		if len(call.Arguments) != 1 {
+			errObj = newError("wrong number of arguments to unquote. got=%d, want=1", len(call.Arguments))
+			return node
+		}
+
+		unquoted := Eval(call.Arguments[0], env)
+		if err, ok := unquoted.(*object.Error); ok {
+			errObj = err
+			return node
+		}
+
+		converted := convertObjectToASTNode(unquoted, ast.Pos(call))
+		if converted == nil {
+			errObj = newError("cannot unquote %s", typeName(unquoted))
+			return node
+		}
+
+		return converted
+	})
+
+	return node, errObj
+}
+
+func convertObjectToASTNode(obj object.Object, pos token.Token) ast.Node {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.INT, Literal: strconv.FormatInt(obj.Value, 10), Line: pos.Line, Column: pos.Column}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}
+	case *object.Boolean:
+		t := token.Token{Type: token.FALSE, Literal: "false", Line: pos.Line, Column: pos.Column}
+		if obj.Value {
+			t.Type, t.Literal = token.TRUE, "true"
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value, Line: pos.Line, Column: pos.Column}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}
+	case *object.Quote:
+		return ast.Copy(obj.Node)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,128 @@
+package evaluator
+
+import (
+	"fmt"
+	"goscript/ast"
+	"goscript/object"
+	"strings"
+)
+
+func str(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	return toString(args[0])
+}
+
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out strings.Builder
+
+	for _, part := range node.Parts {
+		value := Eval(part, env)
+		if isError(value) {
+			return value
+		}
+
+		str := toString(value)
+		if isError(str) {
+			return str
+		}
+		out.WriteString(str.(*object.String).Value)
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+func formatBuiltin(name string) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if len(args) < 1 {
+			return newError("wrong number of arguments. got=%d, want=1 or more", len(args))
+		}
+		format, ok := args[0].(*object.String)
+		if !ok {
+			return newError("argument to `%s` must be STRING, got=%s", name, typeName(args[0]))
+		}
+
+		return sprintf(name, format.Value, args[1:])
+	}
+}
+
+// sprintf formats args according to a format string with the verbs of Go's
+// fmt package that make sense for script values: %d, %x, %X, %o and %b for
+// integers, %s, %v and %q for any value as str converts it, and %t for
+// booleans. A verb may have the flags -, +, 0, # and space, a width and a
+// precision.
+func sprintf(name, format string, args []object.Object) object.Object {
+	var out strings.Builder
+	used := 0
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			out.WriteByte(format[i])
+			continue
+		}
+
+		start := i
+		for i++; i < len(format) && strings.IndexByte("-+0# .0123456789", format[i]) >= 0; i++ {
+		}
+		if i == len(format) {
+			return newError("unfinished verb %s in format string", format[start:])
+		}
+
+		spec := format[start : i+1]
+		if format[i] == '%' {
+			if spec != "%%" {
+				return newError("unknown verb %s in format string", spec)
+			}
+			out.WriteByte('%')
+			continue
+		}
+
+		if used == len(args) {
+			return newError("missing argument for %s passed to `%s`", spec, name)
+		}
+		arg := args[used]
+		used++
+
+		value, err := formatValue(name, spec, arg)
+		if err != nil {
+			return err
+		}
+		out.WriteString(fmt.Sprintf(spec, value))
+	}
+
+	if used < len(args) {
+		return newError("too many arguments to `%s`. got=%d, want=%d", name, len(args), used)
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+// formatValue converts arg to the Go value that spec formats.
+func formatValue(name, spec string, arg object.Object) (interface{}, *object.Error) {
+	verb := spec[len(spec)-1]
+
+	switch verb {
+	case 'd', 'x', 'X', 'o', 'b':
+		if i, ok := arg.(*object.Integer); ok {
+			return i.Value, nil
+		}
+		if s, ok := arg.(*object.String); ok && (verb == 'x' || verb == 'X') {
+			return s.Value, nil
+		}
+		return nil, newError("argument for %s passed to `%s` must be INTEGER, got=%s", spec, name, typeName(arg))
+	case 't':
+		if b, ok := arg.(*object.Boolean); ok {
+			return b.Value, nil
+		}
+		return nil, newError("argument for %s passed to `%s` must be BOOLEAN, got=%s", spec, name, typeName(arg))
+	case 's', 'v', 'q':
+		str := toString(arg)
+		if err, ok := str.(*object.Error); ok {
+			return nil, err
+		}
+		return str.(*object.String).Value, nil
+	default:
+		return nil, newError("unknown verb %s in format string", spec)
+	}
+}
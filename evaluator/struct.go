@@ -0,0 +1,111 @@
+package evaluator
+
+import (
+	"goscript/ast"
+	"goscript/object"
+)
+
+func evalStructStatement(node *ast.StructStatement, env *object.Environment) object.Object {
+	s := &object.Struct{
+		Name:    node.Name.Value,
+		Fields:  make([]string, len(node.Fields)),
+		Methods: make(map[string]*object.Function, len(node.Methods)),
+	}
+	for i, field := range node.Fields {
+		s.Fields[i] = field.Value
+	}
+	for _, method := range node.Methods {
+		s.Methods[method.Name] = Eval(method, env).(*object.Function)
+	}
+	if _, ok := s.Methods["__str__"]; ok {
+		s.Str = func(inst *object.Instance) (string, bool) {
+			str, ok := toString(inst).(*object.String)
+			if !ok {
+				return "", false
+			}
+			return str.Value, true
+		}
+	}
+	if _, ok := s.Methods["__lt__"]; ok {
+		s.Less = func(a, b object.Object) (bool, bool) {
+			result, ok := evalOperatorMethod("<", a, b)
+			if !ok || isError(result) {
+				return false, false
+			}
+			return isTruthy(result), true
+		}
+	}
+
+	bind(node.Name, s, env)
+	return nil
+}
+
+func construct(s *object.Struct, args []object.Object) object.Object {
+	if len(args) != len(s.Fields) {
+		return newError("wrong number of arguments to %s. got=%d, want=%d", s.Name, len(args), len(s.Fields))
+	}
+
+	values := make([]object.Object, len(args))
+	copy(values, args)
+	return &object.Instance{Struct: s, Values: values}
+}
+
+// member returns the field called name of an instance, or its method of that
+// name bound to the instance. A method is bound by running it in a frame
+// whose only slot is self, which is where the resolver expects it.
+func member(inst *object.Instance, name string) object.Object {
+	if i := inst.Struct.Field(name); i >= 0 {
+		return inst.Values[i]
+	}
+
+	method, ok := inst.Struct.Methods[name]
+	if !ok {
+		return newError("%s has no field or method %s", inst.Struct.Name, name)
+	}
+
+	self := object.NewFrame(method.Env, []string{"self"})
+	self.SetSlot(0, inst)
+
+	bound := *method
+	bound.Env = self
+	return &bound
+}
+
+func evalWithExpression(node *ast.WithExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	inst, ok := left.(*object.Instance)
+	if !ok {
+		return newError("with needs a struct value, got %s", typeName(left))
+	}
+
+	values := make([]object.Object, len(inst.Values))
+	copy(values, inst.Values)
+
+	for i, field := range node.Fields {
+		j := inst.Struct.Field(field.Value)
+		if j < 0 {
+			return newError("%s has no field %s", inst.Struct.Name, field.Value)
+		}
+
+		value := Eval(node.Values[i], env)
+		if isError(value) {
+			return value
+		}
+		values[j] = value
+	}
+
+	return &object.Instance{Struct: inst.Struct, Values: values}
+}
+
+// typeName is the type of obj as errors name it, which for an instance is
+// the name of its struct.
+func typeName(obj object.Object) string {
+	if inst, ok := obj.(*object.Instance); ok {
+		return inst.Struct.Name
+	}
+	return string(obj.Type())
+}
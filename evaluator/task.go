@@ -0,0 +1,181 @@
+package evaluator
+
+import (
+	"goscript/ast"
+	"goscript/object"
+)
+
+// evalSpawnExpression evaluates the function and the arguments of a spawned
+// call in the current task, so that only the call itself runs in the new one.
+func evalSpawnExpression(node *ast.SpawnExpression, env *object.Environment) object.Object {
+	var function object.Object
+	var args []object.Object
+
+	call, ok := node.Call.(*ast.CallExpression)
+	if ok && !isQuoteCall(call) {
+		function = Eval(call.Function, env)
+		if isError(function) {
+			return function
+		}
+		args = evalExpressions(call.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+	} else {
+		call = nil
+		function = Eval(node.Call, env)
+		if isError(function) {
+			return function
+		}
+	}
+
+	switch function.(type) {
+	case *object.Function, *object.Builtin:
+	default:
+		return newError("cannot spawn %s", typeName(function))
+	}
+
+	return object.Spawn(func() object.Object {
+		return runTask("task", func() object.Object {
+			return callFunction(call, function, args)
+		})
+	})
+}
+
+func channelArgument(name string, args []object.Object, want int) (*object.Channel, *object.Error) {
+	if len(args) != want {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), want)
+	}
+
+	ch, ok := args[0].(*object.Channel)
+	if !ok {
+		return nil, newError("argument to `%s` must be CHANNEL, got=%s", name, typeName(args[0]))
+	}
+	return ch, nil
+}
+
+func channel(args ...object.Object) object.Object {
+	if len(args) > 1 {
+		return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+	}
+	if len(args) == 0 {
+		return object.NewChannel(0)
+	}
+
+	capacity, ok := args[0].(*object.Integer)
+	if !ok || capacity.Value < 0 {
+		return newError("capacity passed to `channel` must be a non-negative INTEGER, got=%s", args[0].Inspect())
+	}
+	return object.NewChannel(int(capacity.Value))
+}
+
+func send(args ...object.Object) object.Object {
+	ch, errObj := channelArgument("send", args, 2)
+	if errObj != nil {
+		return errObj
+	}
+
+	if err := ch.Send(args[1]); err != nil {
+		return newError("send: %s", err)
+	}
+	return NULL
+}
+
+// recv returns null once the channel is closed and drained.
+func recv(args ...object.Object) object.Object {
+	ch, errObj := channelArgument("recv", args, 1)
+	if errObj != nil {
+		return errObj
+	}
+
+	value, ok, err := ch.Recv()
+	if err != nil {
+		return newError("recv: %s", err)
+	}
+	if !ok {
+		return NULL
+	}
+	return value
+}
+
+func closeChannel(args ...object.Object) object.Object {
+	ch, errObj := channelArgument("close", args, 1)
+	if errObj != nil {
+		return errObj
+	}
+
+	if err := ch.Close(); err != nil {
+		return newError("close: %s", err)
+	}
+	return NULL
+}
+
+func await(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	task, ok := args[0].(*object.Task)
+	if !ok {
+		return newError("argument to `await` must be TASK, got=%s", typeName(args[0]))
+	}
+
+	result, err := task.Await()
+	if err != nil {
+		return newError("await: %s", err)
+	}
+	return result
+}
+
+// selectCase takes an array of cases: [channel, fn(value) { ... }] receives
+// from the channel and [channel, value, fn() { ... }] sends to it. The
+// handler of the first case that can proceed is called, or the default
+// handler if none can, and its result is returned. Without a default,
+// select blocks until one of the cases can proceed.
+func selectCase(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("cases passed to `select` must be ARRAY, got=%s", typeName(args[0]))
+	}
+
+	cases := make([]object.SelectCase, len(arr.Elements))
+	handlers := make([]object.Object, len(arr.Elements))
+	for i, el := range arr.Elements {
+		c, ok := el.(*object.Array)
+		if !ok || (len(c.Elements) != 2 && len(c.Elements) != 3) {
+			return newError("case %d passed to `select` must be [channel, handler] or [channel, value, handler], got=%s", i, el.Inspect())
+		}
+
+		ch, ok := c.Elements[0].(*object.Channel)
+		if !ok {
+			return newError("case %d passed to `select` must start with a CHANNEL, got=%s", i, typeName(c.Elements[0]))
+		}
+
+		cases[i] = object.SelectCase{Channel: ch}
+		if len(c.Elements) == 3 {
+			cases[i].Send = true
+			cases[i].Value = c.Elements[1]
+		}
+		handlers[i] = c.Elements[len(c.Elements)-1]
+	}
+
+	index, value, ok, err := object.Select(cases, len(args) == 1)
+	if err != nil {
+		return newError("select: %s", err)
+	}
+
+	if index < 0 {
+		return applyFunction(args[1], nil)
+	}
+	if cases[index].Send {
+		return applyFunction(handlers[index], nil)
+	}
+	if !ok {
+		value = NULL
+	}
+	return applyFunction(handlers[index], []object.Object{value})
+}
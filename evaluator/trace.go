@@ -0,0 +1,56 @@
+package evaluator
+
+import (
+	"goscript/ast"
+	"goscript/object"
+)
+
+type Tracer interface {
+	Node(node ast.Node, env *object.Environment)
+	EnterFunction(fn *object.Function, call *ast.CallExpression)
+	ExitFunction(fn *object.Function, result object.Object)
+}
+
+type ModuleTracer interface {
+	EnterModule(path string, program *ast.Program)
+	ExitModule(path string, result object.Object)
+}
+
+type BranchTracer interface {
+	Branch(ie *ast.IfExpression, taken bool)
+}
+
+// A TaskTracer runs the body of every task and generator in its goroutine,
+// so that it can tell them apart and recover there from any panic of its
+// own. name is the frame the body runs in, which is empty for the body of an
+// anonymous generator.
+type TaskTracer interface {
+	RunTask(name string, body func() object.Object) object.Object
+}
+
+var tracer Tracer
+
+func SetTracer(t Tracer) {
+	tracer = t
+}
+
+func callFunction(call *ast.CallExpression, function object.Object, args []object.Object) object.Object {
+	fn, ok := function.(*object.Function)
+	if !ok || tracer == nil {
+		return applyFunction(function, args)
+	}
+
+	t := tracer
+	t.EnterFunction(fn, call)
+	result := applyFunction(fn, args)
+	t.ExitFunction(fn, result)
+
+	return result
+}
+
+func runTask(name string, body func() object.Object) object.Object {
+	if tt, ok := tracer.(TaskTracer); ok {
+		return tt.RunTask(name, body)
+	}
+	return body()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"goscript/diff"
+	"goscript/format"
+	"io"
+	"os"
+)
+
+func fmtMain(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write result to the source file instead of standard output")
+	showDiff := flags.Bool("d", false, "display diffs instead of rewriting files")
+	check := flags.Bool("check", false, "list files whose formatting differs and exit with status 1")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return formatFile("<stdin>", src, false, *showDiff, *check)
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		if code := formatFile(path, src, *write, *showDiff, *check); code > status {
+			status = code
+		}
+	}
+
+	return status
+}
+
+func formatFile(path string, src []byte, write, showDiff, check bool) int {
+	formatted, err := format.Source(src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+		return 2
+	}
+
+	changed := !bytes.Equal(src, formatted)
+
+	switch {
+	case check:
+		if changed {
+			fmt.Println(path)
+			return 1
+		}
+	case showDiff:
+		fmt.Print(diff.Unified(path+".orig", path, string(src), string(formatted)))
+	case write:
+		if changed {
+			if err := os.WriteFile(path, formatted, 0644); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+		}
+	default:
+		os.Stdout.Write(formatted)
+	}
+
+	return 0
+}
@@ -0,0 +1,700 @@
+package format
+
+import (
+	"bytes"
+	"errors"
+	"goscript/ast"
+	"goscript/lexer"
+	"goscript/parser"
+	"goscript/token"
+	"sort"
+	"strings"
+)
+
+const (
+	_ int = iota
+	lowest
+	equals
+	lessGreater
+	sum
+	product
+	prefix
+	call
+	index
+	primary
+)
+
+var precedences = map[string]int{
+	"==": equals,
+	"!=": equals,
+	"<":  lessGreater,
+	">":  lessGreater,
+	"+":  sum,
+	"-":  sum,
+	"*":  product,
+	"/":  product,
+}
+
+func Source(src []byte) ([]byte, error) {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	pr := newPrinter(program)
+	for i, line := range strings.Split(string(src), "\n") {
+		if strings.TrimSpace(line) == "" {
+			pr.blank[i+1] = true
+		}
+	}
+
+	return []byte(pr.program(program)), nil
+}
+
+func Program(program *ast.Program) string {
+	return newPrinter(program).program(program)
+}
+
+func Node(node ast.Node) string {
+	p := &printer{blank: make(map[int]bool)}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		return p.program(node)
+	case ast.Statement:
+		p.statement(node)
+	case ast.Expression:
+		p.expression(node, lowest)
+	}
+
+	return p.out.String()
+}
+
+type printer struct {
+	out      bytes.Buffer
+	indent   int
+	comments []*ast.Comment
+	blank    map[int]bool
+	lastLine int
+}
+
+func newPrinter(program *ast.Program) *printer {
+	comments := append([]*ast.Comment{}, program.Comments...)
+	sort.SliceStable(comments, func(i, j int) bool {
+		return before(comments[i].Token, comments[j].Token)
+	})
+
+	return &printer{comments: comments, blank: make(map[int]bool)}
+}
+
+func before(a, b token.Token) bool {
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
+
+func (p *printer) program(program *ast.Program) string {
+	p.statements(program.Statements, 0)
+	p.flushComments(0, p.out.Len() == 0)
+
+	return p.out.String()
+}
+
+// statements prints stmts, which are followed in the source by a token on
+// line end, or by nothing if end is 0.
+func (p *printer) statements(stmts []ast.Statement, end int) {
+	first := true
+
+	for i, stmt := range stmts {
+		line := startLine(stmt)
+		first = p.flushComments(line, first)
+		p.separate(line, first)
+
+		p.writeIndent()
+		p.statement(stmt)
+		p.lastLine = endLine(stmt)
+		next := end
+		if i < len(stmts)-1 {
+			next = startLine(stmts[i+1])
+		}
+		p.trailingComment(next)
+		p.out.WriteString("\n")
+
+		first = false
+	}
+}
+
+func (p *printer) separate(line int, first bool) {
+	if !first && line > 0 && p.hasBlankLine(p.lastLine, line) {
+		p.out.WriteString("\n")
+	}
+}
+
+func (p *printer) hasBlankLine(from, to int) bool {
+	for l := from + 1; l < to; l++ {
+		if p.blank[l] {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *printer) flushComments(line int, first bool) bool {
+	for len(p.comments) > 0 && (line == 0 || p.comments[0].Token.Line < line) {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+
+		p.separate(c.Token.Line, first)
+		p.writeIndent()
+		p.out.WriteString(c.Text)
+		p.out.WriteString("\n")
+		p.lastLine = c.Token.Line
+
+		first = false
+	}
+
+	return first
+}
+
+// trailingComment prints the comment at the end of the last line printed,
+// unless the token that comes next in the source, which starts on line next,
+// is on that line too: a comment belongs to the last token of its line.
+func (p *printer) trailingComment(next int) {
+	if next == p.lastLine {
+		return
+	}
+	if len(p.comments) > 0 && p.comments[0].Token.Line == p.lastLine {
+		p.out.WriteString(" ")
+		p.out.WriteString(p.comments[0].Text)
+		p.comments = p.comments[1:]
+	}
+}
+
+func (p *printer) writeIndent() {
+	p.out.WriteString(strings.Repeat("\t", p.indent))
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.out.WriteString("let ")
+		if stmt.Name != nil {
+			p.out.WriteString(stmt.Name.Value)
+		} else {
+			p.expression(stmt.Pattern, lowest)
+		}
+		if stmt.Type != nil {
+			p.out.WriteString(": " + stmt.Type.String())
+		}
+		p.out.WriteString(" = ")
+		p.expression(stmt.Value, lowest)
+		p.out.WriteString(";")
+	case *ast.ReturnStatement:
+		p.out.WriteString("return")
+		if stmt.ReturnValue != nil {
+			p.out.WriteString(" ")
+			p.expression(stmt.ReturnValue, lowest)
+		}
+		p.out.WriteString(";")
+	case *ast.YieldStatement:
+		p.out.WriteString("yield ")
+		p.expression(stmt.Value, lowest)
+		p.out.WriteString(";")
+	case *ast.ForStatement:
+		p.out.WriteString("for (" + stmt.Variable.Value + " in ")
+		p.expression(stmt.Iterable, lowest)
+		p.out.WriteString(") ")
+		p.block(stmt.Body)
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression, lowest)
+		switch stmt.Expression.(type) {
+		case *ast.IfExpression, *ast.MatchExpression:
+		default:
+			p.out.WriteString(";")
+		}
+	case *ast.ImportStatement:
+		p.out.WriteString("import ")
+		if stmt.Name != nil {
+			p.out.WriteString(stmt.Name.Value)
+			p.out.WriteString(" from ")
+		}
+		p.out.WriteString(`"` + stmt.Path.Value + `"`)
+		p.out.WriteString(";")
+	case *ast.ExportStatement:
+		p.out.WriteString("export ")
+		p.statement(stmt.Statement)
+	case *ast.StructStatement:
+		p.structStatement(stmt)
+	case *ast.BlockStatement:
+		p.block(stmt)
+	}
+}
+
+// function prints the parameters, return type and body of a function
+// literal, which follow fn or the name of a method.
+func (p *printer) function(fl *ast.FunctionLiteral) {
+	p.out.WriteString("(")
+	for i, param := range fl.Parameters {
+		if i > 0 {
+			p.out.WriteString(", ")
+		}
+		if fl.Rest && i == len(fl.Parameters)-1 {
+			p.out.WriteString("...")
+		}
+		if pattern := fl.ParameterPattern(i); pattern != nil {
+			p.expression(pattern, lowest)
+		} else {
+			p.out.WriteString(param.Value)
+		}
+		if t := fl.ParameterType(i); t != nil {
+			p.out.WriteString(": " + t.String())
+		}
+		if d := fl.ParameterDefault(i); d != nil {
+			p.out.WriteString(" = ")
+			p.expression(d, lowest)
+		}
+	}
+	p.out.WriteString(")")
+	if fl.ReturnType != nil {
+		p.out.WriteString(": " + fl.ReturnType.String())
+	}
+	p.out.WriteString(" ")
+	p.block(fl.Body)
+}
+
+// structStatement prints the fields of a struct without methods on one line,
+// and otherwise each member on a line of its own.
+func (p *printer) structStatement(stmt *ast.StructStatement) {
+	p.out.WriteString("struct " + stmt.Name.Value + " ")
+
+	if len(stmt.Methods) == 0 && !p.hasCommentBefore(stmt.Rbrace.Line) {
+		if len(stmt.Fields) == 0 {
+			p.out.WriteString("{}")
+		} else {
+			fields := make([]string, len(stmt.Fields))
+			for i, field := range stmt.Fields {
+				fields[i] = field.Value
+			}
+			p.out.WriteString("{ " + strings.Join(fields, ", ") + " }")
+		}
+		p.lastLine = stmt.Rbrace.Line
+		return
+	}
+
+	members := make([]ast.Node, 0, len(stmt.Fields)+len(stmt.Methods))
+	for _, field := range stmt.Fields {
+		members = append(members, field)
+	}
+	for _, method := range stmt.Methods {
+		members = append(members, method)
+	}
+
+	next := stmt.Rbrace.Line
+	if len(members) > 0 {
+		next = startLine(members[0])
+	}
+
+	p.out.WriteString("{")
+	p.lastLine = stmt.Token.Line
+	p.trailingComment(next)
+	p.out.WriteString("\n")
+
+	p.indent++
+	for i, member := range members {
+		p.flushComments(startLine(member), i == 0)
+		p.writeIndent()
+		switch member := member.(type) {
+		case *ast.Identifier:
+			p.out.WriteString(member.Value)
+		case *ast.FunctionLiteral:
+			p.out.WriteString("fn " + member.Name)
+			p.function(member)
+		}
+		p.out.WriteString(",")
+		p.lastLine = endLine(member)
+		next = stmt.Rbrace.Line
+		if i < len(members)-1 {
+			next = startLine(members[i+1])
+		}
+		p.trailingComment(next)
+		p.out.WriteString("\n")
+	}
+	p.flushComments(stmt.Rbrace.Line, len(members) == 0)
+	p.indent--
+
+	p.writeIndent()
+	p.out.WriteString("}")
+	p.lastLine = stmt.Rbrace.Line
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	if len(block.Statements) == 0 && !p.hasCommentBefore(block.Rbrace.Line) {
+		p.out.WriteString("{}")
+		p.lastLine = block.Rbrace.Line
+		return
+	}
+
+	next := block.Rbrace.Line
+	if len(block.Statements) > 0 {
+		next = startLine(block.Statements[0])
+	}
+
+	p.out.WriteString("{")
+	p.lastLine = block.Token.Line
+	p.trailingComment(next)
+	p.out.WriteString("\n")
+
+	p.indent++
+	p.statements(block.Statements, block.Rbrace.Line)
+	if block.Rbrace.Line > 0 {
+		p.flushComments(block.Rbrace.Line, len(block.Statements) == 0)
+	}
+	p.indent--
+
+	p.writeIndent()
+	p.out.WriteString("}")
+	p.lastLine = block.Rbrace.Line
+}
+
+func (p *printer) hasCommentBefore(line int) bool {
+	return len(p.comments) > 0 && line > 0 && p.comments[0].Token.Line < line
+}
+
+func (p *printer) expression(exp ast.Expression, parent int) {
+	if precedence(exp) < parent {
+		p.out.WriteString("(")
+		defer p.out.WriteString(")")
+	}
+
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		p.out.WriteString(exp.Value)
+	case *ast.IntegerLiteral:
+		p.out.WriteString(exp.Token.Literal)
+	case *ast.Boolean:
+		p.out.WriteString(exp.Token.Literal)
+	case *ast.StringLiteral:
+		p.out.WriteString(`"` + escape(exp.Value) + `"`)
+	case *ast.InterpolatedString:
+		p.out.WriteString(`"`)
+		for _, part := range exp.Parts {
+			if text, ok := part.(*ast.StringLiteral); ok {
+				p.out.WriteString(escape(text.Value))
+				continue
+			}
+			p.out.WriteString("${")
+			p.expression(part, lowest)
+			p.out.WriteString("}")
+		}
+		p.out.WriteString(`"`)
+	case *ast.PrefixExpression:
+		p.out.WriteString(exp.Operator)
+		p.expression(exp.Right, prefix)
+	case *ast.SpawnExpression:
+		p.out.WriteString("spawn ")
+		p.expression(exp.Call, prefix)
+	case *ast.SpreadExpression:
+		p.out.WriteString("...")
+		p.expression(exp.Value, prefix)
+	case *ast.InfixExpression:
+		op := precedences[exp.Operator]
+		p.expression(exp.Left, op)
+		p.out.WriteString(" " + exp.Operator + " ")
+		p.expression(exp.Right, op+1)
+	case *ast.IfExpression:
+		p.out.WriteString("if (")
+		p.expression(exp.Condition, lowest)
+		p.out.WriteString(") ")
+		p.block(exp.Consequence)
+		if exp.Alternative != nil {
+			p.out.WriteString(" else ")
+			p.block(exp.Alternative)
+		}
+	case *ast.MatchExpression:
+		p.match(exp)
+	case *ast.FunctionLiteral:
+		p.out.WriteString("fn")
+		p.function(exp)
+	case *ast.MacroLiteral:
+		params := make([]string, len(exp.Parameters))
+		for i, param := range exp.Parameters {
+			params[i] = param.Value
+		}
+		p.out.WriteString("macro(" + strings.Join(params, ", ") + ") ")
+		p.block(exp.Body)
+	case *ast.CallExpression:
+		p.expression(exp.Function, call)
+		p.arguments(exp)
+	case *ast.ArrayLiteral:
+		p.array(exp)
+	case *ast.HashLiteral:
+		p.hash(exp)
+	case *ast.IndexExpression:
+		p.expression(exp.Left, call)
+		p.out.WriteString("[")
+		p.expression(exp.Index, lowest)
+		p.out.WriteString("]")
+	case *ast.MemberExpression:
+		p.expression(exp.Object, call)
+		p.out.WriteString(".")
+		p.out.WriteString(exp.Property.Value)
+	case *ast.WithExpression:
+		p.expression(exp.Left, call)
+		p.out.WriteString(" with {")
+		for i, field := range exp.Fields {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			p.out.WriteString(field.Value + ": ")
+			p.expression(exp.Values[i], lowest)
+		}
+		p.out.WriteString("}")
+	case *ast.ArrayPattern:
+		p.out.WriteString("[")
+		p.list(exp.Elements)
+		if exp.Rest != nil {
+			if len(exp.Elements) > 0 {
+				p.out.WriteString(", ")
+			}
+			p.out.WriteString("..." + exp.Rest.Value)
+		}
+		p.out.WriteString("]")
+	case *ast.HashPattern:
+		p.out.WriteString("{")
+		for i, key := range exp.Keys {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			p.out.WriteString(key.Value)
+			if ident, ok := exp.Values[i].(*ast.Identifier); !ok || ident.Value != key.Value {
+				p.out.WriteString(": ")
+				p.expression(exp.Values[i], lowest)
+			}
+		}
+		p.out.WriteString("}")
+	case *ast.TypePattern:
+		p.out.WriteString(exp.String())
+	}
+}
+
+// arguments prints the arguments of a call on one line, unless there are
+// comments among them, in which case each goes on a line of its own like
+// the elements of an array.
+func (p *printer) arguments(ce *ast.CallExpression) {
+	args := ce.Arguments
+	if len(args) == 0 || !p.commentWithin(ce.Token, ce.Rparen) {
+		p.out.WriteString("(")
+		p.list(args)
+		p.out.WriteString(")")
+		return
+	}
+
+	p.out.WriteString("(")
+	p.lastLine = ce.Token.Line
+	p.trailingComment(startLine(args[0]))
+	p.out.WriteString("\n")
+
+	p.indent++
+	for i, arg := range args {
+		p.flushComments(startLine(arg), i == 0)
+		p.writeIndent()
+		p.expression(arg, lowest)
+		if i < len(args)-1 {
+			p.out.WriteString(",")
+		}
+		p.lastLine = endLine(arg)
+		p.trailingComment(nextLine(args, i+1, ce.Rparen.Line))
+		p.out.WriteString("\n")
+	}
+	p.flushComments(ce.Rparen.Line, false)
+	p.indent--
+	p.writeIndent()
+	p.out.WriteString(")")
+}
+
+// nextLine returns the line on which exps[i] starts, or end if there are no
+// more expressions.
+func nextLine(exps []ast.Expression, i, end int) int {
+	if i < len(exps) {
+		return startLine(exps[i])
+	}
+	return end
+}
+
+// commentWithin reports whether the next comment lies between the tokens
+// from and to.
+func (p *printer) commentWithin(from, to token.Token) bool {
+	return len(p.comments) > 0 && to.Line > 0 && before(from, p.comments[0].Token) && before(p.comments[0].Token, to)
+}
+
+func (p *printer) list(exps []ast.Expression) {
+	for i, exp := range exps {
+		if i > 0 {
+			p.out.WriteString(", ")
+		}
+		p.expression(exp, lowest)
+	}
+}
+
+// escape writes the text of a string literal so that it reads back as
+// text rather than as an interpolation.
+func escape(text string) string {
+	return strings.ReplaceAll(text, "${", "$${")
+}
+
+func (p *printer) array(array *ast.ArrayLiteral) {
+	if len(array.Elements) == 0 || startLine(array.Elements[len(array.Elements)-1]) <= array.Token.Line {
+		p.out.WriteString("[")
+		p.list(array.Elements)
+		p.out.WriteString("]")
+		return
+	}
+
+	p.out.WriteString("[\n")
+	p.indent++
+	for i, el := range array.Elements {
+		p.flushComments(startLine(el), i == 0)
+		p.writeIndent()
+		p.expression(el, lowest)
+		if i < len(array.Elements)-1 {
+			p.out.WriteString(",")
+		}
+		p.lastLine = endLine(el)
+		p.trailingComment(nextLine(array.Elements, i+1, 0))
+		p.out.WriteString("\n")
+	}
+	p.indent--
+	p.writeIndent()
+	p.out.WriteString("]")
+}
+
+// match prints one arm per line, each ending in a comma.
+func (p *printer) match(me *ast.MatchExpression) {
+	p.out.WriteString("match (")
+	p.expression(me.Value, lowest)
+	p.out.WriteString(") ")
+
+	if len(me.Arms) == 0 && !p.hasCommentBefore(me.Rbrace.Line) {
+		p.out.WriteString("{}")
+		p.lastLine = me.Rbrace.Line
+		return
+	}
+
+	next := me.Rbrace.Line
+	if len(me.Arms) > 0 {
+		next = startLine(me.Arms[0])
+	}
+
+	p.out.WriteString("{")
+	p.lastLine = me.Token.Line
+	p.trailingComment(next)
+	p.out.WriteString("\n")
+
+	p.indent++
+	for i, arm := range me.Arms {
+		p.flushComments(startLine(arm), i == 0)
+		p.writeIndent()
+		p.expression(arm.Pattern, lowest)
+		if arm.Guard != nil {
+			p.out.WriteString(" if ")
+			p.expression(arm.Guard, lowest)
+		}
+		p.out.WriteString(" => ")
+		p.expression(arm.Body, lowest)
+		p.out.WriteString(",")
+		p.lastLine = endLine(arm)
+		next = me.Rbrace.Line
+		if i < len(me.Arms)-1 {
+			next = startLine(me.Arms[i+1])
+		}
+		p.trailingComment(next)
+		p.out.WriteString("\n")
+	}
+	p.flushComments(me.Rbrace.Line, len(me.Arms) == 0)
+	p.indent--
+
+	p.writeIndent()
+	p.out.WriteString("}")
+	p.lastLine = me.Rbrace.Line
+}
+
+func (p *printer) hash(hash *ast.HashLiteral) {
+	if len(hash.Keys) == 0 || startLine(hash.Keys[len(hash.Keys)-1]) <= hash.Token.Line {
+		p.out.WriteString("{")
+		for i, key := range hash.Keys {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			p.expression(key, lowest)
+			p.out.WriteString(": ")
+			p.expression(hash.Pairs[key], lowest)
+		}
+		p.out.WriteString("}")
+		return
+	}
+
+	p.out.WriteString("{\n")
+	p.indent++
+	for i, key := range hash.Keys {
+		p.flushComments(startLine(key), i == 0)
+		p.writeIndent()
+		p.expression(key, lowest)
+		p.out.WriteString(": ")
+		p.expression(hash.Pairs[key], lowest)
+		p.out.WriteString(",")
+		p.lastLine = endLine(hash.Pairs[key])
+		p.trailingComment(nextLine(hash.Keys, i+1, 0))
+		p.out.WriteString("\n")
+	}
+	p.indent--
+	p.writeIndent()
+	p.out.WriteString("}")
+}
+
+func precedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return precedences[exp.Operator]
+	case *ast.PrefixExpression, *ast.SpawnExpression, *ast.SpreadExpression:
+		return prefix
+	case *ast.CallExpression:
+		return call
+	case *ast.IndexExpression, *ast.MemberExpression, *ast.WithExpression:
+		return index
+	default:
+		return primary
+	}
+}
+
+func startLine(node ast.Node) int {
+	return ast.Pos(node).Line
+}
+
+func endLine(node ast.Node) int {
+	line := 0
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		if l := startLine(n); l > line {
+			line = l
+		}
+		if block, ok := n.(*ast.BlockStatement); ok && block.Rbrace.Line > line {
+			line = block.Rbrace.Line
+		}
+		if me, ok := n.(*ast.MatchExpression); ok && me.Rbrace.Line > line {
+			line = me.Rbrace.Line
+		}
+		if ss, ok := n.(*ast.StructStatement); ok && ss.Rbrace.Line > line {
+			line = ss.Rbrace.Line
+		}
+		if ce, ok := n.(*ast.CallExpression); ok && ce.Rparen.Line > line {
+			line = ce.Rparen.Line
+		}
+		return true
+	})
+
+	return line
+}
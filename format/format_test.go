@@ -0,0 +1,197 @@
+package format
+
+import (
+	"goscript/ast"
+	"goscript/lexer"
+	"goscript/parser"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			"let statements",
+			"let x=5;let y = (x+2)*3 ;",
+			"let x = 5;\nlet y = (x + 2) * 3;\n",
+		},
+		{
+			"function literal",
+			"let add=fn(a,b){return a+b}",
+			"let add = fn(a, b) {\n\treturn a + b;\n};\n",
+		},
+		{
+			"if else",
+			"if(x>1){puts(x)}else{puts(0);}",
+			"if (x > 1) {\n\tputs(x);\n} else {\n\tputs(0);\n}\n",
+		},
+		{
+			"type annotations",
+			"let n:int=1;let f=fn(a:[int],b){a}; let g = fn(h:{string:fn(int):bool}):fn():null{}",
+			"let n: int = 1;\nlet f = fn(a: [int], b) {\n\ta;\n};\nlet g = fn(h: {string: fn(int): bool}): fn(): null {};\n",
+		},
+		{
+			"spawn",
+			"let t=spawn fetch(url);let u = spawn fn(){ 1 } ;await(spawn (f)(1))",
+			"let t = spawn fetch(url);\nlet u = spawn fn() {\n\t1;\n};\nawait(spawn f(1));\n",
+		},
+		{
+			"generators",
+			"let count=fn(n){for(i in range(n)){yield i*2}};for (x in count(3)) { puts(x) }",
+			"let count = fn(n) {\n\tfor (i in range(n)) {\n\t\tyield i * 2;\n\t}\n};\nfor (x in count(3)) {\n\tputs(x);\n}\n",
+		},
+		{
+			"default and rest parameters",
+			"let f=fn(a,b=1+2,...c){[a,b,...c]};f(...[1,2],...g(x)+y)",
+			"let f = fn(a, b = 1 + 2, ...c) {\n\t[a, b, ...c];\n};\nf(...[1, 2], ...(g(x) + y));\n",
+		},
+		{
+			"destructuring",
+			"let [a,b,...c]=xs;let {name,age:years}=h;let f=fn([x,{y}],z){x}",
+			"let [a, b, ...c] = xs;\nlet {name, age: years} = h;\nlet f = fn([x, {y}], z) {\n\tx;\n};\n",
+		},
+		{
+			"match",
+			"match(x){0=>\"zero\",\n[a,...b] if a>1=>b,\nn:int=>n // int\n,_=>match (y) {}}",
+			"match (x) {\n\t0 => \"zero\",\n\t[a, ...b] if a > 1 => b,\n\tn: int => n, // int\n\t_ => match (y) {},\n}\n",
+		},
+		{
+			"interpolated strings",
+			`let s="${ a+1 }: ${f( "x" )} $${b}";let t="$${}"`,
+			"let s = \"${a + 1}: ${f(\"x\")} $${b}\";\nlet t = \"$${}\";\n",
+		},
+		{
+			"structs",
+			"struct Empty{}struct Point{x,y}struct Box{v,fn get(k){self.v*k}}let q=(p with{x:1}) with {y:a+b}",
+			"struct Empty {}\nstruct Point { x, y }\nstruct Box {\n\tv,\n\tfn get(k) {\n\t\tself.v * k;\n\t},\n}\nlet q = p with {x: 1} with {y: a + b};\n",
+		},
+		{
+			"empty blocks",
+			"let f = fn() {}; if (x) { }",
+			"let f = fn() {};\nif (x) {}\n",
+		},
+		{
+			"parentheses",
+			"(a - (b - c)) * -(d + e) - (f * g) + h[0](i).j",
+			"(a - (b - c)) * -(d + e) - f * g + h[0](i).j;\n",
+		},
+		{
+			"comparison parentheses",
+			"(1 < 2) == true; a - (b == c)",
+			"1 < 2 == true;\na - (b == c);\n",
+		},
+		{
+			"call on grouped expression",
+			"(a + b)(c); (fn(x) { x })(1)",
+			"(a + b)(c);\nfn(x) {\n\tx;\n}(1);\n",
+		},
+		{
+			"hash and array literals",
+			`let h = {"b":[1,2],"a":{}}; let a = [ ];`,
+			"let h = {\"b\": [1, 2], \"a\": {}};\nlet a = [];\n",
+		},
+		{
+			"multi-line literals",
+			"let h = {\"a\": 1,\n\"b\": 2 // two\n}; let a = [1,\n2];",
+			"let h = {\n\t\"a\": 1,\n\t\"b\": 2, // two\n};\nlet a = [\n\t1,\n\t2\n];\n",
+		},
+		{
+			"import and export",
+			`import   "lib" ; import u from "./util"
+export let x=u.y;
+export struct P {x}`,
+			"import \"lib\";\nimport u from \"./util\";\nexport let x = u.y;\nexport struct P { x }\n",
+		},
+		{
+			"comments",
+			"// head\nlet x = 1; // one\n\n\n// before y\nlet y = fn() { // open\n  // inside\n  x\n  // end\n};\n// tail\n",
+			"// head\nlet x = 1; // one\n\n// before y\nlet y = fn() { // open\n\t// inside\n\tx;\n\t// end\n};\n// tail\n",
+		},
+		{
+			"trailing comment after two statements",
+			"let a = 1; let b = 2; // both",
+			"let a = 1;\nlet b = 2; // both\n",
+		},
+		{
+			"trailing comment after a one line if else",
+			"if (a > b) { a } else { b } // after if\nlet c = fn() { 1 }; // after fn\n",
+			"if (a > b) {\n\ta;\n} else {\n\tb;\n} // after if\nlet c = fn() {\n\t1;\n}; // after fn\n",
+		},
+		{
+			"comments between call arguments",
+			"f(\n 1, // arg\n 2\n);\ng(1,\n // before two\n 2 // two\n) // call\nh(1, 2); // one line\n",
+			"f(\n\t1, // arg\n\t2\n);\ng(\n\t1,\n\t// before two\n\t2 // two\n); // call\nh(1, 2); // one line\n",
+		},
+		{
+			"blank lines",
+			"let a = fn() {\n\n  1;\n\n\n  2;\n};\n\nlet b = 2;\nlet c = 3;\n",
+			"let a = fn() {\n\t1;\n\n\t2;\n};\n\nlet b = 2;\nlet c = 3;\n",
+		},
+		{
+			"macro literal",
+			"let unless=macro(c,a){quote(if(!(unquote(c))){unquote(a)})};",
+			"let unless = macro(c, a) {\n\tquote(if (!unquote(c)) {\n\t\tunquote(a);\n\t});\n};\n",
+		},
+		{
+			"only comments",
+			"// a\n\n// b\n",
+			"// a\n\n// b\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatted, err := Source([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Source returned error: %v", err)
+			}
+			if string(formatted) != tt.expected {
+				t.Errorf("wrong output.\nexpected=%q\ngot=     %q", tt.expected, formatted)
+			}
+
+			again, err := Source(formatted)
+			if err != nil {
+				t.Fatalf("formatted output does not parse: %v", err)
+			}
+			if string(again) != string(formatted) {
+				t.Errorf("formatting is not idempotent.\nfirst= %q\nsecond=%q", formatted, again)
+			}
+		})
+	}
+}
+
+func TestSourceParseError(t *testing.T) {
+	_, err := Source([]byte("let = 5;"))
+	if err == nil || !strings.Contains(err.Error(), "expected next token to be IDENT") {
+		t.Errorf("expected parse error. got=%v", err)
+	}
+}
+
+func TestProgram(t *testing.T) {
+	p := parser.New(lexer.New("let x = 1;\n\nlet y = x;"))
+	program := p.ParseProgram()
+
+	expected := "let x = 1;\nlet y = x;\n"
+	if Program(program) != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, Program(program))
+	}
+}
+
+func TestNode(t *testing.T) {
+	p := parser.New(lexer.New("if ((x+1)>2) { y }"))
+	program := p.ParseProgram()
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	cond := stmt.Expression.(*ast.IfExpression).Condition
+
+	if Node(cond) != "x + 1 > 2" {
+		t.Errorf("wrong output. got=%q", Node(cond))
+	}
+	if Node(stmt) != "if (x + 1 > 2) {\n\ty;\n}" {
+		t.Errorf("wrong output. got=%q", Node(stmt))
+	}
+}
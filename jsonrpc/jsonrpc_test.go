@@ -0,0 +1,99 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		testName string
+		input    string
+		expected []string
+		err      string
+	}{
+		{"single", "Content-Length: 2\r\n\r\n{}", []string{"{}"}, ""},
+		{"two frames", "Content-Length: 1\r\n\r\n1Content-Length: 1\r\n\r\n2", []string{"1", "2"}, ""},
+		{"extra headers", "Content-Type: application/vscode-jsonrpc\r\ncontent-length: 3\r\n\r\nabc", []string{"abc"}, ""},
+		{"missing length", "Content-Type: x\r\n\r\n{}", nil, "missing Content-Length header"},
+		{"bad length", "Content-Length: x\r\n\r\n", nil, `invalid Content-Length " x"`},
+		{"malformed header", "oops\r\n\r\n", nil, `malformed header "oops"`},
+		{"short body", "Content-Length: 10\r\n\r\n{}", nil, "unexpected EOF"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tt.input))
+
+			for _, expected := range tt.expected {
+				body, err := ReadFrame(r)
+				if err != nil {
+					t.Fatalf("ReadFrame returned error: %v", err)
+				}
+				if string(body) != expected {
+					t.Errorf("wrong body. expected=%q, got=%q", expected, body)
+				}
+			}
+
+			_, err := ReadFrame(r)
+			if tt.err == "" {
+				if err != io.EOF {
+					t.Errorf("expected io.EOF. got=%v", err)
+				}
+			} else if err == nil || err.Error() != tt.err {
+				t.Errorf("wrong error. expected=%q, got=%v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestConn(t *testing.T) {
+	var buf bytes.Buffer
+	conn := NewConn(&buf, &buf)
+
+	id, err := conn.Request("hover", map[string]int{"line": 1})
+	if err != nil {
+		t.Fatalf("Request returned error: %v", err)
+	}
+	conn.Notify("initialized", struct{}{})
+	conn.Reply(id, nil, nil)
+	conn.Reply(id, nil, &Error{Code: MethodNotFound, Message: "nope"})
+
+	expected := []string{
+		`{"jsonrpc":"2.0","id":1,"method":"hover","params":{"line":1}}`,
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		`{"jsonrpc":"2.0","id":1,"result":null}`,
+		`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"nope"}}`,
+	}
+
+	r := bufio.NewReader(bytes.NewReader(buf.Bytes()))
+	for _, want := range expected {
+		body, err := ReadFrame(r)
+		if err != nil {
+			t.Fatalf("ReadFrame returned error: %v", err)
+		}
+		if string(body) != want {
+			t.Errorf("wrong message.\nwant=%s\ngot= %s", want, body)
+		}
+	}
+
+	conn = NewConn(strings.NewReader("Content-Length: 5\r\n\r\n{bad}"), io.Discard)
+	if _, err := conn.Read(); err == nil || err.(*Error).Code != ParseError {
+		t.Errorf("expected parse error. got=%v", err)
+	}
+
+	buf.Reset()
+	WriteFrame(&buf, []byte(`{"jsonrpc":"2.0","id":7,"result":true}`))
+	conn = NewConn(&buf, io.Discard)
+	msg, err := conn.Read()
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	if !msg.IsResponse() || string(msg.ID) != "7" || !bytes.Equal(msg.Result, json.RawMessage("true")) {
+		t.Errorf("wrong message. got=%+v", msg)
+	}
+}
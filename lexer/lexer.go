@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"goscript/token"
+	"strings"
 )
 
 type Lexer struct {
@@ -9,15 +10,28 @@ type Lexer struct {
 	position    int
 	readPostion int
 	ch          byte
+	line        int
+	column      int
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewAt(input, 1, 1)
+}
+
+// NewAt returns a lexer whose input starts at the given line and column of
+// a larger source, such as an expression interpolated into a string.
+func NewAt(input string, line, column int) *Lexer {
+	l := &Lexer{input: input, line: line, column: column - 1}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+
 	if l.readPostion >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -25,13 +39,23 @@ func (l *Lexer) readChar() {
 	}
 	l.position = l.readPostion
 	l.readPostion += 1
+	l.column += 1
 }
 
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
-
 	l.skipWhitespace()
 
+	line, column := l.line, l.column
+	tok := l.nextToken()
+	tok.Line = line
+	tok.Column = column
+
+	return tok
+}
+
+func (l *Lexer) nextToken() token.Token {
+	var tok token.Token
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -39,6 +63,9 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			literal := string(ch) + string(l.ch)
 			tok = token.Token{Type: token.EQ, Literal: literal}
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: "=>"}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
@@ -54,6 +81,11 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '/':
+		if l.peekChar() == '/' {
+			tok.Type = token.COMMENT
+			tok.Literal = l.readComment()
+			return tok
+		}
 		tok = newToken(token.SLASH, l.ch)
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
@@ -87,6 +119,14 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RBRACKET, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		if strings.HasPrefix(l.input[l.readPostion:], "..") {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	default:
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
@@ -113,6 +153,14 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
+func (l *Lexer) readComment() string {
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+	return strings.TrimRight(l.input[position:l.position], " \t\r")
+}
+
 func (l *Lexer) readNumber() string {
 	position := l.position
 	for isDigit(l.ch) {
@@ -140,7 +188,7 @@ func (l *Lexer) skipWhitespace() {
 }
 
 func (l *Lexer) peekChar() byte {
-	if l.readPostion > len(l.input) {
+	if l.readPostion >= len(l.input) {
 		return 0
 	} else {
 		return l.input[l.readPostion]
@@ -149,12 +197,50 @@ func (l *Lexer) peekChar() byte {
 
 func (l *Lexer) readString() string {
 	position := l.position + 1
-	for {
+	end := stringEnd(l.input, position)
+	for l.position < end {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
-		}
 	}
 
 	return l.input[position:l.position]
 }
+
+// stringEnd returns the index of the quote that closes the string whose
+// contents start at s[i], skipping the expressions interpolated into it, or
+// len(s) if the string is not closed. $${ stands for a literal ${.
+func stringEnd(s string, i int) int {
+	for i < len(s) {
+		switch {
+		case s[i] == '"':
+			return i
+		case strings.HasPrefix(s[i:], "$${"):
+			i += 3
+		case strings.HasPrefix(s[i:], "${"):
+			i = InterpolationEnd(s, i+2) + 1
+		default:
+			i++
+		}
+	}
+	return len(s)
+}
+
+// InterpolationEnd returns the index of the brace that closes the
+// expression interpolated into a string at s[i], or len(s) if there is none.
+// Braces and strings inside the expression are skipped.
+func InterpolationEnd(s string, i int) int {
+	depth := 0
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case '"':
+			i = stringEnd(s, i+1)
+		}
+	}
+	return len(s)
+}
@@ -25,6 +25,11 @@ func TestNextToken(t *testing.T) {
 	"foo bar"
 	[1, 2];
 	{"foo": "bar"}
+	import lib from "lib";
+	export let x = lib.y;
+	f(...xs);
+	match (x) { _ => 1 }
+	struct P { x } p with {x: 1}
 	`
 
 	tests := []struct {
@@ -118,6 +123,46 @@ func TestNextToken(t *testing.T) {
 		{":", token.COLON, ":"},
 		{"bar", token.STRING, "bar"},
 		{"}", token.RBRACE, "}"},
+		{"import", token.IMPORT, "import"},
+		{"lib", token.IDENT, "lib"},
+		{"from", token.IDENT, "from"},
+		{"lib string", token.STRING, "lib"},
+		{";", token.SEMICOLON, ";"},
+		{"export", token.EXPORT, "export"},
+		{"let", token.LET, "let"},
+		{"x", token.IDENT, "x"},
+		{"=", token.ASSIGN, "="},
+		{"lib ident", token.IDENT, "lib"},
+		{".", token.DOT, "."},
+		{"y", token.IDENT, "y"},
+		{";", token.SEMICOLON, ";"},
+		{"f", token.IDENT, "f"},
+		{"(", token.LPAREN, "("},
+		{"...", token.ELLIPSIS, "..."},
+		{"xs", token.IDENT, "xs"},
+		{")", token.RPAREN, ")"},
+		{";", token.SEMICOLON, ";"},
+		{"match", token.MATCH, "match"},
+		{"(", token.LPAREN, "("},
+		{"x", token.IDENT, "x"},
+		{")", token.RPAREN, ")"},
+		{"{", token.LBRACE, "{"},
+		{"_", token.IDENT, "_"},
+		{"=>", token.ARROW, "=>"},
+		{"1", token.INT, "1"},
+		{"}", token.RBRACE, "}"},
+		{"struct", token.STRUCT, "struct"},
+		{"P", token.IDENT, "P"},
+		{"{", token.LBRACE, "{"},
+		{"x", token.IDENT, "x"},
+		{"}", token.RBRACE, "}"},
+		{"p", token.IDENT, "p"},
+		{"with", token.WITH, "with"},
+		{"{", token.LBRACE, "{"},
+		{"x", token.IDENT, "x"},
+		{":", token.COLON, ":"},
+		{"1", token.INT, "1"},
+		{"}", token.RBRACE, "}"},
 		{"EOF", token.EOF, ""},
 	}
 
@@ -137,3 +182,75 @@ func TestNextToken(t *testing.T) {
 		})
 	}
 }
+
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a ${b} c"`, "a ${b} c"},
+		{`"a ${ {"k": "}"}["k"] } c"`, `a ${ {"k": "}"}["k"] } c`},
+		{`"a ${ "b ${c}" } d"`, `a ${ "b ${c}" } d`},
+		{`"$${" + "}"`, "$${"},
+		{`"a ${b`, "a ${b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			tok := New(tt.input).NextToken()
+			if tok.Type != token.STRING || tok.Literal != tt.expected {
+				t.Errorf("wrong token. expected=STRING(%q), got=%q(%q)", tt.expected, tok.Type, tok.Literal)
+			}
+		})
+	}
+}
+
+func TestNewAt(t *testing.T) {
+	l := NewAt("a +\n b", 3, 7)
+
+	for _, want := range []token.Token{
+		{Type: token.IDENT, Literal: "a", Line: 3, Column: 7},
+		{Type: token.PLUS, Literal: "+", Line: 3, Column: 9},
+		{Type: token.IDENT, Literal: "b", Line: 4, Column: 2},
+	} {
+		if got := l.NextToken(); got != want {
+			t.Errorf("wrong token. expected=%+v, got=%+v", want, got)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5; // five\n  x / 2\n//"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1, 1},
+		{token.IDENT, "x", 1, 5},
+		{token.ASSIGN, "=", 1, 7},
+		{token.INT, "5", 1, 9},
+		{token.SEMICOLON, ";", 1, 10},
+		{token.COMMENT, "// five", 1, 12},
+		{token.IDENT, "x", 2, 3},
+		{token.SLASH, "/", 2, 5},
+		{token.INT, "2", 2, 7},
+		{token.COMMENT, "//", 3, 1},
+		{token.EOF, "", 3, 3},
+	}
+
+	l := New(input)
+
+	for _, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("wrong token. expected=%q(%q), got=%q(%q)", tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			t.Errorf("%q: wrong position. expected=%d:%d, got=%d:%d", tt.expectedLiteral, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"goscript/lint"
+	"os"
+)
+
+func lintMain(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	config := flags.String("config", "", "path to the lint configuration file (default "+lint.ConfigFile+" if present)")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: goscript lint [-config file] files...")
+		return 2
+	}
+
+	path := *config
+	if path == "" {
+		if _, err := os.Stat(lint.ConfigFile); err == nil {
+			path = lint.ConfigFile
+		}
+	}
+
+	var cfg *lint.Config
+	if path != "" {
+		var err error
+		if cfg, err = lint.LoadConfig(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	status := 0
+	for _, file := range flags.Args() {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 2
+			continue
+		}
+
+		for _, d := range lint.Source(src, cfg) {
+			fmt.Printf("%s:%s\n", file, d)
+			if status == 0 {
+				status = 1
+			}
+		}
+	}
+
+	return status
+}
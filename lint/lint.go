@@ -0,0 +1,559 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"goscript/ast"
+	"goscript/evaluator"
+	"goscript/lexer"
+	"goscript/parser"
+	"goscript/token"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	RuleSyntax      = "syntax"
+	RuleUnused      = "unused"
+	RuleShadow      = "shadow"
+	RuleUnreachable = "unreachable"
+	RuleUndefined   = "undefined"
+	RuleArity       = "arity"
+)
+
+var Rules = []string{RuleUnused, RuleShadow, RuleUnreachable, RuleUndefined, RuleArity}
+
+const ConfigFile = ".goscriptlint.json"
+
+type Diagnostic struct {
+	Rule    string
+	Message string
+	Line    int
+	Column  int
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s (%s)", d.Line, d.Column, d.Message, d.Rule)
+}
+
+type Config struct {
+	Disable []string `json:"disable"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for _, rule := range cfg.Disable {
+		if !knownRule(rule) {
+			return nil, fmt.Errorf("%s: unknown rule %q", path, rule)
+		}
+	}
+
+	return cfg, nil
+}
+
+func knownRule(rule string) bool {
+	for _, r := range Rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) enabled(rule string) bool {
+	if c == nil {
+		return true
+	}
+
+	for _, r := range c.Disable {
+		if r == rule {
+			return false
+		}
+	}
+	return true
+}
+
+func Source(src []byte, cfg *Config) []Diagnostic {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+
+	if errs := p.ErrorList(); len(errs) != 0 {
+		diags := make([]Diagnostic, len(errs))
+		for i, err := range errs {
+			diags[i] = Diagnostic{Rule: RuleSyntax, Message: err.Message, Line: err.Line, Column: err.Column}
+		}
+		return diags
+	}
+
+	return Program(program, cfg)
+}
+
+func Program(program *ast.Program, cfg *Config) []Diagnostic {
+	l := &linter{}
+	l.program(program)
+
+	ignores := suppressions(program.Comments)
+
+	diags := []Diagnostic{}
+	for _, d := range l.diags {
+		if cfg.enabled(d.Rule) && !ignores.match(d) {
+			diags = append(diags, d)
+		}
+	}
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].Line != diags[j].Line {
+			return diags[i].Line < diags[j].Line
+		}
+		return diags[i].Column < diags[j].Column
+	})
+
+	return diags
+}
+
+func Resolve(program *ast.Program) map[*ast.Identifier]*ast.Identifier {
+	l := &linter{refs: make(map[*ast.Identifier]*ast.Identifier)}
+	l.program(program)
+
+	return l.refs
+}
+
+type binding struct {
+	ident    *ast.Identifier
+	defined  bool
+	used     bool
+	exported bool
+	param    bool
+}
+
+type scope struct {
+	outer    *scope
+	bindings map[string]*binding
+	order    []*binding
+}
+
+type linter struct {
+	scope *scope
+	diags []Diagnostic
+	refs  map[*ast.Identifier]*ast.Identifier
+}
+
+func (l *linter) program(program *ast.Program) {
+	l.openScope()
+	l.declareBlock(program.Statements)
+	l.statements(program.Statements)
+	l.closeScope()
+}
+
+func (l *linter) reference(use *ast.Identifier, decl *ast.Identifier) {
+	if l.refs != nil {
+		l.refs[use] = decl
+	}
+}
+
+func (l *linter) report(tok token.Token, rule, format string, a ...interface{}) {
+	l.diags = append(l.diags, Diagnostic{
+		Rule:    rule,
+		Message: fmt.Sprintf(format, a...),
+		Line:    tok.Line,
+		Column:  tok.Column,
+	})
+}
+
+func (l *linter) openScope() {
+	l.scope = &scope{outer: l.scope, bindings: make(map[string]*binding)}
+}
+
+func (l *linter) closeScope() {
+	for _, b := range l.scope.order {
+		if b.used || b.exported || b.param || strings.HasPrefix(b.ident.Value, "_") {
+			continue
+		}
+		l.report(b.ident.Token, RuleUnused, "%s is declared but never used", b.ident.Value)
+	}
+
+	l.scope = l.scope.outer
+}
+
+func (l *linter) declareBlock(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			for _, name := range stmt.Names() {
+				l.predeclare(name)
+			}
+		case *ast.ExportStatement:
+			l.predeclare(stmt.Name())
+			l.scope.bindings[stmt.Name().Value].exported = true
+		case *ast.ImportStatement:
+			if name := ImportName(stmt); name != nil {
+				l.predeclare(name)
+			}
+		case *ast.ForStatement:
+			l.predeclare(stmt.Variable)
+			l.declareBlock(stmt.Body.Statements)
+		case *ast.StructStatement:
+			l.predeclare(stmt.Name)
+		case *ast.ExpressionStatement:
+			if ifExp, ok := stmt.Expression.(*ast.IfExpression); ok {
+				l.declareBlock(ifExp.Consequence.Statements)
+				if ifExp.Alternative != nil {
+					l.declareBlock(ifExp.Alternative.Statements)
+				}
+			}
+		}
+	}
+}
+
+func ImportName(stmt *ast.ImportStatement) *ast.Identifier {
+	if stmt.Name != nil {
+		return stmt.Name
+	}
+	return &ast.Identifier{Token: stmt.Path.Token, Value: stmt.BoundName()}
+}
+
+func (l *linter) predeclare(ident *ast.Identifier) {
+	if _, ok := l.scope.bindings[ident.Value]; ok {
+		return
+	}
+
+	b := &binding{ident: ident}
+	l.scope.bindings[ident.Value] = b
+	l.scope.order = append(l.scope.order, b)
+}
+
+func (l *linter) define(ident *ast.Identifier) {
+	l.predeclare(ident)
+
+	b := l.scope.bindings[ident.Value]
+	l.reference(ident, b.ident)
+	if b.defined {
+		return
+	}
+	b.defined = true
+
+	l.checkShadow(ident)
+}
+
+func (l *linter) checkShadow(ident *ast.Identifier) {
+	for s := l.scope.outer; s != nil; s = s.outer {
+		if outer, ok := s.bindings[ident.Value]; ok {
+			l.report(ident.Token, RuleShadow, "%s shadows declaration at line %d", ident.Value, outer.ident.Token.Line)
+			return
+		}
+	}
+
+	if _, ok := evaluator.LookupBuiltin(ident.Value); ok {
+		l.report(ident.Token, RuleShadow, "%s shadows a builtin function", ident.Value)
+	}
+}
+
+func (l *linter) resolve(ident *ast.Identifier) {
+	if b, ok := l.scope.bindings[ident.Value]; ok {
+		if !b.defined {
+			l.report(ident.Token, RuleUndefined, "%s is used before it is defined", ident.Value)
+		}
+		b.used = true
+		l.reference(ident, b.ident)
+		return
+	}
+
+	for s := l.scope.outer; s != nil; s = s.outer {
+		if b, ok := s.bindings[ident.Value]; ok {
+			b.used = true
+			l.reference(ident, b.ident)
+			return
+		}
+	}
+
+	if _, ok := evaluator.LookupBuiltin(ident.Value); ok {
+		return
+	}
+
+	l.report(ident.Token, RuleUndefined, "undefined: %s", ident.Value)
+}
+
+func (l *linter) isBuiltin(name string) bool {
+	for s := l.scope; s != nil; s = s.outer {
+		if _, ok := s.bindings[name]; ok {
+			return false
+		}
+	}
+
+	_, ok := evaluator.LookupBuiltin(name)
+	return ok
+}
+
+func (l *linter) statements(stmts []ast.Statement) {
+	returned := false
+
+	for _, stmt := range stmts {
+		if returned {
+			l.report(ast.Pos(stmt), RuleUnreachable, "unreachable code")
+			returned = false
+		}
+
+		l.statement(stmt)
+
+		if _, ok := stmt.(*ast.ReturnStatement); ok {
+			returned = true
+		}
+	}
+}
+
+func (l *linter) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		l.expression(stmt.Value)
+		for _, name := range stmt.Names() {
+			l.define(name)
+		}
+	case *ast.ExportStatement:
+		l.statement(stmt.Statement)
+	case *ast.ImportStatement:
+		if name := ImportName(stmt); name != nil {
+			l.define(name)
+		}
+	case *ast.ReturnStatement:
+		l.expression(stmt.ReturnValue)
+	case *ast.YieldStatement:
+		l.expression(stmt.Value)
+	case *ast.ForStatement:
+		l.expression(stmt.Iterable)
+		l.define(stmt.Variable)
+		l.statements(stmt.Body.Statements)
+	case *ast.StructStatement:
+		l.define(stmt.Name)
+		for _, method := range stmt.Methods {
+			l.method(method)
+		}
+	case *ast.ExpressionStatement:
+		l.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		l.statements(stmt.Statements)
+	}
+}
+
+// method lints a method of a struct in a scope that binds self.
+func (l *linter) method(method *ast.FunctionLiteral) {
+	l.openScope()
+
+	self := &ast.Identifier{Token: method.Token, Value: "self"}
+	l.predeclare(self)
+	l.scope.bindings[self.Value].param = true
+	l.define(self)
+
+	l.function(method.Parameters, method.Patterns, method.Defaults, method.Body)
+	l.closeScope()
+}
+
+func (l *linter) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		l.resolve(exp)
+	case *ast.PrefixExpression:
+		l.expression(exp.Right)
+	case *ast.SpawnExpression:
+		l.expression(exp.Call)
+	case *ast.SpreadExpression:
+		l.expression(exp.Value)
+	case *ast.InfixExpression:
+		l.expression(exp.Left)
+		l.expression(exp.Right)
+	case *ast.IfExpression:
+		l.expression(exp.Condition)
+		l.statement(exp.Consequence)
+		if exp.Alternative != nil {
+			l.statement(exp.Alternative)
+		}
+	case *ast.MatchExpression:
+		l.expression(exp.Value)
+		for _, arm := range exp.Arms {
+			l.openScope()
+			for _, name := range ast.PatternNames(arm.Pattern) {
+				l.define(name)
+			}
+			if arm.Guard != nil {
+				l.expression(arm.Guard)
+			}
+			l.expression(arm.Body)
+			l.closeScope()
+		}
+	case *ast.FunctionLiteral:
+		l.function(exp.Parameters, exp.Patterns, exp.Defaults, exp.Body)
+	case *ast.MacroLiteral:
+		l.function(exp.Parameters, nil, nil, exp.Body)
+	case *ast.CallExpression:
+		if l.isQuote(exp) {
+			l.quoted(exp)
+			return
+		}
+
+		l.expression(exp.Function)
+		for _, arg := range exp.Arguments {
+			l.expression(arg)
+		}
+		l.checkArity(exp)
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			l.expression(el)
+		}
+	case *ast.InterpolatedString:
+		for _, part := range exp.Parts {
+			l.expression(part)
+		}
+	case *ast.HashLiteral:
+		for _, key := range exp.Keys {
+			l.expression(key)
+			l.expression(exp.Pairs[key])
+		}
+	case *ast.IndexExpression:
+		l.expression(exp.Left)
+		l.expression(exp.Index)
+	case *ast.MemberExpression:
+		l.expression(exp.Object)
+	case *ast.WithExpression:
+		l.expression(exp.Left)
+		for _, value := range exp.Values {
+			l.expression(value)
+		}
+	}
+}
+
+// function lints a function or macro literal. The names in the pattern of a
+// destructured parameter are bound instead of the parameter itself.
+func (l *linter) function(params []*ast.Identifier, patterns, defaults []ast.Expression, body *ast.BlockStatement) {
+	l.openScope()
+
+	seen := make(map[string]bool)
+	for i, param := range params {
+		if i < len(defaults) && defaults[i] != nil {
+			l.expression(defaults[i])
+		}
+
+		names := []*ast.Identifier{param}
+		if i < len(patterns) && patterns[i] != nil {
+			names = ast.PatternNames(patterns[i])
+		}
+
+		for _, name := range names {
+			if seen[name.Value] {
+				continue
+			}
+			seen[name.Value] = true
+
+			l.predeclare(name)
+			l.scope.bindings[name.Value].param = true
+			l.define(name)
+		}
+	}
+
+	l.declareBlock(body.Statements)
+	l.statements(body.Statements)
+	l.closeScope()
+}
+
+func (l *linter) isQuote(call *ast.CallExpression) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok || ident.Value != "quote" {
+		return false
+	}
+
+	for s := l.scope; s != nil; s = s.outer {
+		if _, ok := s.bindings[ident.Value]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *linter) quoted(call *ast.CallExpression) {
+	for _, arg := range call.Arguments {
+		ast.Inspect(arg, func(node ast.Node) bool {
+			unquote, ok := node.(*ast.CallExpression)
+			if !ok {
+				return true
+			}
+			if ident, ok := unquote.Function.(*ast.Identifier); !ok || ident.Value != "unquote" {
+				return true
+			}
+
+			for _, arg := range unquote.Arguments {
+				l.expression(arg)
+			}
+			return false
+		})
+	}
+}
+
+func (l *linter) checkArity(call *ast.CallExpression) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok || !l.isBuiltin(ident.Value) {
+		return
+	}
+	for _, arg := range call.Arguments {
+		if _, ok := arg.(*ast.SpreadExpression); ok {
+			return
+		}
+	}
+
+	builtin, _ := evaluator.LookupBuiltin(ident.Value)
+	min, max := builtin.Arity()
+	got := len(call.Arguments)
+
+	switch {
+	case got < min && max == -1:
+		l.report(ident.Token, RuleArity, "%s expects at least %d arguments, got %d", ident.Value, min, got)
+	case (got < min || got > max) && max != -1 && min == max:
+		l.report(ident.Token, RuleArity, "%s expects %d arguments, got %d", ident.Value, min, got)
+	case (got < min || got > max) && max != -1:
+		l.report(ident.Token, RuleArity, "%s expects %d to %d arguments, got %d", ident.Value, min, max, got)
+	}
+}
+
+type suppression map[int][]string
+
+func suppressions(comments []*ast.Comment) suppression {
+	s := make(suppression)
+
+	for _, c := range comments {
+		text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if !strings.HasPrefix(text, "lint:ignore") {
+			continue
+		}
+
+		rules := []string{}
+		for _, rule := range strings.Split(strings.TrimSpace(strings.TrimPrefix(text, "lint:ignore")), ",") {
+			if rule = strings.TrimSpace(rule); rule != "" {
+				rules = append(rules, rule)
+			}
+		}
+		if len(rules) == 0 {
+			rules = append(rules, "*")
+		}
+
+		s[c.Token.Line] = append(s[c.Token.Line], rules...)
+		s[c.Token.Line+1] = append(s[c.Token.Line+1], rules...)
+	}
+
+	return s
+}
+
+func (s suppression) match(d Diagnostic) bool {
+	for _, rule := range s[d.Line] {
+		if rule == "*" || rule == d.Rule {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			"clean",
+			`let add = fn(a, b) { a + b }; puts(add(1, 2));`,
+			[]string{},
+		},
+		{
+			"unused binding",
+			`let x = 1; let f = fn() { let y = 2; let _z = 3; 4 }; f();`,
+			[]string{"1:5: x is declared but never used (unused)", "1:31: y is declared but never used (unused)"},
+		},
+		{
+			"unused parameters are allowed",
+			`let f = fn(a, b) { a }; f(1, 2);`,
+			[]string{},
+		},
+		{
+			"exports are used",
+			`export let x = 1; export struct P { x }`,
+			[]string{},
+		},
+		{
+			"unused import",
+			`import "lib/util"; import u from "other";`,
+			[]string{"1:8: util is declared but never used (unused)", "1:27: u is declared but never used (unused)"},
+		},
+		{
+			"shadowing",
+			`let x = 1; let f = fn(x) { let len = 2; x + len }; f(x);`,
+			[]string{"1:23: x shadows declaration at line 1 (shadow)", "1:32: len shadows a builtin function (shadow)"},
+		},
+		{
+			"unreachable code",
+			"let f = fn() {\n\treturn 1;\n\tputs(2);\n\tputs(3);\n};\nf();",
+			[]string{"3:2: unreachable code (unreachable)"},
+		},
+		{
+			"undefined identifier",
+			`puts(y); let f = fn() { z };`,
+			[]string{"1:6: undefined: y (undefined)", "1:14: f is declared but never used (unused)", "1:25: undefined: z (undefined)"},
+		},
+		{
+			"loop variables",
+			`let f = fn(xs) { for (x in xs) { yield x; } for (y in xs) {} }; f([]);`,
+			[]string{"1:50: y is declared but never used (unused)"},
+		},
+		{
+			"default values",
+			`let f = fn(a, b = a + c, ...d) { b }; f(...[1]);`,
+			[]string{"1:23: undefined: c (undefined)"},
+		},
+		{
+			"destructuring",
+			`let [a, b] = [1, 2]; let f = fn([x, y], {z}) { z }; f(a, {});`,
+			[]string{"1:9: b is declared but never used (unused)"},
+		},
+		{
+			"match",
+			`let x = 1; match (x) { [a, b] if a > 0 => a, {c} => c + d, _ => 0 };`,
+			[]string{"1:28: b is declared but never used (unused)", "1:57: undefined: d (undefined)"},
+		},
+		{
+			"interpolated strings",
+			`let a = 1; puts("${a} and ${b}");`,
+			[]string{"1:29: undefined: b (undefined)"},
+		},
+		{
+			"structs",
+			`struct P { x, fn get(unused) { self.x + y } } P(1) with {x: z};`,
+			[]string{"1:41: undefined: y (undefined)", "1:61: undefined: z (undefined)"},
+		},
+		{
+			"use before definition",
+			`puts(x); let x = 1;`,
+			[]string{"1:6: x is used before it is defined (undefined)"},
+		},
+		{
+			"later definitions are visible in function bodies",
+			`let f = fn() { g() }; let g = fn() { f() }; f();`,
+			[]string{},
+		},
+		{
+			"let in if block binds in the enclosing scope",
+			`if (true) { let x = 1; } puts(x);`,
+			[]string{},
+		},
+		{
+			"builtin arity",
+			`push([1]); len(); puts(); json_encode(1, 2, 3); rest([1], 2)`,
+			[]string{
+				"1:1: push expects 2 arguments, got 1 (arity)",
+				"1:12: len expects 1 arguments, got 0 (arity)",
+				"1:27: json_encode expects 1 to 2 arguments, got 3 (arity)",
+				"1:49: rest expects 1 arguments, got 2 (arity)",
+			},
+		},
+		{
+			"arity ignores shadowed builtins",
+			`let len = fn(a, b) { a + b }; len(1, 2);`,
+			[]string{"1:5: len shadows a builtin function (shadow)"},
+		},
+		{
+			"macros and quote",
+			"let unless = macro(cond, body) { quote(if (!(unquote(cond))) { unquote(body); later }) };\nunless(true, puts(1));\nlet q = quote(unquote(missing));",
+			[]string{"3:5: q is declared but never used (unused)", "3:23: undefined: missing (undefined)"},
+		},
+		{
+			"suppression comments",
+			"let x = 1; // lint:ignore unused\n// lint:ignore\nlet y = z;\nlet w = 2; // lint:ignore shadow",
+			[]string{"4:5: w is declared but never used (unused)"},
+		},
+		{
+			"syntax errors",
+			`let = 1;`,
+			[]string{"1:5: expected next token to be IDENT, got = instead (syntax)", "1:5: no prefix parse function for = found (syntax)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diags := Source([]byte(tt.input), nil)
+
+			got := make([]string, len(diags))
+			for i, d := range diags {
+				got[i] = d.String()
+			}
+
+			if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("wrong diagnostics.\nexpected=%q\ngot=     %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ConfigFile)
+	if err := os.WriteFile(path, []byte(`{"disable": ["unused", "undefined"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig returned error: %v", err)
+	}
+
+	diags := Source([]byte(`let x = y; push(1);`), cfg)
+	if len(diags) != 1 || diags[0].Rule != RuleArity {
+		t.Errorf("wrong diagnostics. got=%v", diags)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"disable": ["nope"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), `unknown rule "nope"`) {
+		t.Errorf("expected unknown rule error. got=%v", err)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"goscript/jsonrpc"
+	"goscript/lsp"
+	"os"
+)
+
+func lspMain(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "usage: goscript lsp")
+		return 2
+	}
+
+	server := lsp.NewServer(jsonrpc.NewConn(os.Stdin, os.Stdout))
+	if err := server.Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, "goscript lsp:", err)
+		return 1
+	}
+
+	return 0
+}
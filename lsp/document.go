@@ -0,0 +1,259 @@
+package lsp
+
+import (
+	"goscript/ast"
+	"goscript/lexer"
+	"goscript/lint"
+	"goscript/parser"
+	"goscript/token"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type document struct {
+	uri   string
+	text  string
+	lines []string
+
+	program *ast.Program
+	errors  []parser.Error
+	refs    map[*ast.Identifier]*ast.Identifier
+	decls   map[*ast.Identifier]ast.Node
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, text: text, lines: strings.Split(text, "\n")}
+
+	p := parser.New(lexer.New(text))
+	program := p.ParseProgram()
+	if d.errors = p.ErrorList(); len(d.errors) != 0 {
+		return d
+	}
+
+	d.program = program
+	d.refs = lint.Resolve(program)
+	d.decls = make(map[*ast.Identifier]ast.Node)
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			for _, name := range node.Names() {
+				d.decls[name] = node
+			}
+		case *ast.FunctionLiteral:
+			for i, param := range node.Parameters {
+				d.decls[param] = node
+				for _, name := range ast.PatternNames(node.ParameterPattern(i)) {
+					d.decls[name] = node
+				}
+			}
+		case *ast.MacroLiteral:
+			for _, param := range node.Parameters {
+				d.decls[param] = node
+			}
+		case *ast.MatchArm:
+			for _, name := range ast.PatternNames(node.Pattern) {
+				d.decls[name] = node
+			}
+		case *ast.StructStatement:
+			d.decls[node.Name] = node
+		}
+		return true
+	})
+
+	return d
+}
+
+// position converts a 1-based line and byte column into an LSP position,
+// which counts UTF-16 code units.
+func (d *document) position(line, column int) Position {
+	if line < 1 || line > len(d.lines) {
+		return Position{Line: line - 1, Character: column - 1}
+	}
+
+	text := d.lines[line-1]
+	if column-1 < len(text) {
+		text = text[:column-1]
+	}
+
+	return Position{Line: line - 1, Character: len(utf16.Encode([]rune(text)))}
+}
+
+func (d *document) column(pos Position) (line, column int) {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return pos.Line + 1, pos.Character + 1
+	}
+
+	text := d.lines[pos.Line]
+	units := 0
+	for i, r := range text {
+		if units >= pos.Character {
+			return pos.Line + 1, i + 1
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+
+	return pos.Line + 1, len(text) + 1
+}
+
+func (d *document) tokenRange(tok token.Token) Range {
+	length := len(tok.Literal)
+	if tok.Type == token.STRING {
+		length += 2
+	}
+
+	return Range{
+		Start: d.position(tok.Line, tok.Column),
+		End:   d.position(tok.Line, tok.Column+length),
+	}
+}
+
+func (d *document) wordRange(line, column int) Range {
+	end := column
+	if line >= 1 && line <= len(d.lines) {
+		text := d.lines[line-1]
+		for end-1 < len(text) && isWordChar(text[end-1]) {
+			end++
+		}
+	}
+
+	if end == column {
+		end++
+	}
+
+	return Range{Start: d.position(line, column), End: d.position(line, end)}
+}
+
+func isWordChar(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' || ch == '_' || ch >= utf8.RuneSelf
+}
+
+func (d *document) end() Position {
+	last := len(d.lines)
+	return d.position(last, len(d.lines[last-1])+1)
+}
+
+func (d *document) identAt(pos Position) *ast.Identifier {
+	if d.program == nil {
+		return nil
+	}
+
+	line, column := d.column(pos)
+
+	var found *ast.Identifier
+	ast.Inspect(d.program, func(node ast.Node) bool {
+		ident, ok := node.(*ast.Identifier)
+		if ok && ident.Token.Line == line && ident.Token.Column <= column && column <= ident.Token.Column+len(ident.Value) {
+			found = ident
+		}
+		return found == nil
+	})
+
+	return found
+}
+
+func (d *document) references(decl *ast.Identifier) []*ast.Identifier {
+	idents := []*ast.Identifier{}
+	for use, target := range d.refs {
+		if target == decl {
+			idents = append(idents, use)
+		}
+	}
+
+	sort.Slice(idents, func(i, j int) bool {
+		a, b := idents[i].Token, idents[j].Token
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	return idents
+}
+
+type name struct {
+	ident *ast.Identifier
+	kind  int
+}
+
+func (d *document) visibleNames(pos Position) []name {
+	if d.program == nil {
+		return nil
+	}
+
+	line, column := d.column(pos)
+	inside := func(block *ast.BlockStatement) bool {
+		start, end := block.Token, block.Rbrace
+		return (line > start.Line || line == start.Line && column > start.Column) &&
+			(line < end.Line || line == end.Line && column <= end.Column)
+	}
+
+	names := []name{}
+	collect := func(stmts []ast.Statement) {
+		for _, stmt := range stmts {
+			ast.Inspect(stmt, func(node ast.Node) bool {
+				switch node := node.(type) {
+				case *ast.FunctionLiteral, *ast.MacroLiteral:
+					return false
+				case *ast.LetStatement:
+					for _, ident := range node.Names() {
+						names = append(names, name{ident, valueKind(node.Value)})
+					}
+				case *ast.StructStatement:
+					names = append(names, name{node.Name, CompletionItemKindStruct})
+					return false
+				case *ast.ImportStatement:
+					names = append(names, name{lint.ImportName(node), CompletionItemKindModule})
+				}
+				return true
+			})
+		}
+	}
+
+	collect(d.program.Statements)
+
+	ast.Inspect(d.program, func(node ast.Node) bool {
+		var params []*ast.Identifier
+		var body *ast.BlockStatement
+
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			body = node.Body
+			for i, param := range node.Parameters {
+				if pattern := node.ParameterPattern(i); pattern != nil {
+					params = append(params, ast.PatternNames(pattern)...)
+				} else {
+					params = append(params, param)
+				}
+			}
+		case *ast.MacroLiteral:
+			params, body = node.Parameters, node.Body
+		default:
+			return true
+		}
+
+		if !inside(body) {
+			return false
+		}
+
+		for _, param := range params {
+			names = append(names, name{param, CompletionItemKindVariable})
+		}
+		collect(body.Statements)
+
+		return true
+	})
+
+	return names
+}
+
+func valueKind(value ast.Expression) int {
+	switch value.(type) {
+	case *ast.FunctionLiteral, *ast.MacroLiteral:
+		return CompletionItemKindFunction
+	default:
+		return CompletionItemKindVariable
+	}
+}
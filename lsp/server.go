@@ -0,0 +1,541 @@
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goscript/ast"
+	"goscript/check"
+	"goscript/evaluator"
+	"goscript/format"
+	"goscript/jsonrpc"
+	"goscript/lint"
+	"goscript/resolver"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var ErrNoShutdown = errors.New("exit notification received before shutdown")
+
+var keywords = []string{"fn", "let", "true", "false", "if", "else", "return", "import", "export", "macro", "spawn", "yield", "for", "in", "match", "struct", "with"}
+
+type Server struct {
+	conn     *jsonrpc.Conn
+	docs     map[string]*document
+	config   *lint.Config
+	shutdown bool
+}
+
+func NewServer(conn *jsonrpc.Conn) *Server {
+	return &Server{conn: conn, docs: make(map[string]*document)}
+}
+
+func (s *Server) Serve() error {
+	for {
+		msg, err := s.conn.Read()
+		if err != nil {
+			var rpcErr *jsonrpc.Error
+			if errors.As(err, &rpcErr) {
+				s.conn.Reply(json.RawMessage("null"), nil, rpcErr)
+				continue
+			}
+			if err == io.EOF {
+				return ErrNoShutdown
+			}
+			return err
+		}
+
+		switch {
+		case msg.IsRequest():
+			result, rpcErr := s.handleRequest(msg.Method, msg.Params)
+			if err := s.conn.Reply(msg.ID, result, rpcErr); err != nil {
+				return err
+			}
+		case msg.IsNotification():
+			if msg.Method == "exit" {
+				if !s.shutdown {
+					return ErrNoShutdown
+				}
+				return nil
+			}
+			s.handleNotification(msg.Method, msg.Params)
+		}
+	}
+}
+
+func (s *Server) handleRequest(method string, params json.RawMessage) (interface{}, *jsonrpc.Error) {
+	switch method {
+	case "initialize":
+		var p InitializeParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		return s.initialize(p), nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/hover":
+		var p TextDocumentPositionParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		return s.hover(p), nil
+	case "textDocument/definition":
+		var p TextDocumentPositionParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		return s.definition(p), nil
+	case "textDocument/references":
+		var p ReferenceParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		return s.references(p), nil
+	case "textDocument/documentSymbol":
+		var p DocumentSymbolParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		return s.documentSymbols(p), nil
+	case "textDocument/formatting":
+		var p DocumentFormattingParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		return s.formatting(p)
+	case "textDocument/completion":
+		var p CompletionParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		return s.completion(p), nil
+	default:
+		return nil, &jsonrpc.Error{Code: jsonrpc.MethodNotFound, Message: "method not found: " + method}
+	}
+}
+
+func (s *Server) handleNotification(method string, params json.RawMessage) {
+	switch method {
+	case "textDocument/didOpen":
+		var p DidOpenTextDocumentParams
+		if decode(params, &p) == nil {
+			s.update(p.TextDocument.URI, p.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var p DidChangeTextDocumentParams
+		if decode(params, &p) == nil && len(p.ContentChanges) > 0 {
+			s.update(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+		}
+	case "textDocument/didClose":
+		var p DidCloseTextDocumentParams
+		if decode(params, &p) == nil {
+			delete(s.docs, p.TextDocument.URI)
+			s.conn.Notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		}
+	}
+}
+
+func decode(params json.RawMessage, v interface{}) *jsonrpc.Error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &jsonrpc.Error{Code: jsonrpc.InvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) initialize(p InitializeParams) InitializeResult {
+	if dir := uriToPath(p.RootURI); dir != "" {
+		path := filepath.Join(dir, lint.ConfigFile)
+		if _, err := os.Stat(path); err == nil {
+			s.config, _ = lint.LoadConfig(path)
+		}
+	}
+
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:           TextDocumentSyncFull,
+			HoverProvider:              true,
+			DefinitionProvider:         true,
+			ReferencesProvider:         true,
+			DocumentSymbolProvider:     true,
+			DocumentFormattingProvider: true,
+			CompletionProvider:         &CompletionOptions{},
+		},
+		ServerInfo: ServerInfo{Name: "goscript"},
+	}
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func (s *Server) update(uri, text string) {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+
+	diags := []Diagnostic{}
+	for _, err := range doc.errors {
+		diags = append(diags, Diagnostic{
+			Range:    doc.wordRange(err.Line, err.Column),
+			Severity: SeverityError,
+			Source:   "goscript",
+			Message:  err.Message,
+		})
+	}
+
+	if doc.program != nil {
+		// The resolver rejects programs with duplicate parameters, which
+		// would fail to run, so those are errors.
+		for _, err := range resolver.Resolve(doc.program) {
+			diags = append(diags, Diagnostic{
+				Range:    doc.wordRange(err.Line, err.Column),
+				Severity: SeverityError,
+				Source:   "resolve",
+				Message:  err.Message,
+			})
+		}
+
+		for _, d := range lint.Program(doc.program, s.config) {
+			diags = append(diags, Diagnostic{
+				Range:    doc.wordRange(d.Line, d.Column),
+				Severity: SeverityWarning,
+				Code:     d.Rule,
+				Source:   "lint",
+				Message:  d.Message,
+			})
+		}
+
+		for _, d := range check.Program(doc.program) {
+			diags = append(diags, Diagnostic{
+				Range:    doc.wordRange(d.Line, d.Column),
+				Severity: SeverityError,
+				Source:   "check",
+				Message:  d.Message,
+			})
+		}
+	}
+
+	s.conn.Notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: diags})
+}
+
+func (s *Server) hover(p TextDocumentPositionParams) *Hover {
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+
+	ident := doc.identAt(p.Position)
+	if ident == nil {
+		return nil
+	}
+
+	var text string
+	if decl, ok := doc.refs[ident]; ok {
+		text = describeDeclaration(decl, doc.decls[decl])
+	} else if builtin, ok := evaluator.LookupBuiltin(ident.Value); ok {
+		text = fmt.Sprintf("```goscript\n%s(%s)\n```\nbuiltin function, %s", ident.Value, strings.Join(builtin.Params, ", "), describeArity(builtin.Arity()))
+	} else {
+		return nil
+	}
+
+	r := doc.tokenRange(ident.Token)
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: &r}
+}
+
+func describeDeclaration(decl *ast.Identifier, node ast.Node) string {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		switch value := node.Value.(type) {
+		case *ast.FunctionLiteral:
+			return fmt.Sprintf("```goscript\nlet %s = fn(%s)\n```", decl.Value, joinParameters(value))
+		case *ast.MacroLiteral:
+			return fmt.Sprintf("```goscript\nlet %s = macro(%s)\n```", decl.Value, joinIdentifiers(value.Parameters))
+		default:
+			target := decl.Value
+			if node.Pattern != nil {
+				target = format.Node(node.Pattern)
+			}
+			source := format.Node(value)
+			if strings.Contains(source, "\n") || len(source) > 80 {
+				return fmt.Sprintf("```goscript\nlet %s\n```", target)
+			}
+			return fmt.Sprintf("```goscript\nlet %s = %s\n```", target, source)
+		}
+	case *ast.FunctionLiteral, *ast.MacroLiteral:
+		return fmt.Sprintf("```goscript\n%s\n```\nparameter", decl.Value)
+	case *ast.StructStatement:
+		return fmt.Sprintf("```goscript\nstruct %s { %s }\n```", decl.Value, joinIdentifiers(node.Fields))
+	case *ast.MatchArm:
+		return fmt.Sprintf("```goscript\n%s\n```\nbound by match pattern %s", decl.Value, format.Node(node.Pattern))
+	default:
+		return fmt.Sprintf("```goscript\nimport %s\n```", decl.Value)
+	}
+}
+
+func joinIdentifiers(idents []*ast.Identifier) string {
+	names := make([]string, len(idents))
+	for i, ident := range idents {
+		names[i] = ident.Value
+	}
+	return strings.Join(names, ", ")
+}
+
+func joinParameters(fl *ast.FunctionLiteral) string {
+	params := make([]string, len(fl.Parameters))
+	for i := range fl.Parameters {
+		params[i] = fl.ParameterString(i)
+	}
+	return strings.Join(params, ", ")
+}
+
+func describeArity(min, max int) string {
+	switch {
+	case max == -1:
+		return fmt.Sprintf("takes at least %d arguments", min)
+	case min == max && min == 1:
+		return "takes 1 argument"
+	case min == max:
+		return fmt.Sprintf("takes %d arguments", min)
+	default:
+		return fmt.Sprintf("takes %d to %d arguments", min, max)
+	}
+}
+
+func (s *Server) definition(p TextDocumentPositionParams) []Location {
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return []Location{}
+	}
+
+	ident := doc.identAt(p.Position)
+	if ident == nil {
+		return []Location{}
+	}
+
+	decl, ok := doc.refs[ident]
+	if !ok {
+		return []Location{}
+	}
+
+	return []Location{{URI: doc.uri, Range: doc.tokenRange(decl.Token)}}
+}
+
+func (s *Server) references(p ReferenceParams) []Location {
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return []Location{}
+	}
+
+	ident := doc.identAt(p.Position)
+	if ident == nil {
+		return []Location{}
+	}
+
+	decl, ok := doc.refs[ident]
+	if !ok {
+		return []Location{}
+	}
+
+	locations := []Location{}
+	for _, use := range doc.references(decl) {
+		if use == decl && !p.Context.IncludeDeclaration {
+			continue
+		}
+		locations = append(locations, Location{URI: doc.uri, Range: doc.tokenRange(use.Token)})
+	}
+
+	return locations
+}
+
+func (s *Server) documentSymbols(p DocumentSymbolParams) []DocumentSymbol {
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok || doc.program == nil {
+		return []DocumentSymbol{}
+	}
+
+	return doc.symbols(doc.program.Statements)
+}
+
+func (d *document) symbols(stmts []ast.Statement) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.ExportStatement:
+			switch exported := stmt.Statement.(type) {
+			case *ast.LetStatement:
+				symbols = append(symbols, d.letSymbol(stmt, exported))
+			case *ast.StructStatement:
+				symbols = append(symbols, d.structSymbol(exported))
+			}
+		case *ast.LetStatement:
+			if stmt.Pattern != nil {
+				for _, name := range stmt.Names() {
+					symbols = append(symbols, DocumentSymbol{
+						Name:           name.Value,
+						Kind:           SymbolKindVariable,
+						Range:          d.nodeRange(stmt),
+						SelectionRange: d.tokenRange(name.Token),
+					})
+				}
+				continue
+			}
+			symbols = append(symbols, d.letSymbol(stmt, stmt))
+		case *ast.StructStatement:
+			symbols = append(symbols, d.structSymbol(stmt))
+		case *ast.ImportStatement:
+			name := lint.ImportName(stmt)
+			symbols = append(symbols, DocumentSymbol{
+				Name:           name.Value,
+				Detail:         stmt.Path.Value,
+				Kind:           SymbolKindModule,
+				Range:          d.nodeRange(stmt),
+				SelectionRange: d.tokenRange(name.Token),
+			})
+		}
+	}
+
+	return symbols
+}
+
+func (d *document) structSymbol(stmt *ast.StructStatement) DocumentSymbol {
+	symbol := DocumentSymbol{
+		Name:           stmt.Name.Value,
+		Detail:         "struct",
+		Kind:           SymbolKindStruct,
+		Range:          d.nodeRange(stmt),
+		SelectionRange: d.tokenRange(stmt.Name.Token),
+	}
+
+	for _, field := range stmt.Fields {
+		symbol.Children = append(symbol.Children, DocumentSymbol{
+			Name:           field.Value,
+			Kind:           SymbolKindField,
+			Range:          d.tokenRange(field.Token),
+			SelectionRange: d.tokenRange(field.Token),
+		})
+	}
+	for _, method := range stmt.Methods {
+		symbol.Children = append(symbol.Children, DocumentSymbol{
+			Name:           method.Name,
+			Detail:         "fn(" + joinParameters(method) + ")",
+			Kind:           SymbolKindMethod,
+			Range:          d.nodeRange(method),
+			SelectionRange: d.tokenRange(method.Token),
+			Children:       d.symbols(method.Body.Statements),
+		})
+	}
+
+	return symbol
+}
+
+func (d *document) letSymbol(stmt ast.Statement, let *ast.LetStatement) DocumentSymbol {
+	symbol := DocumentSymbol{
+		Name:           let.Name.Value,
+		Kind:           SymbolKindVariable,
+		Range:          d.nodeRange(stmt),
+		SelectionRange: d.tokenRange(let.Name.Token),
+	}
+
+	switch value := let.Value.(type) {
+	case *ast.FunctionLiteral:
+		symbol.Kind = SymbolKindFunction
+		symbol.Detail = "fn(" + joinParameters(value) + ")"
+		symbol.Children = d.symbols(value.Body.Statements)
+	case *ast.MacroLiteral:
+		symbol.Kind = SymbolKindFunction
+		symbol.Detail = "macro(" + joinIdentifiers(value.Parameters) + ")"
+	}
+
+	return symbol
+}
+
+func (d *document) nodeRange(node ast.Node) Range {
+	start := ast.Pos(node)
+	end := start
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+
+		tok := ast.Pos(n)
+		switch n := n.(type) {
+		case *ast.BlockStatement:
+			if n.Rbrace.Line > 0 {
+				tok = n.Rbrace
+			}
+		case *ast.MatchExpression:
+			tok = n.Rbrace
+		case *ast.StructStatement:
+			tok = n.Rbrace
+		}
+		if tok.Line > end.Line || tok.Line == end.Line && tok.Column > end.Column {
+			end = tok
+		}
+		return true
+	})
+
+	return Range{Start: d.position(start.Line, start.Column), End: d.tokenRange(end).End}
+}
+
+func (s *Server) formatting(p DocumentFormattingParams) ([]TextEdit, *jsonrpc.Error) {
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, &jsonrpc.Error{Code: jsonrpc.InvalidParams, Message: "unknown document: " + p.TextDocument.URI}
+	}
+
+	formatted, err := format.Source([]byte(doc.text))
+	if err != nil {
+		return nil, nil
+	}
+
+	if string(formatted) == doc.text {
+		return []TextEdit{}, nil
+	}
+
+	return []TextEdit{{Range: Range{End: doc.end()}, NewText: string(formatted)}}, nil
+}
+
+func (s *Server) completion(p CompletionParams) []CompletionItem {
+	items := []CompletionItem{}
+	seen := make(map[string]bool)
+
+	if doc, ok := s.docs[p.TextDocument.URI]; ok {
+		for _, n := range doc.visibleNames(p.Position) {
+			if seen[n.ident.Value] {
+				continue
+			}
+			seen[n.ident.Value] = true
+			items = append(items, CompletionItem{Label: n.ident.Value, Kind: n.kind})
+		}
+	}
+
+	for _, name := range evaluator.BuiltinNames() {
+		if seen[name] {
+			continue
+		}
+		builtin, _ := evaluator.LookupBuiltin(name)
+		items = append(items, CompletionItem{
+			Label:  name,
+			Kind:   CompletionItemKindFunction,
+			Detail: name + "(" + strings.Join(builtin.Params, ", ") + ")",
+		})
+	}
+
+	for _, keyword := range keywords {
+		items = append(items, CompletionItem{Label: keyword, Kind: CompletionItemKindKeyword})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+
+	return items
+}
@@ -0,0 +1,363 @@
+package lsp
+
+import (
+	"encoding/json"
+	"goscript/jsonrpc"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testURI = "file:///tmp/main.gs"
+
+type testClient struct {
+	t    *testing.T
+	conn *jsonrpc.Conn
+	done chan error
+}
+
+func newTestClient(t *testing.T) *testClient {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	server := NewServer(jsonrpc.NewConn(serverIn, serverOut))
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve()
+		serverOut.Close()
+	}()
+
+	c := &testClient{t: t, conn: jsonrpc.NewConn(clientIn, clientOut), done: done}
+	c.call("initialize", InitializeParams{}, nil)
+	c.notify("initialized", struct{}{})
+
+	return c
+}
+
+func (c *testClient) call(method string, params, result interface{}) *jsonrpc.Error {
+	c.t.Helper()
+
+	id, err := c.conn.Request(method, params)
+	if err != nil {
+		c.t.Fatalf("request %s failed: %v", method, err)
+	}
+
+	for {
+		msg, err := c.conn.Read()
+		if err != nil {
+			c.t.Fatalf("reading response to %s failed: %v", method, err)
+		}
+		if !msg.IsResponse() || string(msg.ID) != string(id) {
+			continue
+		}
+
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("decoding response to %s failed: %v", method, err)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *testClient) notify(method string, params interface{}) {
+	c.t.Helper()
+
+	if err := c.conn.Notify(method, params); err != nil {
+		c.t.Fatalf("notification %s failed: %v", method, err)
+	}
+}
+
+func (c *testClient) open(text string) PublishDiagnosticsParams {
+	c.t.Helper()
+
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: testURI, LanguageID: "goscript", Version: 1, Text: text},
+	})
+
+	msg, err := c.conn.Read()
+	if err != nil {
+		c.t.Fatalf("reading diagnostics failed: %v", err)
+	}
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected diagnostics. got=%s", msg.Method)
+	}
+
+	var params PublishDiagnosticsParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		c.t.Fatalf("decoding diagnostics failed: %v", err)
+	}
+	return params
+}
+
+func (c *testClient) close() error {
+	c.t.Helper()
+
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	return <-c.done
+}
+
+func at(line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: testURI},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+func rng(line, start, end int) Range {
+	return Range{Start: Position{Line: line, Character: start}, End: Position{Line: line, Character: end}}
+}
+
+func TestInitializeAndShutdown(t *testing.T) {
+	c := newTestClient(t)
+
+	var result InitializeResult
+	c.call("initialize", InitializeParams{}, &result)
+	if !result.Capabilities.HoverProvider || result.Capabilities.TextDocumentSync != TextDocumentSyncFull {
+		t.Errorf("wrong capabilities. got=%+v", result.Capabilities)
+	}
+
+	if err := c.call("textDocument/unknown", struct{}{}, nil); err == nil || err.Code != jsonrpc.MethodNotFound {
+		t.Errorf("expected method not found. got=%v", err)
+	}
+
+	if err := c.close(); err != nil {
+		t.Errorf("Serve returned error: %v", err)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	c := newTestClient(t)
+	c.notify("exit", nil)
+
+	if err := <-c.done; err != ErrNoShutdown {
+		t.Errorf("expected ErrNoShutdown. got=%v", err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		testName string
+		input    string
+		expected []Diagnostic
+	}{
+		{"clean", "let x = 1;\nputs(x);", []Diagnostic{}},
+		{
+			"parse error",
+			"let = 1;",
+			[]Diagnostic{
+				{Range: rng(0, 4, 5), Severity: SeverityError, Source: "goscript", Message: "expected next token to be IDENT, got = instead"},
+				{Range: rng(0, 4, 5), Severity: SeverityError, Source: "goscript", Message: "no prefix parse function for = found"},
+			},
+		},
+		{
+			"lint findings",
+			"let unused = 1;\npush([1]);",
+			[]Diagnostic{
+				{Range: rng(0, 4, 10), Severity: SeverityWarning, Code: "unused", Source: "lint", Message: "unused is declared but never used"},
+				{Range: rng(1, 0, 4), Severity: SeverityWarning, Code: "arity", Source: "lint", Message: "push expects 2 arguments, got 1"},
+			},
+		},
+		{
+			"resolver errors",
+			"let f = fn(a, a) { b; let b = a; b };\nf(1, 2);",
+			[]Diagnostic{
+				{Range: rng(0, 14, 15), Severity: SeverityError, Source: "resolve", Message: "duplicate parameter a"},
+				{Range: rng(0, 19, 20), Severity: SeverityWarning, Code: "undefined", Source: "lint", Message: "b is used before it is defined"},
+			},
+		},
+		{
+			"type errors",
+			"let n: int = \"one\";\nputs(n);",
+			[]Diagnostic{
+				{Range: rng(0, 13, 14), Severity: SeverityError, Source: "check", Message: "cannot use string as int in let n"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			c := newTestClient(t)
+			defer c.close()
+
+			params := c.open(tt.input)
+			if params.URI != testURI {
+				t.Errorf("wrong uri. got=%q", params.URI)
+			}
+			if !reflect.DeepEqual(params.Diagnostics, tt.expected) {
+				t.Errorf("wrong diagnostics.\nwant=%+v\ngot= %+v", tt.expected, params.Diagnostics)
+			}
+		})
+	}
+}
+
+const navigationSource = `let add = fn(a, b) { a + b };
+let total = add(1, 2);
+let f = fn(total) { total + add(total, 1) };
+puts(f(total));`
+
+func TestHover(t *testing.T) {
+	c := newTestClient(t)
+	defer c.close()
+	c.open(navigationSource)
+
+	tests := []struct {
+		testName string
+		position TextDocumentPositionParams
+		expected string
+	}{
+		{"function", at(1, 13), "```goscript\nlet add = fn(a, b)\n```"},
+		{"value", at(3, 9), "```goscript\nlet total = add(1, 2)\n```"},
+		{"parameter", at(0, 21), "```goscript\na\n```\nparameter"},
+		{"builtin", at(3, 1), "```goscript\nputs(args...)\n```\nbuiltin function, takes at least 0 arguments"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			var hover *Hover
+			c.call("textDocument/hover", tt.position, &hover)
+
+			if hover == nil {
+				t.Fatalf("expected hover")
+			}
+			if hover.Contents.Value != tt.expected {
+				t.Errorf("wrong hover.\nwant=%q\ngot= %q", tt.expected, hover.Contents.Value)
+			}
+		})
+	}
+
+	var hover *Hover
+	c.call("textDocument/hover", at(0, 9), &hover)
+	if hover != nil {
+		t.Errorf("expected no hover on keyword. got=%+v", hover)
+	}
+}
+
+func TestDefinitionAndReferences(t *testing.T) {
+	c := newTestClient(t)
+	defer c.close()
+	c.open(navigationSource)
+
+	var locations []Location
+	c.call("textDocument/definition", at(2, 20), &locations)
+	if len(locations) != 1 || locations[0].Range != rng(2, 11, 16) {
+		t.Errorf("parameter should resolve to its declaration. got=%+v", locations)
+	}
+
+	c.call("textDocument/definition", at(3, 8), &locations)
+	if len(locations) != 1 || locations[0].Range != rng(1, 4, 9) {
+		t.Errorf("global should resolve to its let. got=%+v", locations)
+	}
+
+	refs := ReferenceParams{TextDocumentPositionParams: at(0, 5), Context: ReferenceContext{IncludeDeclaration: true}}
+	c.call("textDocument/references", refs, &locations)
+
+	expected := []Range{rng(0, 4, 7), rng(1, 12, 15), rng(2, 28, 31)}
+	got := []Range{}
+	for _, loc := range locations {
+		got = append(got, loc.Range)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong references.\nwant=%v\ngot= %v", expected, got)
+	}
+
+	refs.Context.IncludeDeclaration = false
+	c.call("textDocument/references", refs, &locations)
+	if len(locations) != 2 {
+		t.Errorf("declaration should be excluded. got=%+v", locations)
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := newTestClient(t)
+	defer c.close()
+	c.open("import u from \"util\";\nlet f = fn(x) {\n  let y = x;\n  y\n};\nexport let z = 1;")
+
+	var symbols []DocumentSymbol
+	c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: testURI}}, &symbols)
+
+	expected := []DocumentSymbol{
+		{Name: "u", Detail: "util", Kind: SymbolKindModule, Range: rng(0, 0, 20), SelectionRange: rng(0, 7, 8)},
+		{
+			Name: "f", Detail: "fn(x)", Kind: SymbolKindFunction,
+			Range:          Range{Start: Position{1, 0}, End: Position{4, 1}},
+			SelectionRange: rng(1, 4, 5),
+			Children: []DocumentSymbol{
+				{Name: "y", Kind: SymbolKindVariable, Range: rng(2, 2, 11), SelectionRange: rng(2, 6, 7)},
+			},
+		},
+		{Name: "z", Kind: SymbolKindVariable, Range: rng(5, 0, 16), SelectionRange: rng(5, 11, 12)},
+	}
+
+	if !reflect.DeepEqual(symbols, expected) {
+		t.Errorf("wrong symbols.\nwant=%+v\ngot= %+v", expected, symbols)
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	c := newTestClient(t)
+	defer c.close()
+	c.open("let x=1;\nlet s = fn(a){\"é\"}")
+
+	var edits []TextEdit
+	c.call("textDocument/formatting", DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: testURI}}, &edits)
+
+	if len(edits) != 1 {
+		t.Fatalf("expected one edit. got=%+v", edits)
+	}
+	if edits[0].Range != (Range{End: Position{Line: 1, Character: 18}}) {
+		t.Errorf("edit should replace the whole document. got=%+v", edits[0].Range)
+	}
+	if !strings.HasPrefix(edits[0].NewText, "let x = 1;\n") {
+		t.Errorf("wrong formatted text. got=%q", edits[0].NewText)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := newTestClient(t)
+	defer c.close()
+	c.open("let g = 1;\nlet f = fn(p) {\n  let local = p;\n  local\n};\n")
+
+	labels := func(pos TextDocumentPositionParams) map[string]int {
+		var items []CompletionItem
+		c.call("textDocument/completion", CompletionParams{pos}, &items)
+
+		kinds := make(map[string]int)
+		for _, item := range items {
+			kinds[item.Label] = item.Kind
+		}
+		return kinds
+	}
+
+	inside := labels(at(3, 2))
+	for label, kind := range map[string]int{
+		"g":     CompletionItemKindVariable,
+		"f":     CompletionItemKindFunction,
+		"p":     CompletionItemKindVariable,
+		"local": CompletionItemKindVariable,
+		"len":   CompletionItemKindFunction,
+		"let":   CompletionItemKindKeyword,
+		"match": CompletionItemKindKeyword,
+		"spawn": CompletionItemKindKeyword,
+		"yield": CompletionItemKindKeyword,
+		"for":   CompletionItemKindKeyword,
+		"in":    CompletionItemKindKeyword,
+	} {
+		if inside[label] != kind {
+			t.Errorf("completion %q inside function: want kind %d, got %d", label, kind, inside[label])
+		}
+	}
+
+	outside := labels(at(5, 0))
+	if _, ok := outside["local"]; ok {
+		t.Errorf("local should not be visible outside its function")
+	}
+	if _, ok := outside["p"]; ok {
+		t.Errorf("parameter should not be visible outside its function")
+	}
+}
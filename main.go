@@ -8,6 +8,10 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -16,3 +20,28 @@ func main() {
 	fmt.Printf("Feel free to type in commands\n")
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+func runCommand(name string, args []string) int {
+	switch name {
+	case "run":
+		return runMain(args)
+	case "fmt":
+		return fmtMain(args)
+	case "lint":
+		return lintMain(args)
+	case "lsp":
+		return lspMain(args)
+	case "debug":
+		return debugMain(args)
+	case "cover":
+		return coverMain(args)
+	case "test":
+		return testMain(args)
+	case "check":
+		return checkMain(args)
+	default:
+		fmt.Fprintf(os.Stderr, "goscript: unknown command %q\n", name)
+		fmt.Fprintf(os.Stderr, "usage: goscript [run|fmt|lint|lsp|debug|cover|test|check] [arguments]\n")
+		return 2
+	}
+}
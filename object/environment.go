@@ -1,8 +1,22 @@
 package object
 
+import (
+	"sort"
+	"sync"
+)
+
+// An Environment is either a map of names, used for modules and for code
+// that has not been resolved, or a frame for a resolved function call,
+// which keeps its locals in slots. Frames can still be searched by name,
+// so that unresolved code such as a debugger expression can read them.
+// Environments are shared by the tasks that close over them, so each one
+// guards its names and slots with a lock.
 type Environment struct {
+	mu    sync.RWMutex
 	store map[string]Object
 	outer *Environment
+	slots []Object
+	names []string
 }
 
 func NewEncloseEnvironment(outer *Environment) *Environment {
@@ -16,16 +30,104 @@ func NewEnvironment() *Environment {
 	return &Environment{store: s}
 }
 
+func NewFrame(outer *Environment, names []string) *Environment {
+	return &Environment{outer: outer, slots: make([]Object, len(names)), names: names}
+}
+
 func (e *Environment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name]
-	if !ok && e.outer != nil {
-		obj, ok = e.outer.Get(name)
+	for env := e; env != nil; env = env.outer {
+		if obj, ok := env.lookup(name); ok {
+			return obj, true
+		}
+	}
+
+	return nil, false
+}
+
+func (e *Environment) lookup(name string) (Object, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if i := e.slotIndex(name); i >= 0 && e.slots[i] != nil {
+		return e.slots[i], true
 	}
 
+	obj, ok := e.store[name]
 	return obj, ok
 }
 
+func (e *Environment) slotIndex(name string) int {
+	for i, n := range e.names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
 func (e *Environment) Set(name string, val Object) Object {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if i := e.slotIndex(name); i >= 0 {
+		e.slots[i] = val
+		return val
+	}
+
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
 	e.store[name] = val
 	return val
 }
+
+// Slot returns the value in slot of the frame depth environments out, or
+// nil if it has not been set yet.
+func (e *Environment) Slot(depth, slot int) Object {
+	env := e
+	for ; depth > 0; depth-- {
+		env = env.outer
+	}
+
+	env.mu.RLock()
+	defer env.mu.RUnlock()
+	return env.slots[slot]
+}
+
+func (e *Environment) SetSlot(slot int, val Object) Object {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.slots[slot] = val
+	return val
+}
+
+func (e *Environment) Root() *Environment {
+	env := e
+	for env.outer != nil {
+		env = env.outer
+	}
+	return env
+}
+
+func (e *Environment) Names() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	names := make([]string, 0, len(e.store)+len(e.names))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	for i, name := range e.names {
+		if _, ok := e.store[name]; !ok && e.slots[i] != nil {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
@@ -0,0 +1,146 @@
+package object
+
+import "strings"
+
+func Equal(a, b Object) bool {
+	if a == b {
+		return true
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		b, ok := b.(*Integer)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !Equal(a.Elements[i], b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		for _, pair := range a.Pairs() {
+			value, ok := b.Get(pair.Key.(Hashable))
+			if !ok || !Equal(pair.Value, value) {
+				return false
+			}
+		}
+		return true
+	case *Instance:
+		b, ok := b.(*Instance)
+		if !ok || a.Struct != b.Struct {
+			return false
+		}
+		for i := range a.Values {
+			if !Equal(a.Values[i], b.Values[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// Compare orders a and b, reporting false if they cannot be ordered. An
+// instance is ordered with the __lt__ method of its struct.
+func Compare(a, b Object) (int, bool) {
+	for _, obj := range []Object{a, b} {
+		if inst, ok := obj.(*Instance); ok && inst.Struct.Less != nil {
+			return compareLess(inst.Struct.Less, a, b)
+		}
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		b, ok := b.(*Integer)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case a.Value < b.Value:
+			return -1, true
+		case a.Value > b.Value:
+			return 1, true
+		default:
+			return 0, true
+		}
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case a.Value == b.Value:
+			return 0, true
+		case b.Value:
+			return -1, true
+		default:
+			return 1, true
+		}
+	case *String:
+		b, ok := b.(*String)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a.Value, b.Value), true
+	case *Null:
+		_, ok := b.(*Null)
+		return 0, ok
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok {
+			return 0, false
+		}
+		for i := 0; i < len(a.Elements) && i < len(b.Elements); i++ {
+			c, ok := Compare(a.Elements[i], b.Elements[i])
+			if !ok {
+				return 0, false
+			}
+			if c != 0 {
+				return c, true
+			}
+		}
+		switch {
+		case len(a.Elements) < len(b.Elements):
+			return -1, true
+		case len(a.Elements) > len(b.Elements):
+			return 1, true
+		default:
+			return 0, true
+		}
+	default:
+		return 0, false
+	}
+}
+
+func compareLess(less func(a, b Object) (bool, bool), a, b Object) (int, bool) {
+	if lt, ok := less(a, b); !ok || lt {
+		return -1, ok
+	}
+	gt, ok := less(b, a)
+	if !ok {
+		return 0, false
+	}
+	if gt {
+		return 1, true
+	}
+	return 0, true
+}
@@ -0,0 +1,68 @@
+package object
+
+import "sync"
+
+const ITERATOR_OBJ = "ITERATOR"
+
+// An Iterator produces its values one at a time, when they are asked for.
+// next returns nil once there are no more values, or an *Error if producing
+// one failed; either ends the iterator. stop, which may be nil, releases
+// whatever the iterator holds when it is abandoned before its end, such as
+// the body of a generator waiting at a yield.
+type Iterator struct {
+	mu      sync.Mutex
+	next    func() Object
+	stop    func()
+	running bool
+	done    bool
+}
+
+func NewIterator(next func() Object, stop func()) *Iterator {
+	return &Iterator{next: next, stop: stop}
+}
+
+func (it *Iterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *Iterator) Inspect() string  { return "iterator" }
+
+// Next returns the next value, or nil once the iterator is exhausted. An
+// iterator cannot be advanced from inside its own next, as a generator that
+// iterates over itself would do, so that is reported as an error.
+func (it *Iterator) Next() Object {
+	it.mu.Lock()
+	if it.done {
+		it.mu.Unlock()
+		return nil
+	}
+	if it.running {
+		it.mu.Unlock()
+		return &Error{Message: "iterator is already running"}
+	}
+	it.running = true
+	it.mu.Unlock()
+
+	value := it.next()
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.running = false
+	if value == nil || value.Type() == ERROR_OBJ {
+		it.done = true
+	}
+	return value
+}
+
+// Stop ends the iterator before it is exhausted. It does nothing if the
+// iterator has ended already or is producing a value.
+func (it *Iterator) Stop() {
+	it.mu.Lock()
+	if it.done || it.running {
+		it.mu.Unlock()
+		return
+	}
+	it.done = true
+	it.mu.Unlock()
+
+	if it.stop != nil {
+		it.stop()
+	}
+}
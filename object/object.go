@@ -2,6 +2,7 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"goscript/ast"
 	"hash/fnv"
@@ -21,6 +22,9 @@ const (
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	MODULE_OBJ       = "MODULE"
+	QUOTE_OBJ        = "QUOTE"
+	MACRO_OBJ        = "MACRO"
 )
 
 type Object interface {
@@ -29,6 +33,7 @@ type Object interface {
 }
 
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
 
@@ -62,6 +67,10 @@ func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
 type Error struct {
 	Message string
+
+	// Assertion is set on the errors of failed assertions, which a test
+	// runner reports as failures rather than as errors.
+	Assertion bool
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
@@ -69,8 +78,14 @@ func (e *Error) Inspect() string  { return "ERROR " + e.Message }
 
 type Function struct {
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression
+	Patterns   []ast.Expression
+	Rest       bool
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Name       string
+	Locals     []string
+	Generator  bool
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
@@ -78,8 +93,15 @@ func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
+	for i, p := range f.Parameters {
+		param := p.String()
+		if f.Rest && i == len(f.Parameters)-1 {
+			param = "..." + param
+		}
+		if i < len(f.Defaults) && f.Defaults[i] != nil {
+			param += " = " + f.Defaults[i].String()
+		}
+		params = append(params, param)
 	}
 
 	out.WriteString("fn")
@@ -101,12 +123,29 @@ func (s *String) Inspect() string  { return s.Value }
 
 type BuiltinFunction func(args ...Object) Object
 type Builtin struct {
-	Fn BuiltinFunction
+	Params []string
+	Fn     BuiltinFunction
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
 func (b *Builtin) Inspect() string  { return "builtin function" }
 
+func (b *Builtin) Arity() (min, max int) {
+	for _, param := range b.Params {
+		switch {
+		case strings.HasSuffix(param, "..."):
+			return min, -1
+		case strings.HasSuffix(param, "?"):
+			max++
+		default:
+			min++
+			max++
+		}
+	}
+
+	return min, max
+}
+
 type Array struct {
 	Elements []Object
 }
@@ -155,13 +194,51 @@ func (s *String) HashKey() HashKey {
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
 
+func (ao *Array) HashKey() HashKey {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+
+	for _, e := range ao.Elements {
+		el, ok := e.(Hashable)
+		if !ok {
+			h.Write([]byte(e.Type()))
+			continue
+		}
+
+		key := el.HashKey()
+		h.Write([]byte(key.Type))
+		binary.LittleEndian.PutUint64(buf, key.Value)
+		h.Write(buf)
+	}
+
+	return HashKey{Type: ao.Type(), Value: h.Sum64()}
+}
+
+func AsHashable(obj Object) (Hashable, bool) {
+	if arr, ok := obj.(*Array); ok {
+		for _, e := range arr.Elements {
+			if _, ok := AsHashable(e); !ok {
+				return nil, false
+			}
+		}
+	}
+
+	key, ok := obj.(Hashable)
+	return key, ok
+}
+
 type HashPair struct {
 	Key   Object
 	Value Object
 }
 
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	pairs   []HashPair
+	buckets map[HashKey][]int
+}
+
+func NewHash() *Hash {
+	return &Hash{buckets: make(map[HashKey][]int)}
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
@@ -170,7 +247,7 @@ func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.pairs {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 
@@ -179,4 +256,85 @@ func (h *Hash) Inspect() string {
 	out.WriteString("}")
 
 	return out.String()
-}
\ No newline at end of file
+}
+
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	idx, ok := h.lookup(key)
+	if !ok {
+		return nil, false
+	}
+
+	return h.pairs[idx].Value, true
+}
+
+func (h *Hash) Set(key Hashable, value Object) {
+	if idx, ok := h.lookup(key); ok {
+		h.pairs[idx].Value = value
+		return
+	}
+
+	if h.buckets == nil {
+		h.buckets = make(map[HashKey][]int)
+	}
+
+	hashed := key.HashKey()
+	h.buckets[hashed] = append(h.buckets[hashed], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: key, Value: value})
+}
+
+func (h *Hash) Len() int { return len(h.pairs) }
+
+func (h *Hash) Pairs() []HashPair { return h.pairs }
+
+func (h *Hash) lookup(key Hashable) (int, bool) {
+	for _, idx := range h.buckets[key.HashKey()] {
+		if Equal(h.pairs[idx].Key, key) {
+			return idx, true
+		}
+	}
+
+	return 0, false
+}
+
+type Module struct {
+	Name    string
+	Path    string
+	Exports map[string]Object
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return fmt.Sprintf("module(%s)", m.Name) }
+
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
@@ -58,3 +58,155 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestArrayHashKey(t *testing.T) {
+	arr1 := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	arr2 := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	diff := &Array{Elements: []Object{&String{Value: "a"}, &Integer{Value: 1}}}
+
+	if arr1.HashKey() != arr2.HashKey() {
+		t.Errorf("arrays with same content have different hash keys")
+	}
+
+	if arr1.HashKey() == diff.HashKey() {
+		t.Errorf("arrays with different content have same hash keys")
+	}
+
+	if _, ok := AsHashable(&Array{Elements: []Object{&Function{}}}); ok {
+		t.Errorf("array containing a function is hashable")
+	}
+}
+
+type collidingKey struct {
+	name string
+}
+
+func (c *collidingKey) Type() ObjectType { return "COLLIDING" }
+func (c *collidingKey) Inspect() string  { return c.name }
+func (c *collidingKey) HashKey() HashKey { return HashKey{Type: c.Type(), Value: 42} }
+
+func TestHashCollisions(t *testing.T) {
+	a := &collidingKey{name: "a"}
+	b := &collidingKey{name: "b"}
+
+	hash := NewHash()
+	hash.Set(a, &Integer{Value: 1})
+	hash.Set(b, &Integer{Value: 2})
+
+	if hash.Len() != 2 {
+		t.Fatalf("colliding keys were merged. got=%d pairs", hash.Len())
+	}
+
+	tests := []struct {
+		key      Hashable
+		expected int64
+	}{
+		{a, 1},
+		{b, 2},
+	}
+
+	for _, tt := range tests {
+		value, ok := hash.Get(tt.key)
+		if !ok {
+			t.Fatalf("no value for key %s", tt.key.Inspect())
+		}
+		if value.(*Integer).Value != tt.expected {
+			t.Errorf("wrong value for key %s. got=%s", tt.key.Inspect(), value.Inspect())
+		}
+	}
+
+	if _, ok := hash.Get(&collidingKey{name: "c"}); ok {
+		t.Errorf("lookup of an absent colliding key succeeded")
+	}
+}
+
+func TestHashSetReplaces(t *testing.T) {
+	hash := NewHash()
+	hash.Set(&String{Value: "key"}, &Integer{Value: 1})
+	hash.Set(&String{Value: "key"}, &Integer{Value: 2})
+
+	if hash.Len() != 1 {
+		t.Fatalf("hash has wrong num of pairs. got=%d", hash.Len())
+	}
+
+	value, _ := hash.Get(&String{Value: "key"})
+	if value.(*Integer).Value != 2 {
+		t.Errorf("value was not replaced. got=%s", value.Inspect())
+	}
+}
+
+func TestEqual(t *testing.T) {
+	hash1 := NewHash()
+	hash1.Set(&String{Value: "a"}, &Integer{Value: 1})
+	hash1.Set(&String{Value: "b"}, &Array{Elements: []Object{&Integer{Value: 2}}})
+	hash2 := NewHash()
+	hash2.Set(&String{Value: "b"}, &Array{Elements: []Object{&Integer{Value: 2}}})
+	hash2.Set(&String{Value: "a"}, &Integer{Value: 1})
+	fn := &Function{}
+
+	tests := []struct {
+		name     string
+		a, b     Object
+		expected bool
+	}{
+		{"integers", &Integer{Value: 1}, &Integer{Value: 1}, true},
+		{"strings", &String{Value: "a"}, &String{Value: "a"}, true},
+		{"different strings", &String{Value: "a"}, &String{Value: "b"}, false},
+		{"nulls", &Null{}, &Null{}, true},
+		{"arrays", &Array{Elements: []Object{&String{Value: "a"}}}, &Array{Elements: []Object{&String{Value: "a"}}}, true},
+		{"hashes", hash1, hash2, true},
+		{"hash and empty hash", hash1, NewHash(), false},
+		{"same function", fn, fn, true},
+		{"different functions", fn, &Function{}, false},
+		{"mixed types", &Integer{Value: 1}, &String{Value: "1"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if Equal(tt.a, tt.b) != tt.expected {
+				t.Errorf("Equal(%s, %s) wrong. want=%t", tt.a.Inspect(), tt.b.Inspect(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name       string
+		a, b       Object
+		expected   int
+		comparable bool
+	}{
+		{"integers", &Integer{Value: 1}, &Integer{Value: 2}, -1, true},
+		{"strings", &String{Value: "b"}, &String{Value: "a"}, 1, true},
+		{"booleans", &Boolean{Value: false}, &Boolean{Value: true}, -1, true},
+		{"arrays", &Array{Elements: []Object{&Integer{Value: 1}}}, &Array{Elements: []Object{&Integer{Value: 1}}}, 0, true},
+		{"array prefix", &Array{}, &Array{Elements: []Object{&Integer{Value: 1}}}, -1, true},
+		{"mixed arrays", &Array{Elements: []Object{&Integer{Value: 1}}}, &Array{Elements: []Object{&String{Value: "a"}}}, 0, false},
+		{"mixed types", &Integer{Value: 1}, &String{Value: "1"}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, ok := Compare(tt.a, tt.b)
+			if ok != tt.comparable || c != tt.expected {
+				t.Errorf("Compare(%s, %s) wrong. got=(%d, %t), want=(%d, %t)",
+					tt.a.Inspect(), tt.b.Inspect(), c, ok, tt.expected, tt.comparable)
+			}
+		})
+	}
+}
+
+func TestResetScheduler(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	Spawn(func() Object {
+		<-stop
+		return nil
+	})
+
+	ResetScheduler()
+	if _, _, err := NewChannel(0).Recv(); err != ErrDeadlock {
+		t.Fatalf("a task of an earlier program kept the new one from deadlocking. got=%v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package object
+
+import "strings"
+
+const (
+	STRUCT_OBJ   = "STRUCT"
+	INSTANCE_OBJ = "INSTANCE"
+)
+
+// A Struct is declared by a struct statement. Calling it constructs an
+// Instance from a value for each of its fields, in order.
+type Struct struct {
+	Name    string
+	Fields  []string
+	Methods map[string]*Function
+
+	// Str converts an instance to a string with the __str__ method of the
+	// struct, which the evaluator runs. It reports false if the struct has
+	// no such method or the method fails.
+	Str func(*Instance) (string, bool)
+
+	// Less reports whether a < b with the __lt__ method of the struct, one
+	// of a and b being its instance. It is nil if the struct has no such
+	// method, and reports false as its second result if the method fails.
+	Less func(a, b Object) (bool, bool)
+}
+
+func (s *Struct) Type() ObjectType { return STRUCT_OBJ }
+func (s *Struct) Inspect() string {
+	return "struct " + s.Name + " {" + strings.Join(s.Fields, ", ") + "}"
+}
+
+// Field returns the index of the field called name, or -1 if there is none.
+func (s *Struct) Field(name string) int {
+	for i, field := range s.Fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// An Instance is a value of a struct. Every instance has the same type, so
+// that no struct can pass for a builtin type by its name. Its fields are never changed; a with expression makes a copy instead. It
+// is inspected with the __str__ method of the struct if there is one.
+type Instance struct {
+	Struct *Struct
+	Values []Object
+}
+
+func (i *Instance) Type() ObjectType { return INSTANCE_OBJ }
+func (i *Instance) Inspect() string {
+	if i.Struct.Str != nil {
+		if str, ok := i.Struct.Str(i); ok {
+			return str
+		}
+	}
+
+	fields := make([]string, len(i.Values))
+	for j, value := range i.Values {
+		fields[j] = i.Struct.Fields[j] + ": " + value.Inspect()
+	}
+	return i.Struct.Name + "{" + strings.Join(fields, ", ") + "}"
+}
@@ -0,0 +1,319 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	TASK_OBJ    = "TASK"
+	CHANNEL_OBJ = "CHANNEL"
+)
+
+var (
+	ErrDeadlock   = errors.New("deadlock: all tasks are blocked")
+	ErrSendClosed = errors.New("send on closed channel")
+)
+
+// A scheduler counts the tasks of a program that are running, the main one
+// included, and keeps the waiters of those that are blocked on a channel or
+// on another task. When the last running task blocks or finishes while
+// others are blocked, none of them can ever be woken, so they are all woken
+// with ErrDeadlock instead. A task woken by another is counted as running by
+// the one that wakes it, so there is no moment at which it looks blocked.
+type scheduler struct {
+	sync.Mutex
+	running int
+	parked  map[*waiter]bool
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{running: 1, parked: make(map[*waiter]bool)}
+}
+
+// current is the scheduler of the program being run. Tasks and channels keep
+// the one they were created with.
+var current = struct {
+	sync.Mutex
+	s *scheduler
+}{s: newScheduler()}
+
+// ResetScheduler starts scheduling a new program, with only its main task
+// running. Tasks left blocked or running by an earlier program keep to the
+// scheduler of that program, so they can neither wake the tasks of the new
+// one nor make it look deadlocked.
+func ResetScheduler() {
+	current.Lock()
+	defer current.Unlock()
+	current.s = newScheduler()
+}
+
+func currentScheduler() *scheduler {
+	current.Lock()
+	defer current.Unlock()
+	return current.s
+}
+
+type waiter struct {
+	s     *scheduler
+	wake  chan struct{}
+	fired bool
+
+	index int
+	value Object
+	ok    bool
+	err   error
+}
+
+func newWaiter(s *scheduler) *waiter {
+	return &waiter{s: s, wake: make(chan struct{}, 1)}
+}
+
+// park blocks until w is fired. It must be called with the scheduler locked
+// and returns with it unlocked.
+func (w *waiter) park() {
+	w.s.parked[w] = true
+	w.s.running--
+	if w.s.running == 0 {
+		w.s.deadlock()
+	}
+	w.s.Unlock()
+
+	<-w.wake
+}
+
+// fire wakes w, unless it has been woken already: a task can be woken by
+// a deadlock before the task it awaits finishes.
+func (w *waiter) fire(index int, value Object, ok bool, err error) {
+	if w.fired {
+		return
+	}
+	w.fired = true
+	w.index, w.value, w.ok, w.err = index, value, ok, err
+
+	if w.s.parked[w] {
+		delete(w.s.parked, w)
+		w.s.running++
+	}
+	w.wake <- struct{}{}
+}
+
+func (s *scheduler) deadlock() {
+	for w := range s.parked {
+		w.fire(w.index, nil, false, ErrDeadlock)
+	}
+}
+
+type Task struct {
+	s       *scheduler
+	done    bool
+	result  Object
+	waiters []*waiter
+}
+
+func (t *Task) Type() ObjectType { return TASK_OBJ }
+func (t *Task) Inspect() string  { return "task" }
+
+// Spawn runs fn in a new goroutine and returns the task that awaits its
+// result.
+func Spawn(fn func() Object) *Task {
+	t := &Task{s: currentScheduler()}
+
+	t.s.Lock()
+	t.s.running++
+	t.s.Unlock()
+
+	go func() {
+		result := fn()
+
+		t.s.Lock()
+		defer t.s.Unlock()
+
+		t.done, t.result = true, result
+		for _, w := range t.waiters {
+			w.fire(0, result, true, nil)
+		}
+		t.waiters = nil
+
+		t.s.running--
+		if t.s.running == 0 {
+			t.s.deadlock()
+		}
+	}()
+
+	return t
+}
+
+func (t *Task) Await() (Object, error) {
+	t.s.Lock()
+	if t.done {
+		t.s.Unlock()
+		return t.result, nil
+	}
+
+	w := newWaiter(t.s)
+	t.waiters = append(t.waiters, w)
+	w.park()
+
+	return w.value, w.err
+}
+
+type Channel struct {
+	s         *scheduler
+	capacity  int
+	buffer    []Object
+	closed    bool
+	senders   []*blocked
+	receivers []*blocked
+}
+
+// A blocked send or receive. The waiter of a select is blocked on every
+// channel of its cases at once, and the first channel to fire it wins.
+type blocked struct {
+	w     *waiter
+	index int
+	value Object
+}
+
+func NewChannel(capacity int) *Channel {
+	return &Channel{s: currentScheduler(), capacity: capacity}
+}
+
+func (c *Channel) Type() ObjectType { return CHANNEL_OBJ }
+func (c *Channel) Inspect() string  { return fmt.Sprintf("channel(%d)", c.capacity) }
+
+func dequeue(queue *[]*blocked) *blocked {
+	for len(*queue) > 0 {
+		b := (*queue)[0]
+		*queue = (*queue)[1:]
+		if !b.w.fired {
+			return b
+		}
+	}
+	return nil
+}
+
+func (c *Channel) trySend(value Object) (bool, error) {
+	if c.closed {
+		return false, ErrSendClosed
+	}
+
+	if r := dequeue(&c.receivers); r != nil {
+		r.w.fire(r.index, value, true, nil)
+		return true, nil
+	}
+
+	if len(c.buffer) < c.capacity {
+		c.buffer = append(c.buffer, value)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (c *Channel) tryRecv() (Object, bool, bool) {
+	if len(c.buffer) > 0 {
+		value := c.buffer[0]
+		c.buffer = c.buffer[1:]
+		if s := dequeue(&c.senders); s != nil {
+			c.buffer = append(c.buffer, s.value)
+			s.w.fire(s.index, nil, true, nil)
+		}
+		return value, true, true
+	}
+
+	if s := dequeue(&c.senders); s != nil {
+		s.w.fire(s.index, nil, true, nil)
+		return s.value, true, true
+	}
+
+	if c.closed {
+		return nil, false, true
+	}
+
+	return nil, false, false
+}
+
+func (c *Channel) Close() error {
+	c.s.Lock()
+	defer c.s.Unlock()
+
+	if c.closed {
+		return errors.New("close of closed channel")
+	}
+	c.closed = true
+
+	for r := dequeue(&c.receivers); r != nil; r = dequeue(&c.receivers) {
+		r.w.fire(r.index, nil, false, nil)
+	}
+	for s := dequeue(&c.senders); s != nil; s = dequeue(&c.senders) {
+		s.w.fire(s.index, nil, false, ErrSendClosed)
+	}
+
+	return nil
+}
+
+func (c *Channel) Send(value Object) error {
+	_, _, _, err := Select([]SelectCase{{Channel: c, Send: true, Value: value}}, true)
+	return err
+}
+
+// Recv returns the next value sent on the channel, or false once the
+// channel is closed and drained.
+func (c *Channel) Recv() (Object, bool, error) {
+	_, value, ok, err := Select([]SelectCase{{Channel: c}}, true)
+	return value, ok, err
+}
+
+type SelectCase struct {
+	Channel *Channel
+	Send    bool
+	Value   Object
+}
+
+// Select performs the first of the cases that can proceed, in order, and
+// returns its index, and for a receive the value received and whether the
+// channel was still open. If none can proceed, Select blocks until one can,
+// or returns -1 if block is false.
+func Select(cases []SelectCase, block bool) (int, Object, bool, error) {
+	s := currentScheduler()
+	if len(cases) > 0 {
+		s = cases[0].Channel.s
+	}
+	s.Lock()
+
+	for i, sc := range cases {
+		if sc.Send {
+			done, err := sc.Channel.trySend(sc.Value)
+			if err != nil || done {
+				s.Unlock()
+				return i, nil, true, err
+			}
+			continue
+		}
+
+		if value, ok, done := sc.Channel.tryRecv(); done {
+			s.Unlock()
+			return i, value, ok, nil
+		}
+	}
+
+	if !block {
+		s.Unlock()
+		return -1, nil, false, nil
+	}
+
+	w := newWaiter(s)
+	for i, sc := range cases {
+		b := &blocked{w: w, index: i, value: sc.Value}
+		if sc.Send {
+			sc.Channel.senders = append(sc.Channel.senders, b)
+		} else {
+			sc.Channel.receivers = append(sc.Channel.receivers, b)
+		}
+	}
+	w.park()
+
+	return w.index, w.value, w.ok, w.err
+}
@@ -0,0 +1,466 @@
+package optimize
+
+import (
+	"goscript/ast"
+	"goscript/token"
+	"strconv"
+	"strings"
+)
+
+// A scope is the top level of a program, the body of a function, an arm of a
+// match or the self of a method, like the scopes of the resolver. bound
+// counts the lets, imports and parameters of each name; only a name bound
+// exactly once can be a constant.
+type scope struct {
+	outer     *scope
+	bound     map[string]int
+	constants map[string]ast.Expression
+}
+
+func newScope(outer *scope, stmts []ast.Statement) *scope {
+	s := &scope{outer: outer, bound: make(map[string]int), constants: make(map[string]ast.Expression)}
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.FunctionLiteral, *ast.MacroLiteral:
+				return false
+			case *ast.LetStatement:
+				for _, name := range node.Names() {
+					s.bound[name.Value]++
+				}
+			case *ast.ImportStatement:
+				s.bound[node.BoundName()]++
+			case *ast.ForStatement:
+				s.bound[node.Variable.Value]++
+			case *ast.StructStatement:
+				s.bound[node.Name.Value]++
+			}
+			return true
+		})
+	}
+	return s
+}
+
+// lookup returns the constant value of name. A binding of the name that is
+// not a constant, or whose let has not been reached yet, hides the outer ones.
+func (s *scope) lookup(name string) (ast.Expression, bool) {
+	for ; s != nil; s = s.outer {
+		if c, ok := s.constants[name]; ok {
+			return c, true
+		}
+		if s.bound[name] > 0 {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// Program rewrites the program in place: it folds operators whose operands
+// are literals, replaces if expressions whose condition is a literal with the
+// branch that is taken, and replaces the uses of names bound once to a
+// literal by the literal. Folded nodes keep the position of the expression
+// they replace. Operations that would fail at runtime, such as a division by
+// zero, are left for the evaluator to report.
+func Program(program *ast.Program) {
+	s := newScope(nil, program.Statements)
+	program.Statements = statements(program.Statements, s, true)
+}
+
+// statements optimises a list of statements. direct is false for the
+// statements of a block, whose lets may not run, so they do not define
+// constants.
+func statements(stmts []ast.Statement, s *scope, direct bool) []ast.Statement {
+	result := make([]ast.Statement, 0, len(stmts))
+	for i, stmt := range stmts {
+		last := i == len(stmts)-1
+
+		if es, ok := stmt.(*ast.ExpressionStatement); ok {
+			if ie, ok := es.Expression.(*ast.IfExpression); ok {
+				ie.Condition = expression(ie.Condition, s)
+				if taken, ok := truthy(ie.Condition); ok {
+					branch := ie.Alternative
+					if taken {
+						branch = ie.Consequence
+					}
+
+					// Blocks do not introduce scopes, so the taken branch can
+					// replace the if, unless the if is the value of the list
+					// and the branch has no value of its own.
+					if branch != nil && len(branch.Statements) > 0 {
+						result = append(result, statements(branch.Statements, s, direct)...)
+						continue
+					}
+					if !last {
+						continue
+					}
+				}
+
+				es.Expression = prune(ie, s)
+				result = append(result, es)
+				continue
+			}
+		}
+
+		result = append(result, statement(stmt, s, direct))
+	}
+	return result
+}
+
+func statement(stmt ast.Statement, s *scope, direct bool) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		let(stmt, s, direct)
+	case *ast.ExportStatement:
+		statement(stmt.Statement, s, direct)
+	case *ast.ReturnStatement:
+		stmt.ReturnValue = expression(stmt.ReturnValue, s)
+	case *ast.YieldStatement:
+		stmt.Value = expression(stmt.Value, s)
+	case *ast.ForStatement:
+		stmt.Iterable = expression(stmt.Iterable, s)
+		block(stmt.Body, s)
+	case *ast.StructStatement:
+		for _, method := range stmt.Methods {
+			self := newScope(s, nil)
+			self.bound["self"]++
+			function(method, self)
+		}
+	case *ast.ExpressionStatement:
+		stmt.Expression = expression(stmt.Expression, s)
+	case *ast.BlockStatement:
+		block(stmt, s)
+	}
+	return stmt
+}
+
+func let(stmt *ast.LetStatement, s *scope, direct bool) {
+	stmt.Value = expression(stmt.Value, s)
+
+	// The let itself stays, so that the name is still defined for
+	// importers, the debugger and code that looks it up by name.
+	if direct && stmt.Name != nil && s.bound[stmt.Name.Value] == 1 && isLiteral(stmt.Value) {
+		s.constants[stmt.Name.Value] = stmt.Value
+	}
+}
+
+func block(b *ast.BlockStatement, s *scope) {
+	b.Statements = statements(b.Statements, s, false)
+}
+
+func expression(exp ast.Expression, s *scope) ast.Expression {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if c, ok := s.lookup(exp.Value); ok {
+			return at(c, exp.Token)
+		}
+	case *ast.PrefixExpression:
+		exp.Right = expression(exp.Right, s)
+		if folded := prefix(exp); folded != nil {
+			return folded
+		}
+	case *ast.SpawnExpression:
+		exp.Call = expression(exp.Call, s)
+	case *ast.SpreadExpression:
+		exp.Value = expression(exp.Value, s)
+	case *ast.InfixExpression:
+		exp.Left = expression(exp.Left, s)
+		exp.Right = expression(exp.Right, s)
+		if folded := infix(exp); folded != nil {
+			return folded
+		}
+	case *ast.IfExpression:
+		exp.Condition = expression(exp.Condition, s)
+		return prune(exp, s)
+	case *ast.MatchExpression:
+		exp.Value = expression(exp.Value, s)
+		for _, arm := range exp.Arms {
+			inner := newScope(s, nil)
+			for _, name := range ast.PatternNames(arm.Pattern) {
+				inner.bound[name.Value]++
+			}
+			if arm.Guard != nil {
+				arm.Guard = expression(arm.Guard, inner)
+			}
+			arm.Body = expression(arm.Body, inner)
+		}
+	case *ast.FunctionLiteral:
+		function(exp, s)
+	case *ast.CallExpression:
+		// Quoted code is data, and the values of its unquoted parts are
+		// converted back into code, so neither is rewritten.
+		if ident, ok := exp.Function.(*ast.Identifier); ok && (ident.Value == "quote" || ident.Value == "unquote") {
+			return exp
+		}
+		exp.Function = expression(exp.Function, s)
+		for i, arg := range exp.Arguments {
+			exp.Arguments[i] = expression(arg, s)
+		}
+	case *ast.ArrayLiteral:
+		for i, el := range exp.Elements {
+			exp.Elements[i] = expression(el, s)
+		}
+	case *ast.InterpolatedString:
+		for i, part := range exp.Parts {
+			exp.Parts[i] = expression(part, s)
+		}
+		if folded := interpolate(exp); folded != nil {
+			return folded
+		}
+	case *ast.HashLiteral:
+		pairs := make(map[ast.Expression]ast.Expression, len(exp.Pairs))
+		for i, key := range exp.Keys {
+			value := exp.Pairs[key]
+			exp.Keys[i] = expression(key, s)
+			pairs[exp.Keys[i]] = expression(value, s)
+		}
+		exp.Pairs = pairs
+	case *ast.IndexExpression:
+		exp.Left = expression(exp.Left, s)
+		exp.Index = expression(exp.Index, s)
+	case *ast.MemberExpression:
+		exp.Object = expression(exp.Object, s)
+	case *ast.WithExpression:
+		exp.Left = expression(exp.Left, s)
+		for i, value := range exp.Values {
+			exp.Values[i] = expression(value, s)
+		}
+	}
+	return exp
+}
+
+// prune removes the branch that is not taken from an if whose condition
+// has been folded and whose value is used. The if is replaced by the taken
+// branch if that is a single expression.
+func prune(ie *ast.IfExpression, s *scope) ast.Expression {
+	taken, ok := truthy(ie.Condition)
+	if !ok {
+		block(ie.Consequence, s)
+		if ie.Alternative != nil {
+			block(ie.Alternative, s)
+		}
+		return ie
+	}
+
+	branch := ie.Alternative
+	if taken {
+		branch = ie.Consequence
+	}
+	if branch == nil {
+		ie.Consequence.Statements = nil
+		return ie
+	}
+
+	block(branch, s)
+	if len(branch.Statements) == 1 {
+		if es, ok := branch.Statements[0].(*ast.ExpressionStatement); ok {
+			return es.Expression
+		}
+	}
+
+	ie.Condition = &ast.Boolean{Token: retoken(ie.Condition, token.TRUE, "true"), Value: true}
+	ie.Consequence = branch
+	ie.Alternative = nil
+	return ie
+}
+
+func function(fl *ast.FunctionLiteral, s *scope) {
+	inner := newScope(s, fl.Body.Statements)
+	for i, p := range fl.Parameters {
+		inner.bound[p.Value]++
+		for _, name := range ast.PatternNames(fl.ParameterPattern(i)) {
+			inner.bound[name.Value]++
+		}
+	}
+	for i, d := range fl.Defaults {
+		if d != nil {
+			fl.Defaults[i] = expression(d, inner)
+		}
+	}
+	fl.Body.Statements = statements(fl.Body.Statements, inner, true)
+}
+
+func isLiteral(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	}
+	return false
+}
+
+// truthy reports whether a literal is truthy, like the evaluator does, and
+// whether exp is a literal at all.
+func truthy(exp ast.Expression) (bool, bool) {
+	if !isLiteral(exp) {
+		return false, false
+	}
+	if b, ok := exp.(*ast.Boolean); ok {
+		return b.Value, true
+	}
+	return true, true
+}
+
+// interpolate merges the literals interpolated into a string with the text
+// around them, and folds the string into a literal if nothing else is left.
+func interpolate(exp *ast.InterpolatedString) ast.Expression {
+	var parts []ast.Expression
+	var text strings.Builder
+	var first ast.Expression
+
+	for _, part := range exp.Parts {
+		if !isLiteral(part) {
+			if first != nil {
+				parts = append(parts, str(first, text.String()))
+				text.Reset()
+				first = nil
+			}
+			parts = append(parts, part)
+			continue
+		}
+
+		if first == nil {
+			first = part
+		}
+		switch part := part.(type) {
+		case *ast.StringLiteral:
+			text.WriteString(part.Value)
+		case *ast.IntegerLiteral:
+			text.WriteString(strconv.FormatInt(part.Value, 10))
+		case *ast.Boolean:
+			text.WriteString(strconv.FormatBool(part.Value))
+		}
+	}
+
+	if len(parts) == 0 {
+		return str(exp, text.String())
+	}
+	if first != nil {
+		parts = append(parts, str(first, text.String()))
+	}
+	exp.Parts = parts
+	return nil
+}
+
+func prefix(exp *ast.PrefixExpression) ast.Expression {
+	switch exp.Operator {
+	case "!":
+		if taken, ok := truthy(exp.Right); ok {
+			return boolean(exp, !taken)
+		}
+	case "-":
+		if right, ok := exp.Right.(*ast.IntegerLiteral); ok {
+			return integer(exp, -right.Value)
+		}
+	}
+	return nil
+}
+
+func infix(exp *ast.InfixExpression) ast.Expression {
+	if !isLiteral(exp.Left) || !isLiteral(exp.Right) {
+		return nil
+	}
+
+	switch left := exp.Left.(type) {
+	case *ast.IntegerLiteral:
+		if right, ok := exp.Right.(*ast.IntegerLiteral); ok {
+			return integerInfix(exp, left.Value, right.Value)
+		}
+	case *ast.StringLiteral:
+		if right, ok := exp.Right.(*ast.StringLiteral); ok {
+			switch exp.Operator {
+			case "+":
+				return str(exp, left.Value+right.Value)
+			case "<":
+				return boolean(exp, left.Value < right.Value)
+			case ">":
+				return boolean(exp, left.Value > right.Value)
+			}
+		}
+	}
+
+	switch exp.Operator {
+	case "==":
+		return boolean(exp, literalEqual(exp.Left, exp.Right))
+	case "!=":
+		return boolean(exp, !literalEqual(exp.Left, exp.Right))
+	}
+	return nil
+}
+
+func integerInfix(exp *ast.InfixExpression, left, right int64) ast.Expression {
+	switch exp.Operator {
+	case "+":
+		return integer(exp, left+right)
+	case "-":
+		return integer(exp, left-right)
+	case "*":
+		return integer(exp, left*right)
+	case "/":
+		if right == 0 {
+			return nil
+		}
+		return integer(exp, left/right)
+	case "<":
+		return boolean(exp, left < right)
+	case ">":
+		return boolean(exp, left > right)
+	case "==":
+		return boolean(exp, left == right)
+	case "!=":
+		return boolean(exp, left != right)
+	}
+	return nil
+}
+
+func literalEqual(a, b ast.Expression) bool {
+	switch a := a.(type) {
+	case *ast.IntegerLiteral:
+		b, ok := b.(*ast.IntegerLiteral)
+		return ok && a.Value == b.Value
+	case *ast.StringLiteral:
+		b, ok := b.(*ast.StringLiteral)
+		return ok && a.Value == b.Value
+	case *ast.Boolean:
+		b, ok := b.(*ast.Boolean)
+		return ok && a.Value == b.Value
+	}
+	return false
+}
+
+func retoken(node ast.Node, typ token.TokenType, literal string) token.Token {
+	pos := ast.Pos(node)
+	return token.Token{Type: typ, Literal: literal, Line: pos.Line, Column: pos.Column}
+}
+
+func integer(node ast.Node, value int64) *ast.IntegerLiteral {
+	return &ast.IntegerLiteral{Token: retoken(node, token.INT, strconv.FormatInt(value, 10)), Value: value}
+}
+
+func str(node ast.Node, value string) *ast.StringLiteral {
+	return &ast.StringLiteral{Token: retoken(node, token.STRING, value), Value: value}
+}
+
+func boolean(node ast.Node, value bool) *ast.Boolean {
+	if value {
+		return &ast.Boolean{Token: retoken(node, token.TRUE, "true"), Value: true}
+	}
+	return &ast.Boolean{Token: retoken(node, token.FALSE, "false"), Value: false}
+}
+
+// at returns a copy of the literal c positioned at tok.
+func at(c ast.Expression, tok token.Token) ast.Expression {
+	switch c := c.(type) {
+	case *ast.IntegerLiteral:
+		lit := *c
+		lit.Token.Line, lit.Token.Column = tok.Line, tok.Column
+		return &lit
+	case *ast.StringLiteral:
+		lit := *c
+		lit.Token.Line, lit.Token.Column = tok.Line, tok.Column
+		return &lit
+	case *ast.Boolean:
+		lit := *c
+		lit.Token.Line, lit.Token.Column = tok.Line, tok.Column
+		return &lit
+	}
+	return c
+}
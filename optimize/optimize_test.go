@@ -0,0 +1,161 @@
+package optimize
+
+import (
+	"goscript/ast"
+	"goscript/format"
+	"goscript/lexer"
+	"goscript/parser"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		t.Fatalf("parser errors: %v", errs)
+	}
+	return program
+}
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		testName string
+		input    string
+		expected string
+	}{
+		{
+			"integer arithmetic",
+			"puts(60 * 60 * 24, 10 - 2 - 3, 7 / 2, -(1 + 2));",
+			"puts(86400, 5, 3, -3);\n",
+		},
+		{
+			"comparisons",
+			"puts(1 < 2, 1 > 2, 1 == 1, 1 != 1, \"a\" < \"b\", true == false, 1 == \"1\", 1 != true);",
+			"puts(true, false, true, false, true, false, false, true);\n",
+		},
+		{
+			"string concatenation",
+			"puts(\"a\" + \"b\" + \"c\");",
+			"puts(\"abc\");\n",
+		},
+		{
+			"interpolated strings",
+			"let n = 2; let f = fn(x) { x }; puts(\"${n + 1} ${true} ${\"s\"}\", \"${n} ${f(n)}\");",
+			"let n = 2;\nlet f = fn(x) {\n\tx;\n};\nputs(\"3 true s\", \"2 ${f(2)}\");\n",
+		},
+		{
+			"boolean prefix",
+			"puts(!true, !!false, !0, !\"\");",
+			"puts(false, false, false, false);\n",
+		},
+		{
+			"operations that fail are kept",
+			"puts(1 / 0, 1 + \"a\", -true, true + true, \"a\" - \"b\");",
+			"puts(1 / 0, 1 + \"a\", -true, true + true, \"a\" - \"b\");\n",
+		},
+		{
+			"partially constant expressions",
+			"let f = fn(x) { x * (2 + 3) };",
+			"let f = fn(x) {\n\tx * 5;\n};\n",
+		},
+		{
+			"constant condition statement",
+			"puts(1);\nif (1 < 2) { puts(2); puts(3); } else { puts(4); }\nif (false) { puts(5); }\nputs(6);",
+			"puts(1);\nputs(2);\nputs(3);\nputs(6);\n",
+		},
+		{
+			"constant condition else branch",
+			"let f = fn() { if (false) { 1 } else { let y = 2; y } };",
+			"let f = fn() {\n\tlet y = 2;\n\t2;\n};\n",
+		},
+		{
+			"untaken branch without else as the value",
+			"let f = fn() { puts(1); if (false) { 2 } };",
+			"let f = fn() {\n\tputs(1);\n\tif (false) {}\n};\n",
+		},
+		{
+			"constant condition expression",
+			"let a = if (true) { 1 } else { 2 };\nlet b = if (0) { puts(1); 2 } else { 3 };",
+			"let a = 1;\nlet b = if (true) {\n\tputs(1);\n\t2;\n};\n",
+		},
+		{
+			"unknown condition",
+			"let f = fn(x) { if (x) { 1 + 1 } else { 2 * 2 } };",
+			"let f = fn(x) {\n\tif (x) {\n\t\t2;\n\t} else {\n\t\t4;\n\t}\n};\n",
+		},
+		{
+			"constants",
+			"let day = 60 * 60 * 24;\nlet week = day * 7;\nlet name = \"x\";\nlet f = fn() { puts(name, week) };",
+			"let day = 86400;\nlet week = 604800;\nlet name = \"x\";\nlet f = fn() {\n\tputs(\"x\", 604800);\n};\n",
+		},
+		{
+			"rebound names are not constants",
+			"let x = 1;\nlet x = 2;\nputs(x);",
+			"let x = 1;\nlet x = 2;\nputs(x);\n",
+		},
+		{
+			"conditional lets are not constants",
+			"let f = fn(c) { if (c) { let x = 1; } x };",
+			"let f = fn(c) {\n\tif (c) {\n\t\tlet x = 1;\n\t}\n\tx;\n};\n",
+		},
+		{
+			"uses before the let are kept",
+			"let f = fn() { x };\nlet x = 1;\nf();",
+			"let f = fn() {\n\tx;\n};\nlet x = 1;\nf();\n",
+		},
+		{
+			"shadowed constants",
+			"let x = 1;\nlet f = fn(x) { x };\nlet g = fn() { let y = x; let x = 2; y };",
+			"let x = 1;\nlet f = fn(x) {\n\tx;\n};\nlet g = fn() {\n\tlet y = x;\n\tlet x = 2;\n\ty;\n};\n",
+		},
+		{
+			"loop variables are not constants",
+			"let x = 1;\nlet f = fn(xs) { for (x in xs) { yield x + 1 * 2; } };",
+			"let x = 1;\nlet f = fn(xs) {\n\tfor (x in xs) {\n\t\tyield x + 2;\n\t}\n};\n",
+		},
+		{
+			"quoted code is kept",
+			"let x = 1;\nquote(x + 1 * 2);",
+			"let x = 1;\nquote(x + 1 * 2);\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			program := parse(t, tt.input)
+			Program(program)
+
+			if got := format.Program(program); got != tt.expected {
+				t.Errorf("wrong program.\ngot:\n%s\nwant:\n%s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPositions(t *testing.T) {
+	program := parse(t, "let x = 2;\nputs(x,\n  1 + 2 * 3);")
+	Program(program)
+
+	call := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+
+	tests := []struct {
+		node   ast.Expression
+		line   int
+		column int
+	}{
+		{call.Arguments[0], 2, 6},
+		{call.Arguments[1], 3, 3},
+	}
+
+	for i, tt := range tests {
+		if _, ok := tt.node.(*ast.IntegerLiteral); !ok {
+			t.Errorf("argument %d is not folded. got=%T", i, tt.node)
+			continue
+		}
+		pos := ast.Pos(tt.node)
+		if pos.Line != tt.line || pos.Column != tt.column {
+			t.Errorf("wrong position of argument %d. got=%d:%d, want=%d:%d", i, pos.Line, pos.Column, tt.line, tt.column)
+		}
+	}
+}
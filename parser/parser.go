@@ -6,6 +6,7 @@ import (
 	"goscript/lexer"
 	"goscript/token"
 	"strconv"
+	"strings"
 )
 
 type (
@@ -24,6 +25,8 @@ var precedences = map[token.TokenType]int{
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
+	token.WITH:     INDEX,
 }
 
 const (
@@ -44,7 +47,12 @@ type Parser struct {
 	curToken  token.Token
 	peekToken token.Token
 
-	errors []string
+	errors   []Error
+	comments []*ast.Comment
+
+	// functions holds the function literals being parsed, innermost last,
+	// with nil for a macro literal.
+	functions []*ast.FunctionLiteral
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
@@ -53,7 +61,7 @@ type Parser struct {
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: []Error{},
 	}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
@@ -66,9 +74,12 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.SPAWN, p.parseSpawnExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfix)
@@ -81,6 +92,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GT, p.parseInfix)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
+	p.registerInfix(token.WITH, p.parseWithExpression)
 
 	p.nextToken()
 	p.nextToken()
@@ -88,13 +101,40 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
 func (p *Parser) Errors() []string {
+	msgs := make([]string, len(p.errors))
+	for i, err := range p.errors {
+		msgs[i] = err.Message
+	}
+	return msgs
+}
+
+func (p *Parser) ErrorList() []Error {
 	return p.errors
 }
 
+func (p *Parser) addError(tok token.Token, msg string) {
+	p.errors = append(p.errors, Error{Line: tok.Line, Column: tok.Column, Message: msg})
+}
+
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	for p.peekToken.Type == token.COMMENT {
+		p.comments = append(p.comments, &ast.Comment{Token: p.peekToken, Text: p.peekToken.Literal})
+		p.peekToken = p.l.NextToken()
+	}
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
@@ -114,6 +154,8 @@ func (p *Parser) ParseProgram() *ast.Program {
 		p.nextToken()
 	}
 
+	program.Comments = p.comments
+
 	return program
 }
 
@@ -123,6 +165,16 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
+	case token.YIELD:
+		return p.parseYieldStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.STRUCT:
+		return p.parseStructStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -142,7 +194,7 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken, msg)
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
@@ -170,11 +222,24 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.curToken}
 
-	if !p.expectPeek(token.IDENT) {
-		return nil
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		if stmt.Pattern = p.parsePattern(false); stmt.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		if stmt.Type = p.parseTypeAnnotation(); stmt.Type == nil {
+			return nil
+		}
+	}
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -184,6 +249,10 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok && stmt.Name != nil {
+		fl.Name = stmt.Name.Value
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -205,13 +274,261 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseYieldStatement() *ast.YieldStatement {
+	stmt := &ast.YieldStatement{Token: p.curToken}
+
+	if len(p.functions) == 0 || p.functions[len(p.functions)-1] == nil {
+		p.addError(p.curToken, "yield is only allowed in a function")
+	} else {
+		p.functions[len(p.functions)-1].Generator = true
+	}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		if !p.peekTokenIs(token.IDENT) || p.peekToken.Literal != "from" {
+			msg := fmt.Sprintf("expected next token to be from, got %s instead", p.peekToken.Type)
+			p.addError(p.peekToken, msg)
+			return nil
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseExportStatement() *ast.ExportStatement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+
+	switch {
+	case p.peekTokenIs(token.STRUCT):
+		p.nextToken()
+		ss := p.parseStructStatement()
+		if ss == nil {
+			return nil
+		}
+		stmt.Statement = ss
+	case p.peekTokenIs(token.LET):
+		p.nextToken()
+		let := p.parseLetStatement()
+		if let == nil {
+			return nil
+		}
+		if let.Pattern != nil {
+			p.addError(ast.Pos(let.Pattern), "cannot export a destructuring let")
+			return nil
+		}
+		stmt.Statement = let
+	default:
+		p.addError(p.peekToken, fmt.Sprintf("expected let or struct after export, got %s instead", p.peekToken.Type))
+		return nil
+	}
+
+	return stmt
+}
+
+// patternTypes are the types a match pattern can test for on their own.
+// After a name and a colon, a pattern can also test for fn or name a struct
+// to test for its instances.
+var patternTypes = map[string]bool{
+	"int":      true,
+	"string":   true,
+	"bool":     true,
+	"null":     true,
+	"array":    true,
+	"hash":     true,
+	"iterator": true,
+}
+
+// parsePattern parses what a let or a parameter binds, starting at the
+// current token: a name, an array pattern or a hash pattern. The patterns of
+// a match are refutable and can also be literals and types.
+func (p *Parser) parsePattern(refutable bool) ast.Expression {
+	switch p.curToken.Type {
+	case token.IDENT:
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !refutable {
+			return ident
+		}
+		if patternTypes[ident.Value] {
+			return &ast.TypePattern{Token: ident.Token, Type: ident.Value}
+		}
+		if !p.peekTokenIs(token.COLON) {
+			return ident
+		}
+		p.nextToken()
+		if p.peekTokenIs(token.FUNCTION) {
+			p.nextToken()
+		} else if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		return &ast.TypePattern{Token: p.curToken, Name: ident, Type: p.curToken.Literal}
+	case token.LBRACKET:
+		return p.parseArrayPattern(refutable)
+	case token.LBRACE:
+		return p.parseHashPattern(refutable)
+	}
+
+	if refutable {
+		switch p.curToken.Type {
+		case token.INT:
+			return p.parseIntegerLiteral()
+		case token.STRING:
+			if lit, ok := p.parseStringLiteral().(*ast.StringLiteral); ok {
+				return lit
+			}
+			p.addError(p.curToken, "cannot interpolate into a pattern")
+			return nil
+		case token.TRUE, token.FALSE:
+			return p.parseBoolean()
+		case token.MINUS:
+			if p.peekTokenIs(token.INT) {
+				exp := &ast.PrefixExpression{Token: p.curToken, Operator: p.curToken.Literal}
+				p.nextToken()
+				exp.Right = p.parseIntegerLiteral()
+				return exp
+			}
+		}
+
+		p.addError(p.curToken, fmt.Sprintf("expected a pattern, got %s instead", p.curToken.Type))
+		return nil
+	}
+
+	p.addError(p.curToken, fmt.Sprintf("expected a name or a pattern, got %s instead", p.curToken.Type))
+	return nil
+}
+
+func (p *Parser) parseArrayPattern(refutable bool) ast.Expression {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			pattern.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			break
+		}
+
+		el := p.parsePattern(refutable)
+		if el == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, el)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return pattern
+}
+
+func (p *Parser) parseHashPattern(refutable bool) ast.Expression {
+	pattern := &ast.HashPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		key := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		var value ast.Expression
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			if value = p.parsePattern(refutable); value == nil {
+				return nil
+			}
+		} else {
+			value = &ast.Identifier{Token: key.Token, Value: key.Value}
+		}
+
+		pattern.Keys = append(pattern.Keys, key)
+		pattern.Values = append(pattern.Values, value)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	return pattern
+}
+
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, msg)
 		return nil
 	}
 
@@ -240,7 +557,7 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, msg)
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
@@ -264,6 +581,16 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseSpawnExpression() ast.Expression {
+	expression := &ast.SpawnExpression{Token: p.curToken}
+
+	p.nextToken()
+
+	expression.Call = p.parseExpression(PREFIX)
+
+	return expression
+}
+
 func (p *Parser) parseInfix(left ast.Expression) ast.Expression {
 	expression := &ast.InfixExpression{
 		Token:    p.curToken,
@@ -326,6 +653,65 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		arm := &ast.MatchArm{}
+		if arm.Pattern = p.parsePattern(true); arm.Pattern == nil {
+			return nil
+		}
+
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			p.nextToken()
+			if arm.Guard = p.parseExpression(LOWEST); arm.Guard == nil {
+				return nil
+			}
+		}
+
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+		arm.Token = p.curToken
+
+		p.nextToken()
+		if arm.Body = p.parseExpression(LOWEST); arm.Body == nil {
+			return nil
+		}
+		expression.Arms = append(expression.Arms, arm)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	expression.Rbrace = p.curToken
+
+	return expression
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
@@ -333,6 +719,11 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		if p.curTokenIs(token.IMPORT) || p.curTokenIs(token.EXPORT) {
+			msg := fmt.Sprintf("%s is only allowed at the top level", p.curToken.Literal)
+			p.addError(p.curToken, msg)
+		}
+
 		stmt := p.parseStatement()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
@@ -340,6 +731,8 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		p.nextToken()
 	}
 
+	block.Rbrace = p.curToken
+
 	return block
 }
 
@@ -350,53 +743,390 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	p.parseFunctionParameters(lit)
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		if lit.ReturnType = p.parseTypeAnnotation(); lit.ReturnType == nil {
+			return nil
+		}
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
+	p.functions = append(p.functions, lit)
 	lit.Body = p.parseBlockStatement()
+	p.functions = p.functions[:len(p.functions)-1]
 
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+func (p *Parser) parseStructStatement() ast.Statement {
+	stmt := &ast.StructStatement{Token: p.curToken}
 
-	if p.peekTokenIs(token.RPAREN) {
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	members := make(map[string]bool)
+	member := func(tok token.Token) {
+		if members[tok.Literal] {
+			p.addError(tok, fmt.Sprintf("duplicate member %s of struct %s", tok.Literal, stmt.Name.Value))
+		}
+		members[tok.Literal] = true
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		switch p.curToken.Type {
+		case token.IDENT:
+			member(p.curToken)
+			stmt.Fields = append(stmt.Fields, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		case token.FUNCTION:
+			tok := p.curToken
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			member(p.curToken)
+			name := p.curToken.Literal
+
+			method, ok := p.parseFunctionLiteral().(*ast.FunctionLiteral)
+			if !ok {
+				return nil
+			}
+			method.Token = tok
+			method.Name = name
+			stmt.Methods = append(stmt.Methods, method)
+		default:
+			p.addError(p.curToken, fmt.Sprintf("expected a field or a method, got %s instead", p.curToken.Type))
+			return nil
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 		p.nextToken()
-		return identifiers
 	}
 
-	p.nextToken()
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	stmt.Rbrace = p.curToken
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	return stmt
+}
 
-	for p.peekTokenIs(token.COMMA) {
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	params := &ast.FunctionLiteral{}
+	p.parseFunctionParameters(params)
+	for _, t := range params.ParameterTypes {
+		if t != nil {
+			p.addError(ast.Pos(t), "macro parameters cannot have type annotations")
+		}
+	}
+	for _, d := range params.Defaults {
+		if d != nil {
+			p.addError(ast.Pos(d), "macro parameters cannot have default values")
+		}
+	}
+	if params.Rest {
+		p.addError(lit.Token, "macro parameters cannot be rest parameters")
+	}
+	for _, pattern := range params.Patterns {
+		if pattern != nil {
+			p.addError(ast.Pos(pattern), "macro parameters cannot be patterns")
+		}
+	}
+	lit.Parameters = params.Parameters
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.functions = append(p.functions, nil)
+	lit.Body = p.parseBlockStatement()
+	p.functions = p.functions[:len(p.functions)-1]
+
+	return lit
+}
+
+// parseFunctionParameters fills in the parameters of lit, along with their
+// types, defaults and patterns.
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) {
+	lit.Parameters = []*ast.Identifier{}
+
+	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
+		return
+	}
+
+	for {
 		p.nextToken()
 
+		if lit.Rest {
+			p.addError(p.curToken, "rest parameter must be last")
+		}
+		if p.curTokenIs(token.ELLIPSIS) {
+			lit.Rest = true
+			p.nextToken()
+		}
+
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		if p.curTokenIs(token.LBRACKET) || p.curTokenIs(token.LBRACE) {
+			if lit.Rest {
+				p.addError(p.curToken, "rest parameter must be a name")
+			}
+			pattern := p.parsePattern(false)
+			if pattern == nil {
+				lit.Parameters, lit.ParameterTypes, lit.Defaults, lit.Patterns = nil, nil, nil, nil
+				return
+			}
+			ident.Value = pattern.String()
+			for len(lit.Patterns) < len(lit.Parameters) {
+				lit.Patterns = append(lit.Patterns, nil)
+			}
+			lit.Patterns = append(lit.Patterns, pattern)
+		}
+		lit.Parameters = append(lit.Parameters, ident)
+
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			t := p.parseTypeAnnotation()
+			if t == nil {
+				lit.Parameters, lit.ParameterTypes, lit.Defaults, lit.Patterns = nil, nil, nil, nil
+				return
+			}
+			for len(lit.ParameterTypes) < len(lit.Parameters)-1 {
+				lit.ParameterTypes = append(lit.ParameterTypes, nil)
+			}
+			lit.ParameterTypes = append(lit.ParameterTypes, t)
+		}
+
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			if lit.Rest {
+				p.addError(p.curToken, "rest parameter cannot have a default value")
+			}
+			p.nextToken()
+			for len(lit.Defaults) < len(lit.Parameters)-1 {
+				lit.Defaults = append(lit.Defaults, nil)
+			}
+			lit.Defaults = append(lit.Defaults, p.parseExpression(LOWEST))
+		} else if len(lit.Defaults) > 0 && !lit.Rest {
+			p.addError(ident.Token, fmt.Sprintf("parameter %s needs a default value because an earlier one has one", ident.Value))
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		lit.Parameters, lit.ParameterTypes, lit.Defaults, lit.Patterns = nil, nil, nil, nil
+		return
+	}
+
+	for lit.ParameterTypes != nil && len(lit.ParameterTypes) < len(lit.Parameters) {
+		lit.ParameterTypes = append(lit.ParameterTypes, nil)
+	}
+	for lit.Defaults != nil && len(lit.Defaults) < len(lit.Parameters) {
+		lit.Defaults = append(lit.Defaults, nil)
+	}
+	for lit.Patterns != nil && len(lit.Patterns) < len(lit.Parameters) {
+		lit.Patterns = append(lit.Patterns, nil)
+	}
+}
+
+// parseTypeAnnotation is called with the colon as the current token.
+func (p *Parser) parseTypeAnnotation() ast.TypeExpression {
+	p.nextToken()
+	return p.parseType()
+}
+
+func (p *Parser) parseType() ast.TypeExpression {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+	case token.LBRACKET:
+		t := &ast.ArrayType{Token: p.curToken}
+		p.nextToken()
+		if t.Element = p.parseType(); t.Element == nil || !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		return t
+	case token.LBRACE:
+		t := &ast.HashType{Token: p.curToken}
+		p.nextToken()
+		if t.Key = p.parseType(); t.Key == nil || !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		if t.Value = p.parseType(); t.Value == nil || !p.expectPeek(token.RBRACE) {
+			return nil
+		}
+		return t
+	case token.FUNCTION:
+		return p.parseFunctionType()
+	default:
+		p.addError(p.curToken, fmt.Sprintf("expected a type, got %s instead", p.curToken.Type))
+		return nil
+	}
+}
+
+func (p *Parser) parseFunctionType() ast.TypeExpression {
+	t := &ast.FunctionType{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	t.Parameters = []ast.TypeExpression{}
+	for !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		param := p.parseType()
+		if param == nil {
+			return nil
+		}
+		t.Parameters = append(t.Parameters, param)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
 	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
 
-	return identifiers
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		if t.Return = p.parseTypeAnnotation(); t.Return == nil {
+			return nil
+		}
+	}
+
+	return t
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Rparen = p.curToken
 	return exp
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
-	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	lit := p.curToken.Literal
+	if strings.Contains(strings.ReplaceAll(lit, "$${", ""), "${") {
+		return p.parseInterpolatedString()
+	}
+
+	return &ast.StringLiteral{Token: p.curToken, Value: strings.ReplaceAll(lit, "$${", "${")}
+}
+
+// parseInterpolatedString splits a string literal into its text and the
+// expressions in ${}, each of which is parsed on its own at its position in
+// the source.
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	tok := p.curToken
+	lit := tok.Literal
+	str := &ast.InterpolatedString{Token: tok}
+
+	var text strings.Builder
+	textStart := 0
+	flush := func(end int) {
+		if text.Len() > 0 {
+			line, column := offset(tok, lit[:textStart])
+			textTok := token.Token{Type: token.STRING, Literal: lit[textStart:end], Line: line, Column: column}
+			str.Parts = append(str.Parts, &ast.StringLiteral{Token: textTok, Value: text.String()})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(lit); {
+		switch {
+		case strings.HasPrefix(lit[i:], "$${"):
+			text.WriteString("${")
+			i += 3
+		case strings.HasPrefix(lit[i:], "${"):
+			flush(i)
+
+			start := i + 2
+			end := lexer.InterpolationEnd(lit, start)
+			line, column := offset(tok, lit[:start])
+			if end == len(lit) {
+				p.errors = append(p.errors, Error{Line: line, Column: column - 2, Message: "unterminated ${ in string"})
+				return nil
+			}
+			if strings.TrimSpace(lit[start:end]) == "" {
+				p.errors = append(p.errors, Error{Line: line, Column: column - 2, Message: "empty ${} in string"})
+				return nil
+			}
+
+			exp := p.parseInterpolation(lit[start:end], line, column)
+			if exp == nil {
+				return nil
+			}
+			str.Parts = append(str.Parts, exp)
+
+			i = end + 1
+			textStart = i
+		default:
+			text.WriteByte(lit[i])
+			i++
+		}
+	}
+	flush(len(lit))
+
+	return str
+}
+
+func (p *Parser) parseInterpolation(src string, line, column int) ast.Expression {
+	inner := New(lexer.NewAt(src, line, column))
+	exp := inner.parseExpression(LOWEST)
+	if len(inner.errors) == 0 && !inner.peekTokenIs(token.EOF) {
+		inner.addError(inner.peekToken, fmt.Sprintf("unexpected %s in ${}", inner.peekToken.Type))
+	}
+
+	if len(inner.errors) > 0 {
+		p.errors = append(p.errors, inner.errors...)
+		return nil
+	}
+	return exp
+}
+
+// offset returns the line and column just after prefix, a prefix of the
+// contents of the string literal tok.
+func offset(tok token.Token, prefix string) (int, int) {
+	line, column := tok.Line, tok.Column+1
+	for i := 0; i < len(prefix); i++ {
+		if prefix[i] == '\n' {
+			line, column = line+1, 1
+		} else {
+			column++
+		}
+	}
+	return line, column
 }
 
 func (p *Parser) parseArrayLiteral() ast.Expression {
@@ -414,12 +1144,12 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	}
 
 	p.nextToken()
-	list = append(list, p.parseExpression(LOWEST))
+	list = append(list, p.parseListElement())
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		list = append(list, p.parseExpression(LOWEST))
+		list = append(list, p.parseListElement())
 	}
 
 	if !p.expectPeek(end) {
@@ -429,6 +1159,19 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	return list
 }
 
+// parseListElement parses an argument of a call or an element of an array
+// literal, either of which can be spread.
+func (p *Parser) parseListElement() ast.Expression {
+	if !p.curTokenIs(token.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
+
+	spread := &ast.SpreadExpression{Token: p.curToken}
+	p.nextToken()
+	spread.Value = p.parseExpression(LOWEST)
+	return spread
+}
+
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
 
@@ -442,6 +1185,61 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: left}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+func (p *Parser) parseWithExpression(left ast.Expression) ast.Expression {
+	exp := &ast.WithExpression{Token: p.curToken, Left: left}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	set := make(map[string]bool)
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		field := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if set[field.Value] {
+			p.addError(field.Token, fmt.Sprintf("field %s is set more than once", field.Value))
+		}
+		set[field.Value] = true
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+
+		value := p.parseExpression(LOWEST)
+		if value == nil {
+			return nil
+		}
+		exp.Fields = append(exp.Fields, field)
+		exp.Values = append(exp.Values, value)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return exp
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
@@ -458,6 +1256,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		value := p.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"goscript/ast"
 	"goscript/lexer"
+	"goscript/token"
+	"strings"
 	"testing"
 )
 
@@ -25,6 +27,78 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestInterpolatedString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"Hello ${name}!"`, "Hello ${name}!"},
+		{`"${a + b * 2}${f(x)}"`, "${(a + (b * 2))}${f(x)}"},
+		{`"a ${ "b ${c}" } d"`, "a ${b ${c}} d"},
+		{`"$${x} ${x}"`, "${x} ${x}"},
+		{`"x" + "${y}"`, "(x + ${y})"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := New(lexer.New(tt.input))
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if got := program.String(); got != tt.expected {
+				t.Errorf("wrong program. want=%q, got=%q", tt.expected, got)
+			}
+		})
+	}
+
+	p := New(lexer.New(`"$${x}"`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	literal, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.StringLiteral)
+	if !ok || literal.Value != "${x}" {
+		t.Errorf("escaped interpolation is not a plain string. got=%#v", program.Statements[0])
+	}
+
+	p = New(lexer.New("let s = \"ab\n c ${x} ${y}\";"))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	str := program.Statements[0].(*ast.LetStatement).Value.(*ast.InterpolatedString)
+	for i, want := range []string{"2:6", "2:8", "2:11"} {
+		pos := ast.Pos(str.Parts[i+1])
+		if got := fmt.Sprintf("%d:%d", pos.Line, pos.Column); got != want {
+			t.Errorf("wrong position of part %d. want=%s, got=%s", i+1, want, got)
+		}
+	}
+}
+
+func TestInterpolationErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let s = "a ${}";`, "1:12: empty ${} in string"},
+		{`let s = "a ${b c}";`, "1:16: unexpected IDENT in ${}"},
+		{`let s = "a ${b +}";`, "1:17: no prefix parse function for EOF found"},
+		{`let s = "a ${b`, "1:12: unterminated ${ in string"},
+		{`match (s) { "${s}" => 1 }`, "1:13: cannot interpolate into a pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := New(lexer.New(tt.input))
+			p.ParseProgram()
+
+			errs := p.ErrorList()
+			if len(errs) == 0 {
+				t.Fatalf("expected parser errors")
+			}
+			if errs[0].Error() != tt.expected {
+				t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errs[0].Error())
+			}
+		})
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	input := `
 let x = 5;
@@ -624,6 +698,310 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		testName string
+		input    string
+		expected string
+		rest     bool
+	}{
+		{"default", "fn(x, y = 10) {}", "fn(x, y = 10)", false},
+		{"typed default", "fn(x: int = 1 + 2) {}", "fn(x: int = (1 + 2))", false},
+		{"rest", "fn(first, ...others) {}", "fn(first, ...others)", true},
+		{"default and rest", "fn(a, b = a, ...c: [int]) {}", "fn(a, b = a, ...c: [int])", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			p := New(lexer.New(tt.input))
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+			if function.Rest != tt.rest {
+				t.Errorf("wrong rest. want=%t, got=%t", tt.rest, function.Rest)
+			}
+			if got := function.String(); got != tt.expected {
+				t.Errorf("wrong function. want=%q, got=%q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParameterErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(...a, b) {}", "1:10: rest parameter must be last"},
+		{"fn(...a = 1) {}", "1:9: rest parameter cannot have a default value"},
+		{"fn(a = 1, b) {}", "1:11: parameter b needs a default value because an earlier one has one"},
+		{"macro(a = 1) { a }", "1:11: macro parameters cannot have default values"},
+		{"macro(...a) { a }", "1:1: macro parameters cannot be rest parameters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := New(lexer.New(tt.input))
+			p.ParseProgram()
+
+			errs := p.ErrorList()
+			if len(errs) == 0 {
+				t.Fatalf("expected parser errors")
+			}
+			if errs[0].Error() != tt.expected {
+				t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errs[0].Error())
+			}
+		})
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		testName string
+		input    string
+		expected string
+		names    []string
+	}{
+		{"array", "let [a, b] = xs;", "let [a, b] = xs;", []string{"a", "b"}},
+		{"rest", "let [head, ...tail] = xs;", "let [head, ...tail] = xs;", []string{"head", "tail"}},
+		{"hash", "let {name, age: years} = h;", "let {name, age: years} = h;", []string{"name", "years"}},
+		{"nested", "let [{x}, [y, ...z]] = v;", "let [{x}, [y, ...z]] = v;", []string{"x", "y", "z"}},
+		{"parameters", "let f = fn([a, b], {c}, d) {};", "let f = fn([a, b], {c}, d);", []string{"f"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			p := New(lexer.New(tt.input))
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if got := program.String(); got != tt.expected {
+				t.Errorf("wrong program. want=%q, got=%q", tt.expected, got)
+			}
+
+			names := []string{}
+			for _, name := range program.Statements[0].(*ast.LetStatement).Names() {
+				names = append(names, name.Value)
+			}
+			if strings.Join(names, " ") != strings.Join(tt.names, " ") {
+				t.Errorf("wrong names. want=%v, got=%v", tt.names, names)
+			}
+		})
+	}
+}
+
+func TestDestructuringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, 1] = xs;", "1:9: expected a name or a pattern, got INT instead"},
+		{"let [...a, b] = xs;", "1:10: expected next token to be ], got , instead"},
+		{"export let [a] = xs;", "1:12: cannot export a destructuring let"},
+		{"fn(...[a]) {}", "1:7: rest parameter must be a name"},
+		{"macro([a]) { a }", "1:7: macro parameters cannot be patterns"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := New(lexer.New(tt.input))
+			p.ParseProgram()
+
+			errs := p.ErrorList()
+			if len(errs) == 0 {
+				t.Fatalf("expected parser errors")
+			}
+			if errs[0].Error() != tt.expected {
+				t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errs[0].Error())
+			}
+		})
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match (x) {
+	0 => "zero",
+	-1 => "minus one",
+	n: int if n > 0 => n,
+	string => "string",
+	[first, ..._] => first,
+	{kind: "circle", radius} => radius,
+	f: fn => f,
+	p: Point => p,
+	_ => null,
+}`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	me, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("expression is not *ast.MatchExpression. got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	if err := testIdentifier(t, me.Value, "x"); err != nil {
+		t.Errorf("[ERROR] %v", err)
+	}
+
+	expected := []string{
+		"0 => zero",
+		"(-1) => minus one",
+		"n: int if (n > 0) => n",
+		"string => string",
+		"[first, ..._] => first",
+		"{kind: circle, radius} => radius",
+		"f: fn => f",
+		"p: Point => p",
+		"_ => null",
+	}
+	if len(me.Arms) != len(expected) {
+		t.Fatalf("wrong number of arms. want=%d, got=%d", len(expected), len(me.Arms))
+	}
+	for i, arm := range me.Arms {
+		if arm.String() != expected[i] {
+			t.Errorf("wrong arm %d. want=%q, got=%q", i, expected[i], arm.String())
+		}
+	}
+
+	names := []string{}
+	for _, name := range ast.PatternNames(me.Arms[4].Pattern) {
+		names = append(names, name.Value)
+	}
+	if strings.Join(names, " ") != "first" {
+		t.Errorf("wrong pattern names. got=%v", names)
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { 1 + 2 => 3 }", "1:15: expected next token to be =>, got + instead"},
+		{"match (x) { (a) => a }", "1:13: expected a pattern, got ( instead"},
+		{"match (x) { n: 1 => n }", "1:16: expected next token to be IDENT, got INT instead"},
+		{"match (x) { a => 1 b => 2 }", "1:20: expected next token to be }, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := New(lexer.New(tt.input))
+			p.ParseProgram()
+
+			errs := p.ErrorList()
+			if len(errs) == 0 {
+				t.Fatalf("expected parser errors")
+			}
+			if errs[0].Error() != tt.expected {
+				t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errs[0].Error())
+			}
+		})
+	}
+}
+
+func TestStructStatement(t *testing.T) {
+	input := `struct Point { x, y, fn norm(k) { self.x * k }, }`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.StructStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.StructStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Name.Value != "Point" {
+		t.Errorf("wrong name. got=%s", stmt.Name.Value)
+	}
+	if len(stmt.Fields) != 2 || stmt.Fields[0].Value != "x" || stmt.Fields[1].Value != "y" {
+		t.Errorf("wrong fields. got=%v", stmt.Fields)
+	}
+	if len(stmt.Methods) != 1 {
+		t.Fatalf("wrong number of methods. got=%d", len(stmt.Methods))
+	}
+	if stmt.Methods[0].Name != "norm" || stmt.Methods[0].Token.Type != token.FUNCTION {
+		t.Errorf("wrong method. got=%s at %s", stmt.Methods[0].Name, stmt.Methods[0].Token.Literal)
+	}
+}
+
+func TestWithExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"p with {x: 1}", "(p with {x: 1})"},
+		{"p with {x: 1, y: a + b}.x", "((p with {x: 1, y: (a + b)}).x)"},
+		{"f(p) with {} with {y: 2}", "((f(p) with {}) with {y: 2})"},
+		{"a + p with {x: 1}", "(a + (p with {x: 1}))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := New(lexer.New(tt.input))
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if got := program.String(); got != tt.expected {
+				t.Errorf("wrong program. want=%q, got=%q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestStructErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct { x }", "1:8: expected next token to be IDENT, got { instead"},
+		{"struct P { x, x }", "1:15: duplicate member x of struct P"},
+		{"struct P { x, fn x() {} }", "1:18: duplicate member x of struct P"},
+		{"struct P { 1 }", "1:12: expected a field or a method, got INT instead"},
+		{"p with {x: 1, x: 2}", "1:15: field x is set more than once"},
+		{"export 1;", "1:8: expected let or struct after export, got INT instead"},
+		{"export struct { x }", "1:15: expected next token to be IDENT, got { instead"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := New(lexer.New(tt.input))
+			p.ParseProgram()
+
+			errs := p.ErrorList()
+			if len(errs) == 0 {
+				t.Fatalf("expected parser errors")
+			}
+			if errs[0].Error() != tt.expected {
+				t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errs[0].Error())
+			}
+		})
+	}
+}
+
+func TestSpreadArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"f(...xs)", "f(...xs)"},
+		{"f(a, ...g(b) + c, d)", "f(a, ...(g(b) + c), d)"},
+		{"[1, ...xs]", "[1, ...xs]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := New(lexer.New(tt.input))
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if got := program.String(); got != tt.expected {
+				t.Errorf("wrong program. want=%q, got=%q", tt.expected, got)
+			}
+		})
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -807,6 +1185,21 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"-lib.a * lib.f(b).c[0]",
+			"-lib.a * lib.f(b).c[0]",
+			"((-(lib.a)) * (((lib.f)(b).c)[0]))",
+		},
+		{
+			"spawn f(a, b).c",
+			"spawn f(a, b).c",
+			"(spawn (f(a, b).c))",
+		},
+		{
+			"await(spawn fn() { x }) + 1",
+			"await(spawn fn() { x }) + 1",
+			"(await((spawn fn()x)) + 1)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -1087,3 +1480,382 @@ func TestParsingHashLiteralsIntegerKeys(t *testing.T) {
 		}
 	}
 }
+
+func TestImportStatements(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedName string
+		expectedPath string
+	}{
+		{`import "lib/util";`, "", "lib/util"},
+		{`import u from "./lib/util"`, "u", "./lib/util"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Statements) != 1 {
+				t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+			}
+
+			stmt, ok := program.Statements[0].(*ast.ImportStatement)
+			if !ok {
+				t.Fatalf("program.Statements[0] is not ast.ImportStatement. got=%T", program.Statements[0])
+			}
+
+			if tt.expectedName == "" && stmt.Name != nil {
+				t.Errorf("stmt.Name is not nil. got=%q", stmt.Name.Value)
+			}
+			if tt.expectedName != "" {
+				if err := testIdentifier(t, stmt.Name, tt.expectedName); err != nil {
+					t.Errorf("[ERROR] %v", err)
+				}
+			}
+			if stmt.Path.Value != tt.expectedPath {
+				t.Errorf("stmt.Path.Value not %q. got=%q", tt.expectedPath, stmt.Path.Value)
+			}
+		})
+	}
+}
+
+func TestExportStatement(t *testing.T) {
+	input := `export let x = lib.value;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExportStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExportStatement. got=%T", program.Statements[0])
+	}
+
+	if err := testLetStatement(t, stmt.Statement, "x"); err != nil {
+		t.Fatalf("[ERROR] %v", err)
+	}
+
+	value := stmt.Statement.(*ast.LetStatement).Value
+	member, ok := value.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("value is not ast.MemberExpression. got=%T", value)
+	}
+
+	if err := testIdentifier(t, member.Object, "lib"); err != nil {
+		t.Errorf("[ERROR] %v", err)
+	}
+	if err := testIdentifier(t, member.Property, "value"); err != nil {
+		t.Errorf("[ERROR] %v", err)
+	}
+
+	if program.String() != "export let x = (lib.value);" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestExportStruct(t *testing.T) {
+	p := New(lexer.New(`export struct Point { x, y }`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExportStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExportStatement. got=%T", program.Statements[0])
+	}
+	if _, ok := stmt.Statement.(*ast.StructStatement); !ok {
+		t.Fatalf("exported statement is not ast.StructStatement. got=%T", stmt.Statement)
+	}
+	if stmt.Name().Value != "Point" {
+		t.Errorf("wrong exported name. got=%q", stmt.Name().Value)
+	}
+}
+
+func TestImportExportOnlyAtTopLevel(t *testing.T) {
+	tests := []string{
+		`fn() { import "lib"; }`,
+		`if (true) { export let x = 1; }`,
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			p := New(lexer.New(input))
+			p.ParseProgram()
+
+			if len(p.Errors()) == 0 {
+				t.Errorf("expected parser errors for %q", input)
+			}
+		})
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading
+let x = 5; // trailing
+let f = fn() {
+	// inside
+	x
+};`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	expected := []struct {
+		text string
+		line int
+	}{
+		{"// leading", 1},
+		{"// trailing", 2},
+		{"// inside", 4},
+	}
+
+	if len(program.Comments) != len(expected) {
+		t.Fatalf("program.Comments has wrong length. got=%d", len(program.Comments))
+	}
+
+	for i, tt := range expected {
+		c := program.Comments[i]
+		if c.Text != tt.text || c.Token.Line != tt.line {
+			t.Errorf("comment %d wrong. expected=%q at line %d, got=%q at line %d", i, tt.text, tt.line, c.Text, c.Token.Line)
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	input := "let x = 5;\nlet = 10;"
+
+	p := New(lexer.New(input))
+	p.ParseProgram()
+
+	errs := p.ErrorList()
+	if len(errs) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+
+	if errs[0].Line != 2 || errs[0].Column != 5 {
+		t.Errorf("wrong error position. expected=2:5, got=%d:%d", errs[0].Line, errs[0].Column)
+	}
+
+	expected := "2:5: expected next token to be IDENT, got = instead"
+	if errs[0].Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, errs[0].Error())
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d", len(macro.Parameters))
+	}
+
+	if err := testLiteralExpression(t, macro.Parameters[0], "x"); err != nil {
+		t.Errorf("[Error] %v", err)
+	}
+	if err := testLiteralExpression(t, macro.Parameters[1], "y"); err != nil {
+		t.Errorf("[Error] %v", err)
+	}
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d", len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T", macro.Body.Statements[0])
+	}
+
+	if err := testInfixExpression(t, bodyStmt.Expression, "x", "+", "y"); err != nil {
+		t.Errorf("[Error] %v", err)
+	}
+}
+
+func TestFunctionLiteralWithName(t *testing.T) {
+	input := `let myFunction = fn() { }; let other = fn() { fn() {} };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d", 2, len(program.Statements))
+	}
+
+	expected := []string{"myFunction", "other"}
+	for i, name := range expected {
+		stmt, ok := program.Statements[i].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("program.Statements[%d] is not ast.LetStatement. got=%T", i, program.Statements[i])
+		}
+
+		function, ok := stmt.Value.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("stmt.Value is not ast.FunctionLiteral. got=%T", stmt.Value)
+		}
+
+		if function.Name != name {
+			t.Errorf("function literal name wrong. want %q, got=%q", name, function.Name)
+		}
+	}
+
+	inner := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral).Body.Statements[0]
+	if fn := inner.(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral); fn.Name != "" {
+		t.Errorf("anonymous function should have no name. got=%q", fn.Name)
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		testName string
+		input    string
+		expected string
+	}{
+		{"let", "let x: int = 5;", "let x: int = 5;"},
+		{"array", "let xs: [string] = [];", "let xs: [string] = [];"},
+		{"hash", "let h: {string: [int]} = {};", "let h: {string: [int]} = {};"},
+		{"parameters", "fn(a: int, b, c: bool) { a }", "fn(a: int, b, c: bool)a"},
+		{"return type", "fn(a): bool { a }", "fn(a): boola"},
+		{"function types", "let f: fn(int, fn(): string): [int] = g;", "let f: fn(int, fn(): string): [int] = g;"},
+		{"hash return type", "fn(): {string: int} { x }", "fn(): {string: int}x"},
+		{"no annotations", "fn(a, b) { a }", "fn(a, b)a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if program.String() != tt.expected {
+				t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+			}
+		})
+	}
+
+	program := New(lexer.New("fn(a, b: int, c) { a }")).ParseProgram()
+	fn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(fn.ParameterTypes) != 3 || fn.ParameterTypes[0] != nil || fn.ParameterTypes[1] == nil || fn.ParameterTypes[2] != nil {
+		t.Errorf("parameter types do not line up with parameters. got=%v", fn.ParameterTypes)
+	}
+
+	program = New(lexer.New("fn(a, b) { a }")).ParseProgram()
+	fn = program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if fn.ParameterTypes != nil {
+		t.Errorf("expected no parameter types. got=%v", fn.ParameterTypes)
+	}
+}
+
+func TestTypeAnnotationErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: = 5;", "1:8: expected a type, got = instead"},
+		{"let x: [int = 5;", "1:13: expected next token to be ], got = instead"},
+		{"let x: {int} = 5;", "1:12: expected next token to be :, got } instead"},
+		{"fn(a: 1) { a }", "1:7: expected a type, got INT instead"},
+		{"macro(a: int) { a }", "1:10: macro parameters cannot have type annotations"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := New(lexer.New(tt.input))
+			p.ParseProgram()
+
+			errs := p.ErrorList()
+			if len(errs) == 0 {
+				t.Fatalf("expected parser errors")
+			}
+			if errs[0].Error() != tt.expected {
+				t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errs[0].Error())
+			}
+		})
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	program := New(lexer.New("let f = fn(xs) { for (x in xs) { yield x * 2; } };"))
+	parsed := program.ParseProgram()
+	checkParserErrors(t, program)
+
+	fl := parsed.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if !fl.Generator {
+		t.Errorf("function is not a generator")
+	}
+
+	stmt, ok := fl.Body.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.ForStatement. got=%T", fl.Body.Statements[0])
+	}
+	if stmt.Variable.Value != "x" {
+		t.Errorf("wrong loop variable. got=%s", stmt.Variable.Value)
+	}
+	if err := testIdentifier(t, stmt.Iterable, "xs"); err != nil {
+		t.Errorf("[ERROR] %v", err)
+	}
+
+	yield, ok := stmt.Body.Statements[0].(*ast.YieldStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.YieldStatement. got=%T", stmt.Body.Statements[0])
+	}
+	if yield.String() != "yield (x * 2);" {
+		t.Errorf("wrong yield. got=%q", yield.String())
+	}
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"yield 1;", "1:1: yield is only allowed in a function"},
+		{"macro() { yield 1; }", "1:11: yield is only allowed in a function"},
+		{"fn() { for x in xs {} }", "1:12: expected next token to be (, got IDENT instead"},
+		{"fn() { for (x of xs) {} }", "1:15: expected next token to be IN, got IDENT instead"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := New(lexer.New(tt.input))
+			p.ParseProgram()
+
+			errs := p.ErrorList()
+			if len(errs) == 0 {
+				t.Fatalf("expected parser errors")
+			}
+			if errs[0].Error() != tt.expected {
+				t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errs[0].Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package profile
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// The pprof format is the protocol buffer message described in
+// github.com/google/pprof/proto/profile.proto. Only the fields goscript needs
+// are written, so it is encoded by hand rather than through generated code.
+
+const (
+	profileSampleType    = 1
+	profileSample        = 2
+	profileLocation      = 4
+	profileFunction      = 5
+	profileStringTable   = 6
+	profileTimeNanos     = 9
+	profileDurationNanos = 10
+	profilePeriodType    = 11
+	profilePeriod        = 12
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+type buffer struct {
+	data []byte
+}
+
+func (b *buffer) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *buffer) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.varint(uint64(field)<<3 | 0)
+	b.varint(x)
+}
+
+func (b *buffer) int64(field int, x int64) {
+	b.uint64(field, uint64(x))
+}
+
+func (b *buffer) bytes(field int, data []byte) {
+	b.varint(uint64(field)<<3 | 2)
+	b.varint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
+
+func (b *buffer) message(field int, fn func(*buffer)) {
+	msg := &buffer{}
+	fn(msg)
+	b.bytes(field, msg.data)
+}
+
+func (b *buffer) packed(field int, values []uint64) {
+	msg := &buffer{}
+	for _, v := range values {
+		msg.varint(v)
+	}
+	b.bytes(field, msg.data)
+}
+
+type stringTable struct {
+	strings []string
+	index   map[string]int64
+}
+
+func (t *stringTable) id(s string) int64 {
+	if i, ok := t.index[s]; ok {
+		return i
+	}
+
+	i := int64(len(t.strings))
+	t.strings = append(t.strings, s)
+	t.index[s] = i
+	return i
+}
+
+func (prof *Profile) WritePprof(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+
+	if _, err := gz.Write(prof.encode()); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+func (prof *Profile) encode() []byte {
+	b := &buffer{}
+	strs := &stringTable{index: make(map[string]int64)}
+	strs.id("")
+
+	valueType := func(field int, typ, unit string) {
+		b.message(field, func(m *buffer) {
+			m.int64(valueTypeType, strs.id(typ))
+			m.int64(valueTypeUnit, strs.id(unit))
+		})
+	}
+
+	valueType(profileSampleType, "calls", "count")
+	valueType(profileSampleType, "time", "nanoseconds")
+
+	functionIDs := make(map[*Function]uint64)
+	functions := []*Function{}
+	locationIDs := make(map[*Location]uint64)
+	locations := []*Location{}
+
+	for _, s := range prof.Samples {
+		ids := make([]uint64, len(s.Stack))
+
+		for i, loc := range s.Stack {
+			if _, ok := locationIDs[loc]; !ok {
+				locationIDs[loc] = uint64(len(locations) + 1)
+				locations = append(locations, loc)
+			}
+			if _, ok := functionIDs[loc.Function]; !ok {
+				functionIDs[loc.Function] = uint64(len(functions) + 1)
+				functions = append(functions, loc.Function)
+			}
+			ids[i] = locationIDs[loc]
+		}
+
+		b.message(profileSample, func(m *buffer) {
+			m.packed(sampleLocationID, ids)
+			m.packed(sampleValue, []uint64{uint64(s.Calls), uint64(s.Time.Nanoseconds())})
+		})
+	}
+
+	for _, loc := range locations {
+		b.message(profileLocation, func(m *buffer) {
+			m.uint64(locationID, locationIDs[loc])
+			m.message(locationLine, func(l *buffer) {
+				l.uint64(lineFunctionID, functionIDs[loc.Function])
+				l.int64(lineLine, int64(loc.Line))
+			})
+		})
+	}
+
+	for _, fn := range functions {
+		b.message(profileFunction, func(m *buffer) {
+			m.uint64(functionID, functionIDs[fn])
+			m.int64(functionName, strs.id(fn.Name))
+			m.int64(functionSystemName, strs.id(fn.Name))
+			m.int64(functionFilename, strs.id(fn.File))
+			m.int64(functionStartLine, int64(fn.StartLine))
+		})
+	}
+
+	b.int64(profileTimeNanos, prof.Start.UnixNano())
+	b.int64(profileDurationNanos, prof.Duration.Nanoseconds())
+	valueType(profilePeriodType, "time", "nanoseconds")
+	b.int64(profilePeriod, 1)
+
+	for _, s := range strs.strings {
+		b.bytes(profileStringTable, []byte(s))
+	}
+
+	return b.data
+}
@@ -0,0 +1,323 @@
+package profile
+
+import (
+	"bytes"
+	"goscript/ast"
+	"goscript/evaluator"
+	"goscript/object"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Function struct {
+	Name      string
+	File      string
+	StartLine int
+}
+
+type Location struct {
+	Function *Function
+	Line     int
+}
+
+type Sample struct {
+	Stack []*Location
+	Calls int64
+	Time  time.Duration
+}
+
+type Profile struct {
+	Start    time.Time
+	Duration time.Duration
+	Samples  []*Sample
+}
+
+type frame struct {
+	fn   *Function
+	line int
+}
+
+// A thread is the stack of a goroutine the program runs in, which is its main
+// one or that of a task or generator. Each is charged the time since its own
+// last event, so tasks run at the same time are not charged for each other.
+type thread struct {
+	stack []*frame
+	last  time.Time
+
+	// name is the root frame of a generator, pushed when its body is entered.
+	name string
+}
+
+type Profiler struct {
+	mu sync.Mutex
+
+	now func() time.Time
+
+	start   time.Time
+	last    time.Time
+	threads map[uint64]*thread
+
+	files     map[*ast.BlockStatement]string
+	functions map[Function]*Function
+	locations map[Location]*Location
+	ids       map[*Location]int
+	samples   map[string]*Sample
+	order     []string
+}
+
+func New() *Profiler {
+	return &Profiler{
+		now:       time.Now,
+		threads:   make(map[uint64]*thread),
+		files:     make(map[*ast.BlockStatement]string),
+		functions: make(map[Function]*Function),
+		locations: make(map[Location]*Location),
+		ids:       make(map[*Location]int),
+		samples:   make(map[string]*Sample),
+	}
+}
+
+func (p *Profiler) Start() {
+	p.start = p.now()
+	p.last = p.start
+	evaluator.SetTracer(p)
+}
+
+func (p *Profiler) Stop() *Profile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	evaluator.SetTracer(nil)
+	p.charge()
+
+	prof := &Profile{Start: p.start, Duration: p.last.Sub(p.start)}
+	for _, key := range p.order {
+		prof.Samples = append(prof.Samples, p.samples[key])
+	}
+
+	return prof
+}
+
+// RunTask gives the body of a task or generator a stack of its own. A task
+// calls its function, which is its root frame, while the body of a generator
+// is run without a call, so its frame is pushed when the body is entered.
+func (p *Profiler) RunTask(name string, body func() object.Object) object.Object {
+	id := goid()
+	p.mu.Lock()
+	p.threads[id] = &thread{last: p.last, name: name}
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.charge()
+		delete(p.threads, id)
+		p.mu.Unlock()
+	}()
+
+	return body()
+}
+
+// goid returns the id of the calling goroutine, which Go only exposes in
+// stack traces.
+func goid() uint64 {
+	buf := make([]byte, 64)
+	buf = bytes.TrimPrefix(buf[:runtime.Stack(buf, false)], []byte("goroutine "))
+	id, _ := strconv.ParseUint(string(buf[:bytes.IndexByte(buf, ' ')]), 10, 64)
+	return id
+}
+
+// thread returns the thread of the calling goroutine, which for the main one
+// is made on its first event.
+func (p *Profiler) thread() *thread {
+	id := goid()
+	th, ok := p.threads[id]
+	if !ok {
+		th = &thread{last: p.last}
+		p.threads[id] = th
+	}
+	return th
+}
+
+func (p *Profiler) function(name, file string, line int) *Function {
+	key := Function{Name: name, File: file, StartLine: line}
+	if fn, ok := p.functions[key]; ok {
+		return fn
+	}
+
+	fn := &key
+	p.functions[key] = fn
+	return fn
+}
+
+func (p *Profiler) location(fn *Function, line int) *Location {
+	key := Location{Function: fn, Line: line}
+	if loc, ok := p.locations[key]; ok {
+		return loc
+	}
+
+	loc := &key
+	p.locations[key] = loc
+	p.ids[loc] = len(p.ids) + 1
+	return loc
+}
+
+func (p *Profiler) sample(frames []*frame) *Sample {
+	stack := make([]*Location, 0, len(frames))
+	ids := make([]string, 0, len(frames))
+
+	for i := len(frames) - 1; i >= 0; i-- {
+		loc := p.location(frames[i].fn, frames[i].line)
+		stack = append(stack, loc)
+		ids = append(ids, strconv.Itoa(p.ids[loc]))
+	}
+
+	key := strings.Join(ids, ",")
+	if s, ok := p.samples[key]; ok {
+		return s
+	}
+
+	s := &Sample{Stack: stack}
+	p.samples[key] = s
+	p.order = append(p.order, key)
+	return s
+}
+
+// charge charges the time since the last event of the calling goroutine to
+// its stack and returns its thread.
+func (p *Profiler) charge() *thread {
+	th := p.thread()
+	now := p.now()
+	if len(th.stack) > 0 {
+		p.sample(th.stack).Time += now.Sub(th.last)
+	}
+	th.last = now
+	p.last = now
+	return th
+}
+
+func (p *Profiler) Node(node ast.Node, env *object.Environment) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := node.(ast.Statement); !ok {
+		return
+	}
+	th := p.thread()
+	if block, ok := node.(*ast.BlockStatement); ok {
+		if len(th.stack) == 0 && th.name != "" {
+			p.charge()
+			p.push(th, th.name, block)
+			th.name = ""
+		}
+		return
+	}
+	if len(th.stack) == 0 {
+		return
+	}
+
+	p.charge()
+	th.stack[len(th.stack)-1].line = ast.Pos(node).Line
+}
+
+func (p *Profiler) push(th *thread, name string, body *ast.BlockStatement) {
+	if name == "" {
+		name = "fn"
+	}
+
+	line := ast.Pos(body).Line
+	f := p.function(name, p.files[body], line)
+	th.stack = append(th.stack, &frame{fn: f, line: line})
+}
+
+func (p *Profiler) EnterModule(path string, program *ast.Program) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		if fl, ok := node.(*ast.FunctionLiteral); ok {
+			p.files[fl.Body] = path
+		}
+		return true
+	})
+
+	th := p.charge()
+
+	name := ast.ModuleName(path)
+	th.stack = append(th.stack, &frame{fn: p.function(name, path, 1), line: 1})
+}
+
+func (p *Profiler) ExitModule(path string, result object.Object) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	th := p.charge()
+	th.stack = th.stack[:len(th.stack)-1]
+}
+
+func (p *Profiler) EnterFunction(fn *object.Function, call *ast.CallExpression) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	th := p.charge()
+	p.push(th, fn.Name, fn.Body)
+	p.sample(th.stack).Calls++
+}
+
+func (p *Profiler) ExitFunction(fn *object.Function, result object.Object) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	th := p.charge()
+	th.stack = th.stack[:len(th.stack)-1]
+}
+
+type FunctionStats struct {
+	Function *Function
+	Calls    int64
+	Flat     time.Duration
+	Cum      time.Duration
+}
+
+func (prof *Profile) Functions() []FunctionStats {
+	stats := make(map[*Function]*FunctionStats)
+	get := func(fn *Function) *FunctionStats {
+		if s, ok := stats[fn]; ok {
+			return s
+		}
+		s := &FunctionStats{Function: fn}
+		stats[fn] = s
+		return s
+	}
+
+	for _, s := range prof.Samples {
+		leaf := get(s.Stack[0].Function)
+		leaf.Calls += s.Calls
+		leaf.Flat += s.Time
+
+		seen := make(map[*Function]bool)
+		for _, loc := range s.Stack {
+			if !seen[loc.Function] {
+				seen[loc.Function] = true
+				get(loc.Function).Cum += s.Time
+			}
+		}
+	}
+
+	result := make([]FunctionStats, 0, len(stats))
+	for _, s := range stats {
+		result = append(result, *s)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Flat != result[j].Flat {
+			return result[i].Flat > result[j].Flat
+		}
+		return result[i].Function.Name < result[j].Function.Name
+	})
+
+	return result
+}
@@ -0,0 +1,296 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"goscript/evaluator"
+	"goscript/object"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func profileScript(t *testing.T, dir string) *Profile {
+	t.Helper()
+
+	evaluator.SetImporter(evaluator.NewImporter(nil))
+	evaluator.SetHost(&evaluator.Host{Stdout: io.Discard})
+	defer evaluator.SetHost(evaluator.DefaultHost())
+
+	clock := time.Unix(0, 0)
+	p := New()
+	p.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+
+	p.Start()
+	result := evaluator.EvalFile(filepath.Join(dir, "main.gs"), object.NewEnvironment())
+	prof := p.Stop()
+
+	if errObj, ok := result.(*object.Error); ok {
+		t.Fatalf("script failed: %s", errObj.Message)
+	}
+	return prof
+}
+
+func stackString(s *Sample) string {
+	frames := []string{}
+	for _, loc := range s.Stack {
+		frames = append(frames, filepath.Base(loc.Function.File)+":"+loc.Function.Name+":"+strconv.Itoa(loc.Line))
+	}
+	return strings.Join(frames, " <- ")
+}
+
+func TestProfile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"lib.gs": "export let double = fn(x) {\n\tx * 2\n};\n",
+		"main.gs": `import "lib";
+let twice = fn(x) {
+	let y = lib.double(x);
+	lib.double(y)
+};
+puts(twice(1));
+puts(twice(2));
+`,
+	})
+
+	prof := profileScript(t, dir)
+
+	expected := map[string]int64{
+		"main.gs:main:1":                                       0,
+		"lib.gs:lib:1 <- main.gs:main:1":                       0,
+		"main.gs:main:2":                                       0,
+		"main.gs:main:6":                                       0,
+		"main.gs:twice:2 <- main.gs:main:6":                    1,
+		"main.gs:twice:3 <- main.gs:main:6":                    0,
+		"lib.gs:double:1 <- main.gs:twice:3 <- main.gs:main:6": 1,
+		"lib.gs:double:2 <- main.gs:twice:3 <- main.gs:main:6": 0,
+		"main.gs:twice:4 <- main.gs:main:6":                    0,
+		"lib.gs:double:1 <- main.gs:twice:4 <- main.gs:main:6": 1,
+		"lib.gs:double:2 <- main.gs:twice:4 <- main.gs:main:6": 0,
+		"main.gs:main:7":                                       0,
+		"main.gs:twice:2 <- main.gs:main:7":                    1,
+		"main.gs:twice:3 <- main.gs:main:7":                    0,
+		"lib.gs:double:1 <- main.gs:twice:3 <- main.gs:main:7": 1,
+		"lib.gs:double:2 <- main.gs:twice:3 <- main.gs:main:7": 0,
+		"main.gs:twice:4 <- main.gs:main:7":                    0,
+		"lib.gs:double:1 <- main.gs:twice:4 <- main.gs:main:7": 1,
+		"lib.gs:double:2 <- main.gs:twice:4 <- main.gs:main:7": 0,
+	}
+
+	got := make(map[string]int64)
+	var total time.Duration
+	for _, s := range prof.Samples {
+		got[stackString(s)] = s.Calls
+		total += s.Time
+		if s.Time <= 0 {
+			t.Errorf("%s: expected positive time, got=%v", stackString(s), s.Time)
+		}
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong samples.\nwant=%v\ngot= %v", expected, got)
+	}
+	// the ticks before entering and after leaving main are not charged to any stack
+	if total != prof.Duration-2*time.Millisecond {
+		t.Errorf("sample time does not add up. total=%v, duration=%v", total, prof.Duration)
+	}
+
+	stats := make(map[string]FunctionStats)
+	for _, fs := range prof.Functions() {
+		stats[fs.Function.Name] = fs
+	}
+
+	if stats["double"].Calls != 4 || stats["twice"].Calls != 2 {
+		t.Errorf("wrong call counts. double=%d, twice=%d", stats["double"].Calls, stats["twice"].Calls)
+	}
+	if stats["twice"].Cum <= stats["twice"].Flat || stats["twice"].Cum < stats["double"].Cum+stats["twice"].Flat {
+		t.Errorf("wrong time for twice. flat=%v, cum=%v", stats["twice"].Flat, stats["twice"].Cum)
+	}
+}
+
+func TestProfileTasks(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.gs": `let ch = channel();
+let work = fn(n) {
+	send(ch, n);
+	n * 2
+};
+let a = spawn work(1);
+let b = spawn work(2);
+puts(recv(ch) + recv(ch));
+puts(await(a) + await(b));
+`,
+	})
+
+	prof := profileScript(t, dir)
+
+	calls := int64(0)
+	for _, s := range prof.Samples {
+		stack := stackString(s)
+		if !strings.Contains(stack, "work") {
+			continue
+		}
+		// each task runs work on a stack of its own, not on main's or the other's
+		if len(s.Stack) != 1 {
+			t.Errorf("task sample is not rooted at work: %s", stack)
+		}
+		calls += s.Calls
+	}
+	if calls != 2 {
+		t.Errorf("wrong call count for work. got=%d", calls)
+	}
+}
+
+func TestProfileGenerator(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.gs": `let gen = fn(n) {
+	for (i in range(n)) {
+		yield i * 2;
+	}
+};
+let consume = fn(it) {
+	len(collect(it))
+};
+puts(consume(gen(3)));
+`,
+	})
+
+	prof := profileScript(t, dir)
+
+	got := make(map[string]int64)
+	for _, s := range prof.Samples {
+		got[stackString(s)] = s.Calls
+	}
+
+	// the body of gen runs on a stack of its own rather than consume's
+	for stack, calls := range map[string]int64{
+		"main.gs:gen:1 <- main.gs:main:9": 1,
+		"main.gs:gen:2":                   0,
+		"main.gs:gen:3":                   0,
+	} {
+		if c, ok := got[stack]; !ok || c != calls {
+			t.Errorf("missing sample %s with %d calls. got=%v", stack, calls, got)
+		}
+	}
+	for stack := range got {
+		if strings.Contains(stack, "gen:2") && stack != "main.gs:gen:2" || strings.Contains(stack, "gen:3") && stack != "main.gs:gen:3" {
+			t.Errorf("generator line charged to its caller: %s", stack)
+		}
+	}
+}
+
+type field struct {
+	num   int
+	value uint64
+	data  []byte
+}
+
+func decodeFields(t *testing.T, data []byte) []field {
+	t.Helper()
+
+	fields := []field{}
+	for len(data) > 0 {
+		key, n := decodeVarint(data)
+		data = data[n:]
+
+		f := field{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			f.value, n = decodeVarint(data)
+			data = data[n:]
+		case 2:
+			length, n := decodeVarint(data)
+			data = data[n:]
+			f.data = data[:length]
+			data = data[length:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func decodeVarint(data []byte) (uint64, int) {
+	var x uint64
+	for i, b := range data {
+		x |= uint64(b&0x7f) << (7 * uint(i))
+		if b < 0x80 {
+			return x, i + 1
+		}
+	}
+	return x, len(data)
+}
+
+func TestWritePprof(t *testing.T) {
+	fn := &Function{Name: "work", File: "/tmp/main.gs", StartLine: 2}
+	main := &Function{Name: "main", File: "/tmp/main.gs", StartLine: 1}
+	prof := &Profile{
+		Start:    time.Unix(1, 0),
+		Duration: 5 * time.Second,
+		Samples: []*Sample{
+			{Stack: []*Location{{Function: main, Line: 4}}, Time: time.Second},
+			{Stack: []*Location{{Function: fn, Line: 3}, {Function: main, Line: 4}}, Calls: 7, Time: 4 * time.Second},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := prof.WritePprof(&buf); err != nil {
+		t.Fatalf("WritePprof returned error: %v", err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("output is not gzipped: %v", err)
+	}
+	data, _ := io.ReadAll(gz)
+
+	counts := make(map[int]int)
+	strs := []string{}
+	var samples [][]field
+	for _, f := range decodeFields(t, data) {
+		counts[f.num]++
+		switch f.num {
+		case profileStringTable:
+			strs = append(strs, string(f.data))
+		case profileSample:
+			samples = append(samples, decodeFields(t, f.data))
+		case profileDurationNanos:
+			if f.value != uint64(5*time.Second) {
+				t.Errorf("wrong duration. got=%d", f.value)
+			}
+		}
+	}
+
+	if counts[profileSampleType] != 2 || counts[profileSample] != 2 || counts[profileLocation] != 3 || counts[profileFunction] != 2 {
+		t.Errorf("wrong message counts. got=%v", counts)
+	}
+
+	expectedStrings := []string{"", "calls", "count", "time", "nanoseconds", "main", "/tmp/main.gs", "work"}
+	if !reflect.DeepEqual(strs, expectedStrings) {
+		t.Errorf("wrong string table.\nwant=%q\ngot= %q", expectedStrings, strs)
+	}
+
+	if v, _ := decodeVarint(samples[1][1].data); samples[1][1].num != sampleValue || v != 7 {
+		t.Errorf("wrong call count. got=%d", v)
+	}
+}
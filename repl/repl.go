@@ -7,6 +7,7 @@ import (
 	"goscript/lexer"
 	"goscript/object"
 	"goscript/parser"
+	"goscript/resolver"
 	"io"
 )
 
@@ -15,6 +16,8 @@ const PROMPT = ">> "
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
+	macroEnv := object.NewEnvironment()
+	evaluator.SetHost(&evaluator.Host{Stdout: out, Stderr: out})
 
 	for {
 		fmt.Print(PROMPT)
@@ -33,6 +36,22 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
+		evaluator.DefineMacros(program, macroEnv)
+		if errObj := evaluator.ExpandMacros(program, macroEnv); errObj != nil {
+			io.WriteString(out, errObj.Inspect())
+			io.WriteString(out, "\n")
+			continue
+		}
+
+		if errs := resolver.Resolve(program); len(errs) != 0 {
+			msgs := make([]string, len(errs))
+			for i, err := range errs {
+				msgs[i] = err.Error()
+			}
+			printParserErrors(out, msgs)
+			continue
+		}
+
 		evaluated := evaluator.Eval(program, env)
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
@@ -0,0 +1,312 @@
+package resolver
+
+import (
+	"fmt"
+	"goscript/ast"
+)
+
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// A scope is the top level of a program, the body of a function, an arm of
+// a match or the self of a method, which all but the first keep their names
+// in a frame. Blocks do
+// not introduce scopes: a let inside an if binds in the enclosing function,
+// just as it does at runtime.
+type scope struct {
+	outer   *scope
+	frame   bool
+	slots   map[string]int
+	names   []string
+	defined map[string]bool
+}
+
+func newScope(outer *scope, frame bool) *scope {
+	return &scope{outer: outer, frame: frame, slots: make(map[string]int), names: []string{}, defined: make(map[string]bool)}
+}
+
+func (s *scope) declare(name string) int {
+	if slot, ok := s.slots[name]; ok {
+		return slot
+	}
+	s.slots[name] = len(s.names)
+	s.names = append(s.names, name)
+	return s.slots[name]
+}
+
+func (s *scope) bind(slot int) *ast.Binding {
+	if !s.frame {
+		return &ast.Binding{Global: true}
+	}
+	return &ast.Binding{Slot: slot}
+}
+
+type resolver struct {
+	errs []Error
+}
+
+// Resolve binds every identifier of the program to the slot of the frame
+// it lives in, or marks it as global, and records the slot names of each
+// function literal in its Locals. It reports duplicate parameters and names
+// bound more than once by a pattern. A name used before its definition in
+// the same scope refers to the binding outside it, as it always has at
+// runtime; lint warns about it instead.
+func Resolve(program *ast.Program) []Error {
+	r := &resolver{}
+
+	s := newScope(nil, false)
+	r.hoist(program, s)
+	for _, stmt := range program.Statements {
+		r.statement(stmt, s)
+	}
+
+	return r.errs
+}
+
+func (r *resolver) errorf(node ast.Node, format string, a ...interface{}) {
+	pos := ast.Pos(node)
+	r.errs = append(r.errs, Error{Line: pos.Line, Column: pos.Column, Message: fmt.Sprintf(format, a...)})
+}
+
+// hoist declares the names bound anywhere in a scope up front, so that
+// closures can refer to locals that are defined after them.
+func (r *resolver) hoist(node ast.Node, s *scope) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral, *ast.MatchArm:
+			return false
+		case *ast.LetStatement:
+			for _, name := range node.Names() {
+				s.declare(name.Value)
+			}
+		case *ast.ForStatement:
+			s.declare(node.Variable.Value)
+		case *ast.StructStatement:
+			s.declare(node.Name.Value)
+			return false
+		case *ast.ImportStatement:
+			s.declare(node.BoundName())
+		}
+		return true
+	})
+}
+
+func (r *resolver) statement(stmt ast.Statement, s *scope) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		r.let(stmt, s)
+	case *ast.ExportStatement:
+		r.statement(stmt.Statement, s)
+	case *ast.ImportStatement:
+		name := stmt.BoundName()
+		if stmt.Name != nil {
+			stmt.Name.Binding = s.bind(s.slots[name])
+		}
+		s.defined[name] = true
+	case *ast.ReturnStatement:
+		r.expression(stmt.ReturnValue, s)
+	case *ast.YieldStatement:
+		r.expression(stmt.Value, s)
+	case *ast.ForStatement:
+		r.expression(stmt.Iterable, s)
+		stmt.Variable.Binding = s.bind(s.slots[stmt.Variable.Value])
+		s.defined[stmt.Variable.Value] = true
+		r.statement(stmt.Body, s)
+	case *ast.StructStatement:
+		stmt.Name.Binding = s.bind(s.slots[stmt.Name.Value])
+		s.defined[stmt.Name.Value] = true
+
+		// A method is called in a frame holding only self, which encloses
+		// the frame of the call.
+		for _, method := range stmt.Methods {
+			self := newScope(s, true)
+			self.declare("self")
+			self.defined["self"] = true
+			r.function(method, self)
+		}
+	case *ast.ExpressionStatement:
+		r.expression(stmt.Expression, s)
+	case *ast.BlockStatement:
+		for _, inner := range stmt.Statements {
+			r.statement(inner, s)
+		}
+	}
+}
+
+func (r *resolver) let(stmt *ast.LetStatement, s *scope) {
+	if stmt.Pattern != nil {
+		r.expression(stmt.Value, s)
+		bound := make(map[string]bool)
+		for _, ident := range stmt.Names() {
+			if bound[ident.Value] {
+				r.errorf(ident, "%s is bound more than once in the pattern", ident.Value)
+			}
+			bound[ident.Value] = true
+			ident.Binding = s.bind(s.slots[ident.Value])
+			s.defined[ident.Value] = true
+		}
+		return
+	}
+
+	name := stmt.Name.Value
+	stmt.Name.Binding = s.bind(s.slots[name])
+
+	if _, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		s.defined[name] = true
+	}
+
+	r.expression(stmt.Value, s)
+
+	s.defined[name] = true
+}
+
+func (r *resolver) identifier(ident *ast.Identifier, s *scope) {
+	name := ident.Value
+
+	depth := 0
+	for scope := s; scope != nil; scope = scope.outer {
+		slot, ok := scope.slots[name]
+		if ok && (scope != s || s.defined[name]) {
+			if !scope.frame {
+				ident.Binding = &ast.Binding{Global: true}
+			} else {
+				ident.Binding = &ast.Binding{Depth: depth, Slot: slot}
+			}
+			return
+		}
+		if scope.frame {
+			depth++
+		}
+	}
+
+	// Builtins and names defined by earlier programs in the same
+	// environment, such as previous lines in the REPL.
+	ident.Binding = &ast.Binding{Global: true}
+}
+
+func (r *resolver) function(fl *ast.FunctionLiteral, s *scope) {
+	inner := newScope(s, true)
+
+	for i, p := range fl.Parameters {
+		// A default value is evaluated in the new frame before its
+		// parameter is bound, so it only sees the parameters before it.
+		if d := fl.ParameterDefault(i); d != nil {
+			r.expression(d, inner)
+		}
+		if _, ok := inner.slots[p.Value]; ok {
+			r.errorf(p, "duplicate parameter %s", p.Value)
+		}
+		p.Binding = &ast.Binding{Slot: inner.declare(p.Value)}
+		inner.defined[p.Value] = true
+	}
+
+	// The names in parameter patterns take the slots after the parameters,
+	// whose slots are their positions.
+	for i := range fl.Parameters {
+		for _, ident := range ast.PatternNames(fl.ParameterPattern(i)) {
+			if _, ok := inner.slots[ident.Value]; ok {
+				r.errorf(ident, "duplicate parameter %s", ident.Value)
+			}
+			ident.Binding = &ast.Binding{Slot: inner.declare(ident.Value)}
+			inner.defined[ident.Value] = true
+		}
+	}
+
+	r.hoist(fl.Body, inner)
+	for _, stmt := range fl.Body.Statements {
+		r.statement(stmt, inner)
+	}
+
+	fl.Locals = inner.names
+}
+
+// match resolves each arm of a match in a scope of its own, in which the
+// names of the pattern come first.
+func (r *resolver) match(me *ast.MatchExpression, s *scope) {
+	r.expression(me.Value, s)
+
+	for _, arm := range me.Arms {
+		inner := newScope(s, true)
+		for _, ident := range ast.PatternNames(arm.Pattern) {
+			if _, ok := inner.slots[ident.Value]; ok {
+				r.errorf(ident, "%s is bound more than once in the pattern", ident.Value)
+			}
+			ident.Binding = &ast.Binding{Slot: inner.declare(ident.Value)}
+			inner.defined[ident.Value] = true
+		}
+
+		if arm.Guard != nil {
+			r.hoist(arm.Guard, inner)
+			r.expression(arm.Guard, inner)
+		}
+		r.hoist(arm.Body, inner)
+		r.expression(arm.Body, inner)
+
+		arm.Locals = inner.names
+	}
+}
+
+func (r *resolver) expression(exp ast.Expression, s *scope) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		r.identifier(exp, s)
+	case *ast.PrefixExpression:
+		r.expression(exp.Right, s)
+	case *ast.SpawnExpression:
+		r.expression(exp.Call, s)
+	case *ast.SpreadExpression:
+		r.expression(exp.Value, s)
+	case *ast.InfixExpression:
+		r.expression(exp.Left, s)
+		r.expression(exp.Right, s)
+	case *ast.IfExpression:
+		r.expression(exp.Condition, s)
+		r.statement(exp.Consequence, s)
+		if exp.Alternative != nil {
+			r.statement(exp.Alternative, s)
+		}
+	case *ast.MatchExpression:
+		r.match(exp, s)
+	case *ast.FunctionLiteral:
+		r.function(exp, s)
+	case *ast.CallExpression:
+		// Quoted code is data; it is resolved once it has been expanded.
+		if ident, ok := exp.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+			return
+		}
+		r.expression(exp.Function, s)
+		for _, arg := range exp.Arguments {
+			r.expression(arg, s)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			r.expression(el, s)
+		}
+	case *ast.InterpolatedString:
+		for _, part := range exp.Parts {
+			r.expression(part, s)
+		}
+	case *ast.HashLiteral:
+		for _, key := range exp.Keys {
+			r.expression(key, s)
+			r.expression(exp.Pairs[key], s)
+		}
+	case *ast.IndexExpression:
+		r.expression(exp.Left, s)
+		r.expression(exp.Index, s)
+	case *ast.MemberExpression:
+		r.expression(exp.Object, s)
+	case *ast.WithExpression:
+		r.expression(exp.Left, s)
+		for _, value := range exp.Values {
+			r.expression(value, s)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package resolver
+
+import (
+	"goscript/ast"
+	"goscript/lexer"
+	"goscript/parser"
+	"reflect"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		t.Fatalf("parser errors: %v", errs)
+	}
+	return program
+}
+
+// bindings lists the bindings of the identifiers named name, in source order.
+func bindings(program *ast.Program, name string) []ast.Binding {
+	result := []ast.Binding{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok && ident.Value == name {
+			if ident.Binding == nil {
+				result = append(result, ast.Binding{Depth: -1})
+			} else {
+				result = append(result, *ident.Binding)
+			}
+		}
+		return true
+	})
+	return result
+}
+
+func TestBindings(t *testing.T) {
+	global := ast.Binding{Global: true}
+
+	tests := []struct {
+		testName string
+		input    string
+		name     string
+		expected []ast.Binding
+	}{
+		{
+			"globals",
+			"let x = 1; puts(x);",
+			"x",
+			[]ast.Binding{global, global},
+		},
+		{
+			"parameters and locals",
+			"let f = fn(a, b) { let c = a + b; c };",
+			"c",
+			[]ast.Binding{{Slot: 2}, {Slot: 2}},
+		},
+		{
+			"captured local",
+			"let f = fn(a) { fn(b) { a + b } };",
+			"a",
+			[]ast.Binding{{Slot: 0}, {Depth: 1, Slot: 0}},
+		},
+		{
+			"let in a block binds in the function",
+			"let f = fn(a) { if (a) { let b = 1; b } };",
+			"b",
+			[]ast.Binding{{Slot: 1}, {Slot: 1}},
+		},
+		{
+			"value refers to the outer binding",
+			"let x = 1; let f = fn() { let x = x + 1; x };",
+			"x",
+			[]ast.Binding{global, {Slot: 0}, global, {Slot: 0}},
+		},
+		{
+			"closure over a later local",
+			"let f = fn() { let g = fn() { h() }; let h = fn() { 1 }; g() };",
+			"h",
+			[]ast.Binding{{Depth: 1, Slot: 1}, {Slot: 1}},
+		},
+		{
+			"recursive function",
+			"let f = fn() { let loop = fn(n) { loop(n) }; loop(1) };",
+			"loop",
+			[]ast.Binding{{Slot: 0}, {Depth: 1, Slot: 0}, {Slot: 0}},
+		},
+		{
+			"loop variable",
+			"let f = fn(xs) { for (x in xs) { puts(x) } };",
+			"x",
+			[]ast.Binding{{Slot: 1}, {Slot: 1}},
+		},
+		{
+			"default values see earlier parameters",
+			"let b = 1; let f = fn(a, c = a + b, b = 2) { c };",
+			"b",
+			[]ast.Binding{global, global, {Slot: 2}},
+		},
+		{
+			"parameter patterns take the slots after the parameters",
+			"let f = fn(a, [b, c]) { let {d} = a; b + c + d };",
+			"c",
+			[]ast.Binding{{Slot: 3}, {Slot: 3}},
+		},
+		{
+			"match arms have frames of their own",
+			"let f = fn(a) { match (a) { [b, c] => fn() { c + a } } };",
+			"a",
+			[]ast.Binding{{Slot: 0}, {Slot: 0}, {Depth: 2, Slot: 0}},
+		},
+		{
+			"methods see self in the frame around them",
+			"struct P { x, fn get(a) { fn() { self.x + a } } }",
+			"self",
+			[]ast.Binding{{Depth: 2, Slot: 0}},
+		},
+		{
+			"a use before the definition refers to the outer binding",
+			"let f = fn(x) { fn() { let y = x; let x = 1; x } };",
+			"x",
+			[]ast.Binding{{Slot: 0}, {Depth: 1, Slot: 0}, {Slot: 1}, {Slot: 1}},
+		},
+		{
+			"builtins are global",
+			"let f = fn(a) { len(a) };",
+			"len",
+			[]ast.Binding{global},
+		},
+		{
+			"quoted code is not resolved",
+			"let f = fn(a) { quote(a) };",
+			"a",
+			[]ast.Binding{{Slot: 0}, {Depth: -1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			program := parse(t, tt.input)
+			if errs := Resolve(program); len(errs) != 0 {
+				t.Fatalf("resolver errors: %v", errs)
+			}
+
+			got := bindings(program, tt.name)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("wrong bindings for %s. got=%+v, want=%+v", tt.name, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLocals(t *testing.T) {
+	program := parse(t, "let f = fn(a, b) { let c = 1; if (a) { let d = 2; } fn(e) { let g = e; g } };")
+	if errs := Resolve(program); len(errs) != 0 {
+		t.Fatalf("resolver errors: %v", errs)
+	}
+
+	locals := [][]string{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		if fl, ok := node.(*ast.FunctionLiteral); ok {
+			locals = append(locals, fl.Locals)
+		}
+		return true
+	})
+
+	expected := [][]string{{"a", "b", "c", "d"}, {"e", "g"}}
+	if !reflect.DeepEqual(locals, expected) {
+		t.Errorf("wrong locals. got=%v, want=%v", locals, expected)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		testName string
+		input    string
+		expected []string
+	}{
+		{
+			"duplicate parameter",
+			"let f = fn(a, b, a) { a };",
+			[]string{"1:18: duplicate parameter a"},
+		},
+		{
+			"duplicate name in a parameter pattern",
+			"let f = fn([x, {k: x}]) { x };",
+			[]string{"1:20: duplicate parameter x"},
+		},
+		{
+			"duplicate name in a let pattern",
+			"let [a, {k: [a]}, ...a] = [1, {\"k\": [2]}]; let [_, _] = [3, 4];",
+			[]string{"1:14: a is bound more than once in the pattern", "1:22: a is bound more than once in the pattern"},
+		},
+		{
+			"closures may refer to later definitions",
+			"let f = fn() { g() };\nlet g = fn() { 1 };",
+			[]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			got := []string{}
+			for _, err := range Resolve(parse(t, tt.input)) {
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("wrong errors. got=%q, want=%q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"goscript/check"
+	"goscript/coverage"
+	"goscript/evaluator"
+	"goscript/object"
+	"goscript/profile"
+	"os"
+	"path/filepath"
+)
+
+type runFlags struct {
+	path       *string
+	allowRead  *string
+	allowWrite *string
+	allowStdin *bool
+	allowEnv   *bool
+	noOptimize *bool
+}
+
+func addRunFlags(flags *flag.FlagSet) *runFlags {
+	return &runFlags{
+		path:       flags.String("path", os.Getenv("GOSCRIPTPATH"), "list of directories searched for imported modules"),
+		allowRead:  flags.String("allow-read", "", "directory the script may read with read_file and list_dir"),
+		allowWrite: flags.String("allow-write", "", "directory the script may write with write_file"),
+		allowStdin: flags.Bool("allow-stdin", false, "allow the script to read standard input with read_line"),
+		allowEnv:   flags.Bool("allow-env", false, "allow the script to read environment variables"),
+		noOptimize: flags.Bool("no-optimize", false, "run the script without folding constants and pruning dead branches"),
+	}
+}
+
+func (f *runFlags) configure() *evaluator.Host {
+	im := evaluator.NewImporter(filepath.SplitList(*f.path))
+	im.Optimize = !*f.noOptimize
+	evaluator.SetImporter(im)
+
+	host := evaluator.DefaultHost()
+	host.Caps = evaluator.Capabilities{
+		ReadRoot: *f.allowRead,
+		WriteDir: *f.allowWrite,
+		Stdin:    *f.allowStdin,
+		Env:      *f.allowEnv,
+	}
+	evaluator.SetHost(host)
+
+	return host
+}
+
+func runMain(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	opts := addRunFlags(flags)
+	profileFile := flags.String("profile", "", "write an execution profile in pprof format to `file`")
+	cover := flags.Bool("cover", false, "report statement and branch coverage on standard error")
+	coverProfile := flags.String("coverprofile", "", "write an LCOV coverage profile to `file`")
+	typeCheck := flags.Bool("check", false, "type check the script and stop before running it if there are errors")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "usage: goscript run [flags] file%s\n", evaluator.SourceExt)
+		return 2
+	}
+
+	if *profileFile != "" && (*cover || *coverProfile != "") {
+		fmt.Fprintln(os.Stderr, "goscript run: -profile cannot be combined with coverage")
+		return 2
+	}
+
+	// Coverage is reported for the code as written, including the
+	// branches the optimiser would remove.
+	if *cover || *coverProfile != "" {
+		*opts.noOptimize = true
+	}
+
+	opts.configure()
+
+	program, errObj := evaluator.ParseFile(flags.Arg(0))
+	if errObj != nil {
+		fmt.Fprintln(os.Stderr, errObj.Inspect())
+		return 1
+	}
+
+	if *typeCheck {
+		if diags := check.Program(program); len(diags) != 0 {
+			for _, d := range diags {
+				fmt.Fprintf(os.Stderr, "%s:%s\n", flags.Arg(0), d)
+			}
+			return 1
+		}
+	}
+
+	var profiler *profile.Profiler
+	if *profileFile != "" {
+		profiler = profile.New()
+		profiler.Start()
+	}
+
+	var collector *coverage.Collector
+	if *cover || *coverProfile != "" {
+		collector = coverage.New()
+		collector.Start()
+	}
+
+	result := evaluator.EvalProgram(flags.Arg(0), program, object.NewEnvironment())
+
+	if profiler != nil {
+		if err := writeProfile(*profileFile, profiler.Stop()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	if collector != nil {
+		prof := collector.Stop()
+		if *cover {
+			fmt.Fprintf(os.Stderr, "coverage: %s\n", prof.Summary())
+		}
+		if *coverProfile != "" {
+			if err := writeCoverProfile(*coverProfile, prof); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+		}
+	}
+
+	if errObj, ok := result.(*object.Error); ok {
+		fmt.Fprintln(os.Stderr, errObj.Inspect())
+		return 1
+	}
+
+	return 0
+}
+
+func writeProfile(path string, prof *profile.Profile) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := prof.WritePprof(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func writeCoverProfile(path string, prof *coverage.Profile) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := prof.WriteLCOV(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
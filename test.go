@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"goscript/testrunner"
+	"os"
+	"regexp"
+)
+
+func testMain(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	opts := addRunFlags(flags)
+	run := flags.String("run", "", "run only the tests whose name matches `regexp`")
+	verbose := flags.Bool("v", false, "print the name and output of every test")
+	junit := flags.String("junit", "", "write a JUnit XML report to `file`")
+	flags.Parse(args)
+
+	var filter *regexp.Regexp
+	if *run != "" {
+		var err error
+		if filter, err = regexp.Compile(*run); err != nil {
+			fmt.Fprintf(os.Stderr, "goscript test: invalid -run: %s\n", err)
+			return 2
+		}
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := testrunner.Discover(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	runner := testrunner.New(opts.configure(), filter)
+
+	status := 0
+	suites := []*testrunner.Suite{}
+	for _, file := range files {
+		suite := runner.RunFile(file)
+		if !suite.Passed() {
+			status = 1
+		}
+		suites = append(suites, suite)
+	}
+
+	if err := testrunner.WriteText(os.Stdout, suites, *verbose); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *junit != "" {
+		if err := writeJUnit(*junit, suites); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	return status
+}
+
+func writeJUnit(path string, suites []*testrunner.Suite) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := testrunner.WriteJUnit(f, suites); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,160 @@
+package testrunner
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func indent(text, prefix string) string {
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	return prefix + strings.Join(lines, "\n"+prefix) + "\n"
+}
+
+// WriteText reports in the style of go test: failures with their output,
+// every test when verbose, and one status line per file.
+func WriteText(w io.Writer, suites []*Suite, verbose bool) error {
+	bw := bufio.NewWriter(w)
+
+	for _, s := range suites {
+		for _, r := range s.Results {
+			if verbose {
+				fmt.Fprintf(bw, "=== RUN   %s\n", r.Name)
+				if r.Output != "" {
+					bw.WriteString(indent(r.Output, "    "))
+				}
+			}
+
+			if r.Passed() {
+				if verbose {
+					fmt.Fprintf(bw, "--- PASS: %s (%ss)\n", r.Name, seconds(r.Duration))
+				}
+				continue
+			}
+
+			fmt.Fprintf(bw, "--- FAIL: %s (%ss)\n", r.Name, seconds(r.Duration))
+			if !verbose && r.Output != "" {
+				bw.WriteString(indent(r.Output, "    "))
+			}
+			if r.Error != "" {
+				bw.WriteString(indent(r.Error, "    "))
+			} else {
+				bw.WriteString(indent(r.Failure, "    "))
+			}
+		}
+
+		switch {
+		case s.Err != "":
+			fmt.Fprintf(bw, "FAIL\t%s\t%s\n", s.File, s.Err)
+		case s.Failed() > 0:
+			fmt.Fprintf(bw, "FAIL\t%s\t%ss\n", s.File, seconds(s.Duration))
+		case len(s.Results) == 0:
+			fmt.Fprintf(bw, "ok  \t%s\t%ss [no tests to run]\n", s.File, seconds(s.Duration))
+		default:
+			fmt.Fprintf(bw, "ok  \t%s\t%ss\n", s.File, seconds(s.Duration))
+		}
+	}
+
+	return bw.Flush()
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Error    *junitMessage   `xml:"error,omitempty"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func newJUnitMessage(text string) *junitMessage {
+	message := text
+	if i := strings.Index(message, "\n"); i >= 0 {
+		message = message[:i]
+	}
+	return &junitMessage{Message: message, Text: text}
+}
+
+func WriteJUnit(w io.Writer, suites []*Suite) error {
+	out := junitTestSuites{}
+	var total time.Duration
+
+	for _, s := range suites {
+		js := junitTestSuite{
+			Name:     s.File,
+			Tests:    len(s.Results),
+			Failures: s.Failed() - s.Errored(),
+			Errors:   s.Errored(),
+			Time:     seconds(s.Duration),
+		}
+		if s.Err != "" {
+			js.Errors++
+			js.Error = newJUnitMessage(s.Err)
+		}
+
+		for _, r := range s.Results {
+			tc := junitTestCase{
+				Name:      r.Name,
+				Classname: s.File,
+				Time:      seconds(r.Duration),
+				SystemOut: r.Output,
+			}
+			if r.Failure != "" {
+				tc.Failure = newJUnitMessage(r.Failure)
+			}
+			if r.Error != "" {
+				tc.Error = newJUnitMessage(r.Error)
+			}
+			js.Cases = append(js.Cases, tc)
+		}
+
+		out.Tests += js.Tests
+		out.Failures += js.Failures
+		out.Errors += js.Errors
+		total += s.Duration
+		out.Suites = append(out.Suites, js)
+	}
+	out.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,195 @@
+package testrunner
+
+import (
+	"bytes"
+	"goscript/ast"
+	"goscript/evaluator"
+	"goscript/object"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	FileSuffix = "_test" + evaluator.SourceExt
+	TestPrefix = "test_"
+)
+
+// A Result is the outcome of one test. Failure holds the message of a failed
+// assertion, and Error that of any other error that stopped the test.
+type Result struct {
+	Name     string
+	Failure  string
+	Error    string
+	Output   string
+	Duration time.Duration
+}
+
+func (r *Result) Passed() bool {
+	return r.Failure == "" && r.Error == ""
+}
+
+type Suite struct {
+	File     string
+	Err      string
+	Results  []*Result
+	Duration time.Duration
+}
+
+func (s *Suite) Failed() int {
+	failed := 0
+	for _, r := range s.Results {
+		if !r.Passed() {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Errored returns the number of tests stopped by an error other than a
+// failed assertion.
+func (s *Suite) Errored() int {
+	errored := 0
+	for _, r := range s.Results {
+		if r.Error != "" {
+			errored++
+		}
+	}
+	return errored
+}
+
+func (s *Suite) Passed() bool {
+	return s.Err == "" && s.Failed() == 0
+}
+
+type Runner struct {
+	host   *evaluator.Host
+	filter *regexp.Regexp
+	now    func() time.Time
+}
+
+func New(host *evaluator.Host, filter *regexp.Regexp) *Runner {
+	return &Runner{host: host, filter: filter, now: time.Now}
+}
+
+// Discover expands directories into the test files they contain, walking
+// them recursively; files named explicitly are kept as they are.
+func Discover(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		found := []string{}
+		err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(p, FileSuffix) {
+				found = append(found, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Strings(found)
+		files = append(files, found...)
+	}
+	return files, nil
+}
+
+func testNames(program *ast.Program) []string {
+	names := []string{}
+	for _, stmt := range program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			stmt = export.Statement
+		}
+
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			continue
+		}
+		if _, ok := let.Value.(*ast.FunctionLiteral); ok && let.Name != nil && strings.HasPrefix(let.Name.Value, TestPrefix) {
+			names = append(names, let.Name.Value)
+		}
+	}
+	return names
+}
+
+func (r *Runner) RunFile(path string) *Suite {
+	start := r.now()
+	suite := &Suite{File: path}
+
+	program, errObj := evaluator.ParseFile(path)
+	if errObj != nil {
+		suite.Err = errObj.Message
+		suite.Duration = r.now().Sub(start)
+		return suite
+	}
+
+	for _, name := range testNames(program) {
+		if r.filter != nil && !r.filter.MatchString(name) {
+			continue
+		}
+		suite.Results = append(suite.Results, r.runTest(path, program, name))
+	}
+
+	suite.Duration = r.now().Sub(start)
+	return suite
+}
+
+func (r *Runner) runTest(path string, program *ast.Program, name string) *Result {
+	var output bytes.Buffer
+	host := *r.host
+	host.Stdout = &output
+	host.Stderr = &output
+	evaluator.SetHost(&host)
+	defer evaluator.SetHost(r.host)
+
+	start := r.now()
+	result := &Result{Name: name}
+	if errObj := runTest(path, program, name); errObj != nil {
+		if errObj.Assertion {
+			result.Failure = errObj.Message
+		} else {
+			result.Error = errObj.Message
+		}
+	}
+	result.Duration = r.now().Sub(start)
+	result.Output = output.String()
+
+	return result
+}
+
+// Every test evaluates the file again in a fresh environment, so tests
+// cannot observe top-level state changed by the tests that ran before them.
+func runTest(path string, program *ast.Program, name string) *object.Error {
+	env := object.NewEnvironment()
+	if errObj, ok := evaluator.EvalProgram(path, program, env).(*object.Error); ok {
+		return errObj
+	}
+
+	fn, ok := env.Get(name)
+	if !ok {
+		return &object.Error{Message: "test function " + name + " is not defined"}
+	}
+	if f, ok := fn.(*object.Function); !ok || len(f.Parameters) != 0 {
+		return &object.Error{Message: "test function " + name + " must not take parameters"}
+	}
+
+	if errObj, ok := evaluator.Apply(fn).(*object.Error); ok {
+		return errObj
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package testrunner
+
+import (
+	"bytes"
+	"goscript/evaluator"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+const mathTests = `puts("loading");
+let add = fn(a, b) { a + b };
+let test_add = fn() {
+	assert_eq(add(1, 2), 3);
+};
+let test_list = fn() {
+	assert_eq([1, 2], [1, 3]);
+};
+export let test_error = fn() {
+	assert_error(fn() { add(1, true) }, "type mismatch");
+};
+let test_crash = fn() { add(1, true) };
+let test_params = fn(x) { x };
+let helper = fn() { assert(false) };
+let test_value = 1;
+`
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func newRunner(filter string) *Runner {
+	evaluator.SetImporter(evaluator.NewImporter(nil))
+
+	var re *regexp.Regexp
+	if filter != "" {
+		re = regexp.MustCompile(filter)
+	}
+
+	r := New(&evaluator.Host{Stdout: io.Discard, Stderr: io.Discard}, re)
+	r.now = func() time.Time { return time.Unix(0, 0) }
+	return r
+}
+
+func TestDiscover(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a_test.gs":       "",
+		"b.gs":            "",
+		"sub/c_test.gs":   "",
+		"sub/d/e_test.gs": "",
+		"sub/test.gs":     "",
+	})
+
+	files, err := Discover([]string{filepath.Join(dir, "sub"), filepath.Join(dir, "b.gs")})
+	if err != nil {
+		t.Fatalf("Discover returned error: %v", err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "sub/c_test.gs"),
+		filepath.Join(dir, "sub/d/e_test.gs"),
+		filepath.Join(dir, "b.gs"),
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("wrong files.\nwant=%q\ngot= %q", expected, files)
+	}
+
+	if _, err := Discover([]string{filepath.Join(dir, "missing")}); err == nil {
+		t.Errorf("expected an error for a missing path")
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"math_test.gs": mathTests,
+		"bad_test.gs":  "let x = ;",
+	})
+	path := filepath.Join(dir, "math_test.gs")
+
+	tests := []struct {
+		testName string
+		filter   string
+		expected []*Result
+	}{
+		{
+			"all tests",
+			"",
+			[]*Result{
+				{Name: "test_add", Output: "loading\n"},
+				{Name: "test_list", Output: "loading\n", Failure: "assert_eq failed\n--- want\n+++ got\n@@ -1 +1 @@\n-[1, 3]\n+[1, 2]"},
+				{Name: "test_error", Output: "loading\n"},
+				{Name: "test_crash", Output: "loading\n", Error: "type mismatch: INTEGER + BOOLEAN"},
+				{Name: "test_params", Output: "loading\n", Error: "test function test_params must not take parameters"},
+			},
+		},
+		{
+			"filtered",
+			"^test_(add|error)$",
+			[]*Result{
+				{Name: "test_add", Output: "loading\n"},
+				{Name: "test_error", Output: "loading\n"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			suite := newRunner(tt.filter).RunFile(path)
+
+			if suite.Err != "" {
+				t.Fatalf("unexpected suite error: %s", suite.Err)
+			}
+			if !reflect.DeepEqual(suite.Results, tt.expected) {
+				for _, r := range suite.Results {
+					t.Logf("%+v", r)
+				}
+				t.Errorf("wrong results")
+			}
+		})
+	}
+
+	suite := newRunner("").RunFile(filepath.Join(dir, "bad_test.gs"))
+	if suite.Err == "" || suite.Passed() {
+		t.Errorf("expected a suite error for a file that does not parse")
+	}
+}
+
+func TestReports(t *testing.T) {
+	suites := []*Suite{
+		{
+			File:     "math_test.gs",
+			Duration: 1500 * time.Millisecond,
+			Results: []*Result{
+				{Name: "test_add", Output: "adding\n", Duration: time.Second},
+				{Name: "test_sub", Failure: "assert failed\ndetails", Output: "subtracting\n", Duration: 500 * time.Millisecond},
+				{Name: "test_div", Error: "division by zero"},
+			},
+		},
+		{File: "empty_test.gs"},
+		{File: "bad_test.gs", Err: "parse error"},
+	}
+
+	tests := []struct {
+		testName string
+		verbose  bool
+		expected string
+	}{
+		{
+			"plain",
+			false,
+			"--- FAIL: test_sub (0.500s)\n" +
+				"    subtracting\n" +
+				"    assert failed\n" +
+				"    details\n" +
+				"--- FAIL: test_div (0.000s)\n" +
+				"    division by zero\n" +
+				"FAIL\tmath_test.gs\t1.500s\n" +
+				"ok  \tempty_test.gs\t0.000s [no tests to run]\n" +
+				"FAIL\tbad_test.gs\tparse error\n",
+		},
+		{
+			"verbose",
+			true,
+			"=== RUN   test_add\n" +
+				"    adding\n" +
+				"--- PASS: test_add (1.000s)\n" +
+				"=== RUN   test_sub\n" +
+				"    subtracting\n" +
+				"--- FAIL: test_sub (0.500s)\n" +
+				"    assert failed\n" +
+				"    details\n" +
+				"=== RUN   test_div\n" +
+				"--- FAIL: test_div (0.000s)\n" +
+				"    division by zero\n" +
+				"FAIL\tmath_test.gs\t1.500s\n" +
+				"ok  \tempty_test.gs\t0.000s [no tests to run]\n" +
+				"FAIL\tbad_test.gs\tparse error\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteText(&buf, suites, tt.verbose); err != nil {
+				t.Fatalf("WriteText returned error: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("wrong report.\nwant=%q\ngot= %q", tt.expected, buf.String())
+			}
+		})
+	}
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="1" errors="2" time="1.500">
+  <testsuite name="math_test.gs" tests="3" failures="1" errors="1" time="1.500">
+    <testcase name="test_add" classname="math_test.gs" time="1.000">
+      <system-out>adding&#xA;</system-out>
+    </testcase>
+    <testcase name="test_sub" classname="math_test.gs" time="0.500">
+      <failure message="assert failed">assert failed&#xA;details</failure>
+      <system-out>subtracting&#xA;</system-out>
+    </testcase>
+    <testcase name="test_div" classname="math_test.gs" time="0.000">
+      <error message="division by zero">division by zero</error>
+    </testcase>
+  </testsuite>
+  <testsuite name="empty_test.gs" tests="0" failures="0" errors="0" time="0.000"></testsuite>
+  <testsuite name="bad_test.gs" tests="0" failures="0" errors="1" time="0.000">
+    <error message="parse error">parse error</error>
+  </testsuite>
+</testsuites>
+`
+
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, suites); err != nil {
+		t.Fatalf("WriteJUnit returned error: %v", err)
+	}
+	if buf.String() != expected {
+		t.Errorf("wrong JUnit report.\nwant=%s\ngot= %s", expected, buf.String())
+	}
+}
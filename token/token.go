@@ -5,6 +5,8 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int
+	Column  int
 }
 
 var keywords = map[string]TokenType{
@@ -15,6 +17,16 @@ var keywords = map[string]TokenType{
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"import": IMPORT,
+	"export": EXPORT,
+	"macro":  MACRO,
+	"spawn":  SPAWN,
+	"yield":  YIELD,
+	"for":    FOR,
+	"in":     IN,
+	"match":  MATCH,
+	"struct": STRUCT,
+	"with":   WITH,
 }
 
 func LookupIdent(ident string) TokenType {
@@ -28,6 +40,8 @@ const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 
+	COMMENT = "COMMENT"
+
 	IDENT  = "IDENT"
 	INT    = "INT"
 	STRING = "STRING"
@@ -47,6 +61,9 @@ const (
 
 	COMMA     = ","
 	SEMICOLON = ";"
+	DOT       = "."
+	ELLIPSIS  = "..."
+	ARROW     = "=>"
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -62,5 +79,15 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
-	COLON = ":"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
+	MACRO    = "MACRO"
+	SPAWN    = "SPAWN"
+	YIELD    = "YIELD"
+	FOR      = "FOR"
+	IN       = "IN"
+	MATCH    = "MATCH"
+	STRUCT   = "STRUCT"
+	WITH     = "WITH"
+	COLON    = ":"
 )